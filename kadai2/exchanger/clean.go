@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/converter"
+	"time"
+)
+
+func (cli *CLI) runClean(args []string) int {
+	fs := cli.flagSet("clean", "Lists the conversion runs recorded in the output directory, or deletes the outputs of a run or the stale ones.")
+	run := fs.String("run", "", "ID of the run whose outputs are deleted, or last for the latest run")
+	stale := fs.Bool("stale", false, "delete the outputs whose sources no longer exist")
+	dryRun := fs.Bool("n", false, "print the files which would be deleted without deleting them")
+	if err := fs.Parse(args); err != nil {
+		return exitCode(err)
+	}
+	if fs.NArg() != 1 || *run != "" && *stale {
+		fs.Usage()
+		return 1
+	}
+	dir := fs.Arg(0)
+
+	if *run == "" && !*stale {
+		runs, err := converter.ReadHistory(dir)
+		if err != nil {
+			fmt.Fprintln(cli.errStream, err)
+			return 1
+		}
+		if len(runs) == 0 {
+			fmt.Fprintln(cli.outStream, "No runs recorded")
+			return 0
+		}
+		for _, r := range runs {
+			fmt.Fprintf(cli.outStream, "%s  %s  %d files\n", r.ID, r.Time.Format(time.RFC3339), len(r.Files))
+		}
+		return 0
+	}
+
+	var removed []string
+	var err error
+	if *stale {
+		removed, err = converter.CleanStale(dir, *dryRun)
+	} else {
+		removed, err = converter.CleanRun(dir, *run, *dryRun)
+	}
+	for _, p := range removed {
+		if *dryRun {
+			fmt.Fprintf(cli.outStream, "would delete %s\n", p)
+		} else {
+			fmt.Fprintf(cli.outStream, "deleted %s\n", p)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(cli.errStream, err)
+		return 1
+	}
+	if *dryRun {
+		fmt.Fprintf(cli.outStream, "%d files would be deleted\n", len(removed))
+	} else {
+		fmt.Fprintf(cli.outStream, "%d files deleted\n", len(removed))
+	}
+
+	return 0
+}
@@ -0,0 +1,401 @@
+// Package bmp implements a decoder and an encoder for Windows BMP images.
+//
+// The decoder supports uncompressed 1, 4, 8, 16, 24 and 32 bits per pixel images,
+// including BI_BITFIELDS masks and the alpha mask of BITMAPV4HEADER and later.
+// RLE compressed images are not supported.
+package bmp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"math/bits"
+)
+
+const (
+	fileHeaderSize = 14
+	infoHeaderSize = 40
+	v4HeaderSize   = 108
+
+	biRGB            = 0
+	biBitFields      = 3
+	biAlphaBitFields = 6
+
+	// 巨大なヘッダで大量のメモリを確保しないための上限。
+	maxPixels = 1 << 28
+)
+
+// FormatError reports that the input is not a valid BMP image.
+type FormatError string
+
+func (e FormatError) Error() string { return "bmp: invalid format: " + string(e) }
+
+// UnsupportedError reports that the input uses a valid but unimplemented BMP feature.
+type UnsupportedError string
+
+func (e UnsupportedError) Error() string { return "bmp: unsupported feature: " + string(e) }
+
+type header struct {
+	offset      uint32
+	width       int
+	height      int
+	topDown     bool
+	bpp         int
+	compression uint32
+	masks       [4]uint32 // R, G, B, A
+	palette     color.Palette
+}
+
+func (h header) hasAlpha() bool { return h.masks[3] != 0 }
+
+func (h header) colorModel() color.Model {
+	switch {
+	case h.bpp <= 8:
+		return h.palette
+	case h.hasAlpha():
+		return color.NRGBAModel
+	}
+	return color.RGBAModel
+}
+
+// readHeader reads the headers and the color table. It consumes exactly read bytes from r.
+func readHeader(r io.Reader) (h header, read int, err error) {
+	var b [fileHeaderSize + 4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return h, 0, err
+	}
+	if string(b[:2]) != "BM" {
+		return h, 0, FormatError("bad magic number")
+	}
+	h.offset = binary.LittleEndian.Uint32(b[10:])
+	size := int(binary.LittleEndian.Uint32(b[14:]))
+	read = len(b)
+
+	// カラーテーブルの1色あたりのバイト数と色数
+	entrySize, colors := 4, 0
+	switch size {
+	case 12:
+		// BITMAPCOREHEADER
+		var c [8]byte
+		if _, err := io.ReadFull(r, c[:]); err != nil {
+			return h, read, unexpectedEOF(err)
+		}
+		read += len(c)
+		h.width = int(binary.LittleEndian.Uint16(c[0:]))
+		h.height = int(binary.LittleEndian.Uint16(c[2:]))
+		h.bpp = int(binary.LittleEndian.Uint16(c[6:]))
+		entrySize = 3
+	case infoHeaderSize, 52, 56, v4HeaderSize, 124:
+		info := make([]byte, size-4)
+		if _, err := io.ReadFull(r, info); err != nil {
+			return h, read, unexpectedEOF(err)
+		}
+		read += len(info)
+		h.width = int(int32(binary.LittleEndian.Uint32(info[0:])))
+		h.height = int(int32(binary.LittleEndian.Uint32(info[4:])))
+		h.bpp = int(binary.LittleEndian.Uint16(info[10:]))
+		h.compression = binary.LittleEndian.Uint32(info[12:])
+		colors = int(binary.LittleEndian.Uint32(info[28:]))
+		if h.height < 0 {
+			h.height = -h.height
+			h.topDown = true
+		}
+
+		switch h.compression {
+		case biRGB:
+		case biBitFields, biAlphaBitFields:
+			n := 3
+			if h.compression == biAlphaBitFields {
+				n = 4
+			}
+			// BITMAPINFOHEADER ではマスクがヘッダの直後に続く。
+			masks := info[36:]
+			if size == infoHeaderSize {
+				masks = make([]byte, 4*n)
+				if _, err := io.ReadFull(r, masks); err != nil {
+					return h, read, unexpectedEOF(err)
+				}
+				read += len(masks)
+			}
+			if len(masks) < 4*n {
+				return h, read, FormatError("missing bit masks")
+			}
+			for i := 0; i < n; i++ {
+				h.masks[i] = binary.LittleEndian.Uint32(masks[4*i:])
+			}
+			if size >= v4HeaderSize {
+				h.masks[3] = binary.LittleEndian.Uint32(info[48:])
+			}
+		default:
+			return h, read, UnsupportedError("compression")
+		}
+		if h.bpp <= 8 && colors > 1<<uint(h.bpp) {
+			return h, read, FormatError("too many colors")
+		}
+	default:
+		return h, read, UnsupportedError("header size")
+	}
+
+	if h.width <= 0 || h.height <= 0 || h.width*h.height > maxPixels {
+		return h, read, FormatError("bad dimensions")
+	}
+
+	switch h.bpp {
+	case 1, 4, 8:
+		n := colors
+		if n == 0 {
+			n = 1 << uint(h.bpp)
+		}
+		p := make([]byte, n*entrySize)
+		if _, err := io.ReadFull(r, p); err != nil {
+			return h, read, unexpectedEOF(err)
+		}
+		read += len(p)
+		h.palette = make(color.Palette, n)
+		for i := range h.palette {
+			e := p[i*entrySize:]
+			h.palette[i] = color.RGBA{e[2], e[1], e[0], 0xff}
+		}
+	case 16:
+		if h.compression == biRGB {
+			h.masks = [4]uint32{0x7c00, 0x03e0, 0x001f, 0}
+		}
+	case 24:
+		if h.compression != biRGB {
+			return h, read, UnsupportedError("bit fields with 24 bits per pixel")
+		}
+		h.masks = [4]uint32{0xff0000, 0x00ff00, 0x0000ff, 0}
+	case 32:
+		if h.compression == biRGB {
+			h.masks = [4]uint32{0xff0000, 0x00ff00, 0x0000ff, 0}
+		}
+	default:
+		return h, read, UnsupportedError("bits per pixel")
+	}
+
+	return h, read, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// DecodeConfig returns the color model and dimensions of a BMP image without decoding the entire image.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	h, _, err := readHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	return image.Config{ColorModel: h.colorModel(), Width: h.width, Height: h.height}, nil
+}
+
+// Decode reads a BMP image from r and returns it as an image.Image.
+// Indexed images are returned as *image.Paletted, images with an alpha mask
+// as *image.NRGBA and the others as *image.RGBA.
+func Decode(r io.Reader) (image.Image, error) {
+	h, read, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if int(h.offset) < read {
+		return nil, FormatError("bad pixel data offset")
+	}
+	if _, err := io.CopyN(ioutil.Discard, r, int64(int(h.offset)-read)); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	rect := image.Rect(0, 0, h.width, h.height)
+	stride := (h.width*h.bpp + 31) / 32 * 4
+	row := make([]byte, stride)
+	br := bufio.NewReader(r)
+
+	var set func(x, y int)
+	var img image.Image
+	switch {
+	case h.bpp <= 8:
+		m := image.NewPaletted(rect, h.palette)
+		mask := byte(1<<uint(h.bpp) - 1)
+		set = func(x, y int) {
+			shift := uint(8 - h.bpp - x*h.bpp%8)
+			idx := row[x*h.bpp/8] >> shift & mask
+			if int(idx) >= len(h.palette) {
+				idx = 0
+			}
+			m.Pix[y*m.Stride+x] = idx
+		}
+		img = m
+	default:
+		var pix []byte
+		var pixStride int
+		if h.hasAlpha() {
+			m := image.NewNRGBA(rect)
+			pix, pixStride, img = m.Pix, m.Stride, m
+		} else {
+			m := image.NewRGBA(rect)
+			pix, pixStride, img = m.Pix, m.Stride, m
+		}
+		bytesPerPixel := h.bpp / 8
+		set = func(x, y int) {
+			var v uint32
+			for i := bytesPerPixel - 1; i >= 0; i-- {
+				v = v<<8 | uint32(row[x*bytesPerPixel+i])
+			}
+			p := pix[y*pixStride+x*4:]
+			p[0] = component(v, h.masks[0])
+			p[1] = component(v, h.masks[1])
+			p[2] = component(v, h.masks[2])
+			p[3] = 0xff
+			if h.hasAlpha() {
+				p[3] = component(v, h.masks[3])
+			}
+		}
+	}
+
+	for i := 0; i < h.height; i++ {
+		if _, err := io.ReadFull(br, row); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		// 通常の BMP は下の行から順に並んでいる。
+		y := h.height - 1 - i
+		if h.topDown {
+			y = i
+		}
+		for x := 0; x < h.width; x++ {
+			set(x, y)
+		}
+	}
+
+	return img, nil
+}
+
+// component extracts the bits of mask from v and scales them to 8 bits.
+func component(v, mask uint32) uint8 {
+	if mask == 0 {
+		return 0
+	}
+	shift := uint(bits.TrailingZeros32(mask))
+	n := uint(bits.OnesCount32(mask))
+	c := (v & mask) >> shift
+	max := uint32(1)<<n - 1
+	return uint8((c*0xff + max/2) / max)
+}
+
+// Encode writes the image m to w in BMP format.
+// Paletted and gray images are written with 8 bits per pixel, opaque images with 24 bits
+// and the others with 32 bits and an alpha mask in a BITMAPV4HEADER.
+func Encode(w io.Writer, m image.Image) error {
+	b := m.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 {
+		return errors.New("bmp: image is empty")
+	}
+
+	var palette color.Palette
+	bpp, headerSize := 24, infoHeaderSize
+	switch img := m.(type) {
+	case *image.Paletted:
+		if opaquePalette(img.Palette) && len(img.Palette) <= 256 {
+			palette, bpp = img.Palette, 8
+		}
+	case *image.Gray:
+		palette, bpp = make(color.Palette, 256), 8
+		for i := range palette {
+			palette[i] = color.Gray{uint8(i)}
+		}
+	}
+	if bpp == 24 {
+		if o, ok := m.(interface{ Opaque() bool }); !ok || !o.Opaque() {
+			bpp, headerSize = 32, v4HeaderSize
+		}
+	}
+
+	stride := (b.Dx()*bpp + 31) / 32 * 4
+	offset := fileHeaderSize + headerSize + 4*len(palette)
+	compression := uint32(biRGB)
+	if bpp == 32 {
+		compression = biBitFields
+	}
+
+	hdr := make([]byte, offset)
+	copy(hdr, "BM")
+	binary.LittleEndian.PutUint32(hdr[2:], uint32(offset+stride*b.Dy()))
+	binary.LittleEndian.PutUint32(hdr[10:], uint32(offset))
+	info := hdr[fileHeaderSize:]
+	binary.LittleEndian.PutUint32(info[0:], uint32(headerSize))
+	binary.LittleEndian.PutUint32(info[4:], uint32(b.Dx()))
+	binary.LittleEndian.PutUint32(info[8:], uint32(b.Dy()))
+	binary.LittleEndian.PutUint16(info[12:], 1)
+	binary.LittleEndian.PutUint16(info[14:], uint16(bpp))
+	binary.LittleEndian.PutUint32(info[16:], compression)
+	binary.LittleEndian.PutUint32(info[20:], uint32(stride*b.Dy()))
+	// 72 dpi
+	binary.LittleEndian.PutUint32(info[24:], 2835)
+	binary.LittleEndian.PutUint32(info[28:], 2835)
+	binary.LittleEndian.PutUint32(info[32:], uint32(len(palette)))
+	if headerSize == v4HeaderSize {
+		binary.LittleEndian.PutUint32(info[40:], 0x00ff0000)
+		binary.LittleEndian.PutUint32(info[44:], 0x0000ff00)
+		binary.LittleEndian.PutUint32(info[48:], 0x000000ff)
+		binary.LittleEndian.PutUint32(info[52:], 0xff000000)
+		// LCS_sRGB
+		copy(info[56:], "BGRs")
+	}
+	for i, c := range palette {
+		r, g, bl, _ := c.RGBA()
+		p := info[headerSize+4*i:]
+		p[0], p[1], p[2] = uint8(bl>>8), uint8(g>>8), uint8(r>>8)
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.Write(hdr)
+	row := make([]byte, stride)
+	for y := b.Max.Y - 1; y >= b.Min.Y; y-- {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := x - b.Min.X
+			switch bpp {
+			case 8:
+				if p, ok := m.(*image.Paletted); ok {
+					row[i] = p.ColorIndexAt(x, y)
+				} else {
+					row[i] = color.GrayModel.Convert(m.At(x, y)).(color.Gray).Y
+				}
+			case 24:
+				c := color.RGBAModel.Convert(m.At(x, y)).(color.RGBA)
+				row[3*i], row[3*i+1], row[3*i+2] = c.B, c.G, c.R
+			case 32:
+				var c color.NRGBA
+				if n, ok := m.(*image.NRGBA); ok {
+					c = n.NRGBAAt(x, y)
+				} else {
+					c = color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+				}
+				row[4*i], row[4*i+1], row[4*i+2], row[4*i+3] = c.B, c.G, c.R, c.A
+			}
+		}
+		if _, err := bw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+func opaquePalette(p color.Palette) bool {
+	for _, c := range p {
+		if _, _, _, a := c.RGBA(); a != 0xffff {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	image.RegisterFormat("bmp", "BM????\x00\x00\x00\x00", Decode, DecodeConfig)
+}
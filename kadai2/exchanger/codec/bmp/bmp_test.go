@@ -0,0 +1,103 @@
+package bmp
+
+import (
+	"bytes"
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	rect := image.Rect(0, 0, 13, 7)
+	rgba := image.NewRGBA(rect)
+	nrgba := image.NewNRGBA(rect)
+	gray := image.NewGray(rect)
+	paletted := image.NewPaletted(rect, color.Palette{
+		color.RGBA{0, 0, 0, 0xff}, color.RGBA{0xff, 0x80, 0, 0xff}, color.RGBA{10, 20, 30, 0xff},
+	})
+	for y := 0; y < 7; y++ {
+		for x := 0; x < 13; x++ {
+			rgba.SetRGBA(x, y, color.RGBA{uint8(x * 19), uint8(y * 37), uint8(x ^ y), 0xff})
+			nrgba.SetNRGBA(x, y, color.NRGBA{uint8(x * 19), uint8(y * 37), uint8(x ^ y), uint8(x * 20)})
+			gray.SetGray(x, y, color.Gray{uint8(x * y * 3)})
+			paletted.SetColorIndex(x, y, uint8((x+y)%3))
+		}
+	}
+
+	tests := []struct {
+		name string
+		img  image.Image
+		want image.Image
+	}{
+		{"24 bits", rgba, &image.RGBA{}},
+		{"32 bits with alpha", nrgba, &image.NRGBA{}},
+		{"gray", gray, &image.Paletted{}},
+		{"paletted", paletted, &image.Paletted{}},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := Encode(&buf, tt.img); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got, name, err := image.Decode(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if name != "bmp" {
+			t.Errorf("%s: format name = %s, want bmp", tt.name, name)
+		}
+		if gt, wt := typeName(got), typeName(tt.want); gt != wt {
+			t.Errorf("%s: decoded as %s, want %s", tt.name, gt, wt)
+		}
+
+		for y := 0; y < 7; y++ {
+			for x := 0; x < 13; x++ {
+				want := color.RGBA64Model.Convert(tt.img.At(x, y))
+				if c := color.RGBA64Model.Convert(got.At(x, y)); c != want {
+					t.Fatalf("%s: pixel (%d, %d) = %v, want %v", tt.name, x, y, c, want)
+				}
+			}
+		}
+	}
+}
+
+func typeName(img image.Image) string {
+	switch img.(type) {
+	case *image.RGBA:
+		return "RGBA"
+	case *image.NRGBA:
+		return "NRGBA"
+	case *image.Paletted:
+		return "Paletted"
+	}
+	return "other"
+}
+
+func TestDecodeError(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	valid := buf.Bytes()
+	rle := append([]byte{}, valid...)
+	// BI_RLE8
+	rle[fileHeaderSize+16] = 1
+
+	tests := []struct {
+		name      string
+		data      []byte
+		wantError bool
+	}{
+		{"valid", valid, false},
+		{"bad magic", append([]byte("XX"), valid[2:]...), true},
+		{"truncated", valid[:len(valid)-5], true},
+		{"rle", rle, true},
+	}
+
+	for _, tt := range tests {
+		_, err := Decode(bytes.NewReader(tt.data))
+		helper.TestWantError(t, err, tt.wantError)
+	}
+}
@@ -0,0 +1,233 @@
+// Package ico implements a decoder and an encoder for Windows ICO files.
+//
+// The encoder stores every image as PNG, which is supported since Windows Vista.
+// The decoder reads both PNG and BMP entries.
+package ico
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+
+	"gopher-dojo/kadai2/exchanger/codec/bmp"
+)
+
+const (
+	headerSize = 6
+	entrySize  = 16
+	pngMagic   = "\x89PNG\r\n\x1a\n"
+)
+
+// FormatError reports that the input is not a valid ICO file.
+type FormatError string
+
+func (e FormatError) Error() string { return "ico: invalid format: " + string(e) }
+
+type entry struct {
+	width, height int
+	data          []byte
+}
+
+func readEntries(r io.Reader) ([]entry, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < headerSize || binary.LittleEndian.Uint16(buf[0:]) != 0 || binary.LittleEndian.Uint16(buf[2:]) != 1 {
+		return nil, FormatError("bad header")
+	}
+
+	n := int(binary.LittleEndian.Uint16(buf[4:]))
+	if n == 0 || len(buf) < headerSize+n*entrySize {
+		return nil, FormatError("bad directory")
+	}
+	entries := make([]entry, n)
+	for i := range entries {
+		e := buf[headerSize+i*entrySize:]
+		size := int64(binary.LittleEndian.Uint32(e[8:]))
+		offset := int64(binary.LittleEndian.Uint32(e[12:]))
+		if offset+size > int64(len(buf)) {
+			return nil, FormatError("entry out of range")
+		}
+		// 0 は 256 を表す。
+		entries[i] = entry{width: int(e[0]), height: int(e[1]), data: buf[offset : offset+size]}
+		if entries[i].width == 0 {
+			entries[i].width = 256
+		}
+		if entries[i].height == 0 {
+			entries[i].height = 256
+		}
+	}
+
+	return entries, nil
+}
+
+// largest returns the index of the entry with the most pixels.
+func largest(entries []entry) int {
+	best := 0
+	for i, e := range entries {
+		if e.width*e.height > entries[best].width*entries[best].height {
+			best = i
+		}
+	}
+	return best
+}
+
+// DecodeConfig returns the color model and dimensions of the largest image in an ICO file.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	entries, err := readEntries(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	e := entries[largest(entries)]
+	if bytes.HasPrefix(e.data, []byte(pngMagic)) {
+		return png.DecodeConfig(bytes.NewReader(e.data))
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: e.width, Height: e.height}, nil
+}
+
+// Decode reads the largest image in an ICO file.
+func Decode(r io.Reader) (image.Image, error) {
+	entries, err := readEntries(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeEntry(entries[largest(entries)])
+}
+
+// DecodeAll reads all the images in an ICO file in the order of the directory.
+func DecodeAll(r io.Reader) ([]image.Image, error) {
+	entries, err := readEntries(r)
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]image.Image, len(entries))
+	for i, e := range entries {
+		if images[i], err = decodeEntry(e); err != nil {
+			return nil, err
+		}
+	}
+	return images, nil
+}
+
+func decodeEntry(e entry) (image.Image, error) {
+	if bytes.HasPrefix(e.data, []byte(pngMagic)) {
+		return png.Decode(bytes.NewReader(e.data))
+	}
+	return decodeDIB(e.data)
+}
+
+// decodeDIB decodes a BMP entry, which has no file header and is followed by
+// the 1-bit AND mask. Its height in the header is doubled to include the mask.
+func decodeDIB(dib []byte) (image.Image, error) {
+	if len(dib) < 40 {
+		return nil, FormatError("short bitmap")
+	}
+	headerLen := int(binary.LittleEndian.Uint32(dib[0:]))
+	width := int(int32(binary.LittleEndian.Uint32(dib[4:])))
+	height := int(int32(binary.LittleEndian.Uint32(dib[8:]))) / 2
+	bpp := int(binary.LittleEndian.Uint16(dib[14:]))
+	colors := int(binary.LittleEndian.Uint32(dib[32:]))
+	if width <= 0 || height <= 0 || headerLen < 40 || headerLen > len(dib) {
+		return nil, FormatError("bad bitmap header")
+	}
+	if bpp <= 8 && colors == 0 {
+		colors = 1 << uint(bpp)
+	}
+	if bpp > 8 {
+		colors = 0
+	}
+
+	// BMP ファイルとしてデコードできるよう、ファイルヘッダを付けて高さを半分にする。
+	offset := 14 + headerLen + 4*colors
+	file := make([]byte, 14, 14+len(dib))
+	copy(file, "BM")
+	binary.LittleEndian.PutUint32(file[2:], uint32(14+len(dib)))
+	binary.LittleEndian.PutUint32(file[10:], uint32(offset))
+	file = append(file, dib...)
+	binary.LittleEndian.PutUint32(file[14+8:], uint32(height))
+
+	img, err := bmp.Decode(bytes.NewReader(file))
+	if err != nil {
+		return nil, err
+	}
+
+	dst := image.NewNRGBA(img.Bounds())
+	stride := (width*bpp + 31) / 32 * 4
+	xor := file[offset:]
+	andStride := (width + 31) / 32 * 4
+	and := xor[stride*height:]
+	for y := 0; y < height; y++ {
+		row := height - 1 - y
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if bpp == 32 {
+				// 32 ビットの場合は4バイト目がアルファ。
+				c.A = xor[row*stride+x*4+3]
+			} else if len(and) >= andStride*height && and[row*andStride+x/8]&(0x80>>uint(x%8)) != 0 {
+				c.A = 0
+			}
+			dst.SetNRGBA(x, y, c)
+		}
+	}
+
+	return dst, nil
+}
+
+// Encode writes the images to w as an ICO file. Each image must be at most 256x256
+// and is stored as PNG.
+func Encode(w io.Writer, images []image.Image) error {
+	if len(images) == 0 {
+		return errors.New("ico: no images")
+	}
+
+	var data [][]byte
+	for _, img := range images {
+		s := img.Bounds().Size()
+		if s.X <= 0 || s.Y <= 0 || s.X > 256 || s.Y > 256 {
+			return errors.New("ico: image must be between 1x1 and 256x256")
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return err
+		}
+		data = append(data, buf.Bytes())
+	}
+
+	dir := make([]byte, headerSize+len(images)*entrySize)
+	binary.LittleEndian.PutUint16(dir[2:], 1)
+	binary.LittleEndian.PutUint16(dir[4:], uint16(len(images)))
+	offset := len(dir)
+	for i, img := range images {
+		s := img.Bounds().Size()
+		e := dir[headerSize+i*entrySize:]
+		e[0], e[1] = uint8(s.X), uint8(s.Y) // 256 は 0 になる。
+		binary.LittleEndian.PutUint16(e[4:], 1)
+		binary.LittleEndian.PutUint16(e[6:], 32)
+		binary.LittleEndian.PutUint32(e[8:], uint32(len(data[i])))
+		binary.LittleEndian.PutUint32(e[12:], uint32(offset))
+		offset += len(data[i])
+	}
+
+	if _, err := w.Write(dir); err != nil {
+		return err
+	}
+	for _, d := range data {
+		if _, err := w.Write(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	image.RegisterFormat("ico", "\x00\x00\x01\x00", Decode, DecodeConfig)
+}
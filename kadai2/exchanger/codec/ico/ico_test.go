@@ -0,0 +1,141 @@
+package ico
+
+import (
+	"bytes"
+	"encoding/binary"
+	"gopher-dojo/kadai2/exchanger/codec/bmp"
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	sizes := []int{16, 32, 48, 256}
+	var images []image.Image
+	for _, s := range sizes {
+		images = append(images, helper.Gradient(s, s))
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, images); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	all, err := DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != len(sizes) {
+		t.Fatalf("decoded %d images, want %d", len(all), len(sizes))
+	}
+	for i, img := range all {
+		if w := img.Bounds().Dx(); w != sizes[i] {
+			t.Errorf("image %d: width = %d, want %d", i, w, sizes[i])
+		}
+	}
+
+	// image.Decode は最大のエントリを返す。
+	img, name, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "ico" {
+		t.Errorf("format name = %s, want ico", name)
+	}
+	want := images[len(images)-1]
+	for _, p := range []image.Point{{0, 0}, {255, 0}, {100, 200}} {
+		if c, w := color.NRGBAModel.Convert(img.At(p.X, p.Y)), want.At(p.X, p.Y); c != w {
+			t.Errorf("pixel %v = %v, want %v", p, c, w)
+		}
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 256 || cfg.Height != 256 {
+		t.Errorf("config = %dx%d, want 256x256", cfg.Width, cfg.Height)
+	}
+}
+
+// dibIcon は BMP エンコーダの出力から BMP エントリ1つの ICO を作る。
+func dibIcon(t *testing.T, img image.Image, mask []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := bmp.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	dib := buf.Bytes()[14:]
+	s := img.Bounds().Size()
+	binary.LittleEndian.PutUint32(dib[8:], uint32(2*s.Y))
+	dib = append(dib, mask...)
+
+	ico := make([]byte, headerSize+entrySize)
+	binary.LittleEndian.PutUint16(ico[2:], 1)
+	binary.LittleEndian.PutUint16(ico[4:], 1)
+	ico[6], ico[7] = uint8(s.X), uint8(s.Y)
+	binary.LittleEndian.PutUint32(ico[6+8:], uint32(len(dib)))
+	binary.LittleEndian.PutUint32(ico[6+12:], uint32(len(ico)))
+	return append(ico, dib...)
+}
+
+func TestDecodeDIB(t *testing.T) {
+	opaque := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for i := range opaque.Pix {
+		opaque.Pix[i] = 0xff
+	}
+	// 各行4バイト境界。上の行 (最後に格納される) の左端だけ透明にする。
+	mask := []byte{0, 0, 0, 0, 0x80, 0, 0, 0}
+
+	img, err := Decode(bytes.NewReader(dibIcon(t, opaque, mask)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := color.NRGBAModel.Convert(img.At(0, 0)).(color.NRGBA).A; a != 0 {
+		t.Errorf("masked pixel alpha = %d, want 0", a)
+	}
+	if a := color.NRGBAModel.Convert(img.At(1, 0)).(color.NRGBA).A; a != 0xff {
+		t.Errorf("unmasked pixel alpha = %d, want 255", a)
+	}
+	if a := color.NRGBAModel.Convert(img.At(0, 1)).(color.NRGBA).A; a != 0xff {
+		t.Errorf("unmasked pixel alpha = %d, want 255", a)
+	}
+}
+
+func TestEncodeError(t *testing.T) {
+	tests := []struct {
+		name   string
+		images []image.Image
+	}{
+		{"no images", nil},
+		{"too large", []image.Image{image.NewNRGBA(image.Rect(0, 0, 257, 16))}},
+		{"empty", []image.Image{image.NewNRGBA(image.Rect(0, 0, 0, 0))}},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := Encode(&buf, tt.images); err == nil {
+			t.Errorf("%s: want error", tt.name)
+		}
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"cursor", []byte{0, 0, 2, 0, 1, 0}},
+		{"no entries", []byte{0, 0, 1, 0, 0, 0}},
+		{"out of range", []byte{0, 0, 1, 0, 1, 0, 16, 16, 0, 0, 1, 0, 32, 0, 0xff, 0, 0, 0, 22, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		if _, err := Decode(bytes.NewReader(tt.data)); err == nil {
+			t.Errorf("%s: want error", tt.name)
+		}
+	}
+}
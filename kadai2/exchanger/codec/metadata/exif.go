@@ -0,0 +1,210 @@
+package metadata
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+const (
+	tagCopyright  = 0x8298
+	tagGPSPointer = 0x8825
+
+	typeASCII = 2
+	typeLong  = 4
+	typeIFD   = 13
+)
+
+// typeSizes are the sizes of the TIFF field types.
+var typeSizes = map[uint16]uint32{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 13: 4,
+}
+
+type tiff struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+func parseTIFF(data []byte) (*tiff, bool) {
+	if len(data) < 8 {
+		return nil, false
+	}
+	t := &tiff{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, false
+	}
+	if t.order.Uint16(data[2:]) != 42 {
+		return nil, false
+	}
+
+	return t, true
+}
+
+// entry is a field of an IFD. at is the offset of the entry in the TIFF structure.
+type entry struct {
+	at         uint32
+	tag, typ   uint16
+	count      uint32
+	valueStart uint32
+	size       uint32
+}
+
+// ifd returns the entries of the IFD at offset, which are all within the data.
+func (t *tiff) ifd(offset uint32) []entry {
+	if uint64(offset)+2 > uint64(len(t.data)) {
+		return nil
+	}
+	n := uint32(t.order.Uint16(t.data[offset:]))
+	if uint64(offset)+2+uint64(n)*12 > uint64(len(t.data)) {
+		return nil
+	}
+
+	var es []entry
+	for i := uint32(0); i < n; i++ {
+		at := offset + 2 + i*12
+		e := entry{at: at, tag: t.order.Uint16(t.data[at:]), typ: t.order.Uint16(t.data[at+2:]), count: t.order.Uint32(t.data[at+4:])}
+		size := uint64(typeSizes[e.typ]) * uint64(e.count)
+		e.valueStart = at + 8
+		if size > 4 {
+			e.valueStart = t.order.Uint32(t.data[at+8:])
+		}
+		if uint64(e.valueStart)+size > uint64(len(t.data)) {
+			continue
+		}
+		e.size = uint32(size)
+		es = append(es, e)
+	}
+
+	return es
+}
+
+func (t *tiff) ifd0() []entry {
+	return t.ifd(t.order.Uint32(t.data[4:]))
+}
+
+// exifCopyright returns the Copyright tag of IFD0 of exif. The notices of the photographer
+// and the editor, separated by NUL, are joined with "; ".
+func exifCopyright(exif []byte) string {
+	t, ok := parseTIFF(exif)
+	if !ok {
+		return ""
+	}
+	for _, e := range t.ifd0() {
+		if e.tag != tagCopyright || e.typ != typeASCII {
+			continue
+		}
+		var parts []string
+		for _, p := range strings.Split(string(t.data[e.valueStart:e.valueStart+e.size]), "\x00") {
+			if p = strings.TrimSpace(p); p != "" {
+				parts = append(parts, p)
+			}
+		}
+		return strings.Join(parts, "; ")
+	}
+
+	return ""
+}
+
+// withCopyright returns exif with the Copyright tag of IFD0 set to c. IFD0 is copied to the
+// end with the tag added or replaced, so the other offsets stay the same. A TIFF structure
+// with only the tag is returned if exif is empty or malformed.
+func withCopyright(exif []byte, c string) []byte {
+	t, ok := parseTIFF(exif)
+	if !ok {
+		t = &tiff{data: []byte("MM\x00\x2a\x00\x00\x00\x00"), order: binary.BigEndian}
+	}
+
+	// 元の IFD0 の項目を生のまま集める。
+	var raw [][]byte
+	var next []byte
+	if offset := t.order.Uint32(t.data[4:]); offset != 0 && uint64(offset)+2 <= uint64(len(t.data)) {
+		n := uint32(t.order.Uint16(t.data[offset:]))
+		if end := uint64(offset) + 2 + uint64(n)*12; end+4 <= uint64(len(t.data)) {
+			for i := uint32(0); i < n; i++ {
+				at := offset + 2 + i*12
+				if t.order.Uint16(t.data[at:]) != tagCopyright {
+					raw = append(raw, t.data[at:at+12])
+				}
+			}
+			next = t.data[end : end+4]
+		}
+	}
+
+	value := append([]byte(c), 0)
+	entry := make([]byte, 12)
+	t.order.PutUint16(entry, tagCopyright)
+	t.order.PutUint16(entry[2:], typeASCII)
+	t.order.PutUint32(entry[4:], uint32(len(value)))
+	i := 0
+	for i < len(raw) && t.order.Uint16(raw[i]) < tagCopyright {
+		i++
+	}
+	raw = append(raw[:i], append([][]byte{entry}, raw[i:]...)...)
+
+	out := append([]byte(nil), t.data...)
+	// IFD は偶数の位置から始める。
+	if len(out)%2 == 1 {
+		out = append(out, 0)
+	}
+	ifd := len(out)
+	t.order.PutUint32(out[4:], uint32(ifd))
+	out = append(out, 0, 0)
+	t.order.PutUint16(out[ifd:], uint16(len(raw)))
+	for _, e := range raw {
+		out = append(out, e...)
+	}
+	if next == nil {
+		next = make([]byte, 4)
+	}
+	out = append(out, next...)
+
+	at := ifd + 2 + i*12 + 8
+	if len(value) <= 4 {
+		copy(out[at:], value)
+	} else {
+		t.order.PutUint32(out[at:], uint32(len(out)))
+		out = append(out, value...)
+	}
+
+	return out
+}
+
+// stripGPS zeroes the entries of the GPS IFD of exif and their values, and sets the number
+// of its entries to zero, so that the offsets of the other IFDs stay the same.
+func stripGPS(exif []byte) {
+	t, ok := parseTIFF(exif)
+	if !ok {
+		return
+	}
+	for _, e := range t.ifd0() {
+		// GPS IFD へのポインタは LONG か IFD 型で書かれる。
+		if e.tag != tagGPSPointer || e.typ != typeLong && e.typ != typeIFD || e.count != 1 {
+			continue
+		}
+		offset := t.order.Uint32(t.data[e.valueStart:])
+		gps := t.ifd(offset)
+		for _, g := range gps {
+			zero(t.data[g.valueStart : g.valueStart+g.size])
+		}
+		if uint64(offset)+2 > uint64(len(t.data)) {
+			return
+		}
+		n := uint32(t.order.Uint16(t.data[offset:]))
+		end := uint64(offset) + 2 + uint64(n)*12
+		if end > uint64(len(t.data)) {
+			end = uint64(len(t.data))
+		}
+		// 項目数を 0 にすると、次の IFD へのオフセットは消した項目の 0 として読まれる。
+		zero(t.data[offset:end])
+	}
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
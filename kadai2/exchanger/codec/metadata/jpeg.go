@@ -0,0 +1,139 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+)
+
+const (
+	jpegSOI = "\xff\xd8"
+
+	markerAPP0 = 0xe0
+	markerAPP1 = 0xe1
+	markerAPP2 = 0xe2
+	markerSOS  = 0xda
+
+	exifHeader = "Exif\x00\x00"
+	iccHeader  = "ICC_PROFILE\x00"
+	// セグメントの長さは 2 バイトで、長さ自身を含む。
+	maxSegment = 0xffff - 2
+)
+
+type segment struct {
+	marker byte
+	// start と end はセグメント全体の範囲、payload はマーカーと長さの後ろ。
+	start, end int
+	payload    []byte
+}
+
+// segments returns the segments of the JPEG file data before the first scan.
+func segments(data []byte) ([]segment, error) {
+	var segs []segment
+	for i := len(jpegSOI); ; {
+		if i+4 > len(data) {
+			return nil, FormatError("JPEG segment is truncated")
+		}
+		if data[i] != 0xff {
+			return nil, FormatError("JPEG marker is missing")
+		}
+		marker := data[i+1]
+		if marker == 0xff {
+			// 詰め物の 0xff は読み飛ばす。
+			i++
+			continue
+		}
+		if marker == markerSOS {
+			return segs, nil
+		}
+		n := int(binary.BigEndian.Uint16(data[i+2:]))
+		if n < 2 || i+2+n > len(data) {
+			return nil, FormatError("JPEG segment length is out of range")
+		}
+		segs = append(segs, segment{marker: marker, start: i, end: i + 2 + n, payload: data[i+4 : i+2+n]})
+		i += 2 + n
+	}
+}
+
+func readJPEG(data []byte) (*Metadata, error) {
+	segs, err := segments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Metadata{}
+	type chunk struct {
+		seq  byte
+		data []byte
+	}
+	var chunks []chunk
+	for _, s := range segs {
+		switch {
+		case s.marker == markerAPP1 && bytes.HasPrefix(s.payload, []byte(exifHeader)) && m.EXIF == nil:
+			m.EXIF = append([]byte(nil), s.payload[len(exifHeader):]...)
+		case s.marker == markerAPP2 && bytes.HasPrefix(s.payload, []byte(iccHeader)) && len(s.payload) >= len(iccHeader)+2:
+			chunks = append(chunks, chunk{s.payload[len(iccHeader)], s.payload[len(iccHeader)+2:]})
+		}
+	}
+
+	// ICC プロファイルは複数のセグメントに分かれていることがあるので、番号順につなぐ。
+	sort.SliceStable(chunks, func(i, j int) bool { return chunks[i].seq < chunks[j].seq })
+	for _, c := range chunks {
+		m.ICC = append(m.ICC, c.data...)
+	}
+
+	return m, nil
+}
+
+// InsertJPEG returns the JPEG file data with the ICC profile and the EXIF of m inserted after
+// the JFIF segment, or after the start of the image if there is none. The EXIF is left out
+// if it does not fit in a segment. The Copyright text of m is written to the Copyright tag of
+// the EXIF if they differ, and the other text is not written.
+func InsertJPEG(data []byte, m *Metadata) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(jpegSOI)) {
+		return nil, FormatError("not a JPEG file")
+	}
+	segs, err := segments(data)
+	if err != nil {
+		return nil, err
+	}
+	at := len(jpegSOI)
+	if len(segs) > 0 && segs[0].marker == markerAPP0 {
+		at = segs[0].end
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data[:at])
+	exif := m.EXIF
+	if c := m.Text["Copyright"]; c != "" && c != exifCopyright(exif) {
+		exif = withCopyright(exif, c)
+	}
+	if len(exif) > 0 && len(exifHeader)+len(exif) <= maxSegment {
+		writeSegment(&buf, markerAPP1, []byte(exifHeader), exif)
+	}
+	const chunkSize = maxSegment - len(iccHeader) - 2
+	count := (len(m.ICC) + chunkSize - 1) / chunkSize
+	if count <= 255 {
+		for i := 0; i < count; i++ {
+			end := (i + 1) * chunkSize
+			if end > len(m.ICC) {
+				end = len(m.ICC)
+			}
+			writeSegment(&buf, markerAPP2, []byte(iccHeader), []byte{byte(i + 1), byte(count)}, m.ICC[i*chunkSize:end])
+		}
+	}
+	buf.Write(data[at:])
+
+	return buf.Bytes(), nil
+}
+
+func writeSegment(buf *bytes.Buffer, marker byte, parts ...[]byte) {
+	n := 2
+	for _, p := range parts {
+		n += len(p)
+	}
+	buf.Write([]byte{0xff, marker, byte(n >> 8), byte(n)})
+	for _, p := range parts {
+		buf.Write(p)
+	}
+}
@@ -0,0 +1,69 @@
+// Package metadata reads and writes the metadata of JPEG and PNG files without decoding
+// the images: ICC profiles, EXIF and PNG text chunks.
+//
+// EXIF is kept as the TIFF structure stored in the files, and only its GPS tags and
+// copyright are interpreted.
+package metadata
+
+import (
+	"bytes"
+	"sort"
+)
+
+// FormatError reports that the metadata of a file is malformed.
+type FormatError string
+
+func (e FormatError) Error() string { return "metadata: invalid format: " + string(e) }
+
+// Metadata is the metadata of an image file.
+type Metadata struct {
+	// ICC is the ICC color profile.
+	ICC []byte
+	// EXIF is the TIFF structure of the EXIF tags, without the "Exif\x00\x00" header of JPEG.
+	EXIF []byte
+	// Text are the PNG text chunks by their keywords, such as "Copyright".
+	Text map[string]string
+}
+
+// Read returns the metadata of a JPEG or PNG file. The other formats have no metadata.
+func Read(data []byte) (*Metadata, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte(jpegSOI)):
+		return readJPEG(data)
+	case bytes.HasPrefix(data, []byte(pngSignature)):
+		return readPNG(data)
+	}
+
+	return &Metadata{}, nil
+}
+
+// Copyright returns the copyright of the EXIF, or the Copyright text if the EXIF has none.
+func (m *Metadata) Copyright() string {
+	if c := exifCopyright(m.EXIF); c != "" {
+		return c
+	}
+	return m.Text["Copyright"]
+}
+
+// StripGPS removes the GPS tags from the EXIF in place, leaving an empty GPS directory.
+func (m *Metadata) StripGPS() {
+	stripGPS(m.EXIF)
+}
+
+// ICCColorSpace returns the data color space in the header of the ICC profile icc,
+// such as "RGB", "GRAY" or "CMYK", or an empty string if icc is too short.
+func ICCColorSpace(icc []byte) string {
+	if len(icc) < 20 {
+		return ""
+	}
+	return string(bytes.TrimRight(icc[16:20], " "))
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,239 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// testEXIF returns a TIFF structure with the copyright in IFD0 and a GPS IFD
+// whose latitude is stored out of line.
+func testEXIF(order binary.ByteOrder) []byte {
+	return testEXIFWithPointer(order, typeLong)
+}
+
+// testEXIFWithPointer returns testEXIF with the pointer to the GPS IFD of the type typ.
+func testEXIFWithPointer(order binary.ByteOrder, typ uint16) []byte {
+	b := make([]byte, 108)
+	if order == binary.LittleEndian {
+		copy(b, "II")
+	} else {
+		copy(b, "MM")
+	}
+	order.PutUint16(b[2:], 42)
+	order.PutUint32(b[4:], 8)
+
+	put := func(at int, tag, typ uint16, count, value uint32) {
+		order.PutUint16(b[at:], tag)
+		order.PutUint16(b[at+2:], typ)
+		order.PutUint32(b[at+4:], count)
+		order.PutUint32(b[at+8:], value)
+	}
+	order.PutUint16(b[8:], 2)
+	put(10, tagCopyright, typeASCII, 16, 68)
+	put(22, tagGPSPointer, typ, 1, 38)
+	order.PutUint16(b[38:], 2)
+	// GPSLatitudeRef は値に収まるので、その場に書く。
+	put(40, 1, typeASCII, 2, 0)
+	copy(b[48:], "N\x00")
+	put(52, 2, 5, 3, 84)
+	copy(b[68:], "Jane Doe\x00Editor\x00")
+	for i := 84; i < 108; i++ {
+		b[i] = 0x11
+	}
+
+	return b
+}
+
+func TestJPEG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, helper.Gradient(8, 8), nil); err != nil {
+		t.Fatal(err)
+	}
+	// 2 つのセグメントに分かれる大きさのプロファイル
+	icc := make([]byte, 70000)
+	copy(icc[16:], "RGB ")
+	for i := 20; i < len(icc); i++ {
+		icc[i] = byte(i)
+	}
+
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		data, err := InsertJPEG(buf.Bytes(), &Metadata{ICC: icc, EXIF: testEXIF(order), Text: map[string]string{"Source": "a.png"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+			t.Fatalf("%v: decode: %v", order, err)
+		}
+
+		m, err := Read(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(m.ICC, icc) || ICCColorSpace(m.ICC) != "RGB" {
+			t.Errorf("%v: ICC of %d bytes is read as %d bytes", order, len(icc), len(m.ICC))
+		}
+		if got := m.Copyright(); got != "Jane Doe; Editor" {
+			t.Errorf("%v: Copyright = %q", order, got)
+		}
+		if m.Text != nil {
+			t.Errorf("%v: JPEG has text %v", order, m.Text)
+		}
+
+		m.StripGPS()
+		if got := order.Uint16(m.EXIF[38:]); got != 0 {
+			t.Errorf("%v: GPS IFD has %d entries after StripGPS", order, got)
+		}
+		if !bytes.Equal(m.EXIF[40:68], make([]byte, 28)) || !bytes.Equal(m.EXIF[84:108], make([]byte, 24)) {
+			t.Errorf("%v: GPS entries or values are left: % x", order, m.EXIF[40:108])
+		}
+		if got := m.Copyright(); got != "Jane Doe; Editor" {
+			t.Errorf("%v: Copyright after StripGPS = %q", order, got)
+		}
+	}
+}
+
+func TestJPEGCopyright(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, helper.Gradient(8, 8), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		exif []byte
+		text string
+		want string
+	}{
+		{"no EXIF", nil, "© ACME", "© ACME"},
+		{"inline", nil, "AB", "AB"},
+		{"replace", testEXIF(binary.LittleEndian), "© ACME", "© ACME"},
+		{"replace big endian", testEXIF(binary.BigEndian), "© ACME", "© ACME"},
+		{"same", testEXIF(binary.BigEndian), "Jane Doe; Editor", "Jane Doe; Editor"},
+		{"no text", testEXIF(binary.BigEndian), "", "Jane Doe; Editor"},
+		{"malformed EXIF", []byte("XX"), "© ACME", "© ACME"},
+	}
+
+	for _, tt := range tests {
+		var text map[string]string
+		if tt.text != "" {
+			text = map[string]string{"Copyright": tt.text}
+		}
+		data, err := InsertJPEG(buf.Bytes(), &Metadata{EXIF: tt.exif, Text: text})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		m, err := Read(data)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := m.Copyright(); got != tt.want {
+			t.Errorf("%s: Copyright = %q, want %q", tt.name, got, tt.want)
+		}
+
+		// 書き直した IFD0 からも GPS IFD をたどれる。
+		if len(tt.exif) == 108 {
+			m.StripGPS()
+			if !bytes.Equal(m.EXIF[84:108], make([]byte, 24)) {
+				t.Errorf("%s: GPS values are left: % x", tt.name, m.EXIF[84:108])
+			}
+		}
+	}
+}
+
+func TestPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, helper.Gradient(8, 8)); err != nil {
+		t.Fatal(err)
+	}
+	want := &Metadata{
+		ICC:  []byte("0123456789abcdefGRAYprofile"),
+		EXIF: testEXIF(binary.BigEndian),
+		Text: map[string]string{"Copyright": "© Jane Doe", "Title": "日本語のタイトル"},
+	}
+
+	data, err := InsertPNG(buf.Bytes(), want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for _, typ := range []string{"iCCP", "eXIf", "tEXt", "iTXt"} {
+		if !bytes.Contains(data, []byte(typ)) {
+			t.Errorf("%s chunk is not written", typ)
+		}
+	}
+
+	got, err := Read(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.ICC, want.ICC) || ICCColorSpace(got.ICC) != "GRAY" || !bytes.Equal(got.EXIF, want.EXIF) {
+		t.Errorf("ICC = %q, EXIF = % x", got.ICC, got.EXIF)
+	}
+	for k, v := range want.Text {
+		if got.Text[k] != v {
+			t.Errorf("text %s = %q, want %q", k, got.Text[k], v)
+		}
+	}
+	// EXIF の著作権が優先される。
+	if c := got.Copyright(); c != "Jane Doe; Editor" {
+		t.Errorf("Copyright = %q", c)
+	}
+
+	for _, key := range []string{"キー", "", " Title", "Two  spaces", "Tab\t", strings.Repeat("k", 80)} {
+		if _, err := InsertPNG(buf.Bytes(), &Metadata{Text: map[string]string{key: "v"}}); err == nil {
+			t.Errorf("InsertPNG accepts the keyword %q", key)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  bool
+	}{
+		{"other format", "GIF89a", false},
+		{"truncated JPEG", "\xff\xd8\xff\xe1\x00\x10Exif", true},
+		{"JPEG without marker", "\xff\xd8\x00\x00\x00\x00", true},
+		{"truncated PNG", pngSignature + "\x00\x00\x00\x10IHDR", true},
+	}
+
+	for _, tt := range tests {
+		m, err := Read([]byte(tt.data))
+		helper.TestWantError(t, err, tt.err)
+		if err == nil && (m.ICC != nil || m.EXIF != nil || m.Text != nil) {
+			t.Errorf("%s: metadata = %+v", tt.name, m)
+		}
+	}
+}
+
+func TestStripGPS(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  uint16
+		// stripped は GPS IFD が空になることを表す。
+		stripped bool
+	}{
+		{"LONG", typeLong, true},
+		{"IFD", typeIFD, true},
+		{"SHORT", 3, false},
+	}
+
+	for _, tt := range tests {
+		m := &Metadata{EXIF: testEXIFWithPointer(binary.LittleEndian, tt.typ)}
+		m.StripGPS()
+		if got := bytes.Equal(m.EXIF[84:108], make([]byte, 24)); got != tt.stripped {
+			t.Errorf("%s: GPS values stripped = %v, want %v", tt.name, got, tt.stripped)
+		}
+		if got := m.Copyright(); got != "Jane Doe; Editor" {
+			t.Errorf("%s: Copyright after StripGPS = %q", tt.name, got)
+		}
+	}
+}
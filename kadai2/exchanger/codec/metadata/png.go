@@ -0,0 +1,224 @@
+package metadata
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"strings"
+	"unicode/utf8"
+)
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+type chunk struct {
+	typ  string
+	data []byte
+	// end は CRC を含むチャンクの終わり。
+	end int
+}
+
+// chunks returns the chunks of the PNG file data.
+func chunks(data []byte) ([]chunk, error) {
+	var cs []chunk
+	for i := len(pngSignature); i < len(data); {
+		if i+8 > len(data) {
+			return nil, FormatError("PNG chunk is truncated")
+		}
+		n := binary.BigEndian.Uint32(data[i:])
+		if uint64(i)+12+uint64(n) > uint64(len(data)) {
+			return nil, FormatError("PNG chunk length is out of range")
+		}
+		end := i + 12 + int(n)
+		cs = append(cs, chunk{typ: string(data[i+4 : i+8]), data: data[i+8 : end-4], end: end})
+		i = end
+	}
+
+	return cs, nil
+}
+
+func readPNG(data []byte) (*Metadata, error) {
+	cs, err := chunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Metadata{}
+	for _, c := range cs {
+		switch c.typ {
+		case "iCCP":
+			// プロファイル名、圧縮方式の後に zlib で圧縮したプロファイルが続く。
+			i := bytes.IndexByte(c.data, 0)
+			if i < 0 || i+2 > len(c.data) {
+				return nil, FormatError("iCCP chunk is malformed")
+			}
+			if m.ICC, err = inflate(c.data[i+2:]); err != nil {
+				return nil, FormatError("iCCP profile cannot be decompressed")
+			}
+		case "eXIf":
+			m.EXIF = append([]byte(nil), c.data...)
+		case "tEXt", "zTXt", "iTXt":
+			key, text, ok := readText(c.typ, c.data)
+			if !ok {
+				continue
+			}
+			if m.Text == nil {
+				m.Text = map[string]string{}
+			}
+			m.Text[key] = text
+		}
+	}
+
+	return m, nil
+}
+
+// readText returns the keyword and the UTF-8 text of a text chunk of typ.
+// ok is false if the chunk is malformed.
+func readText(typ string, data []byte) (key, text string, ok bool) {
+	i := bytes.IndexByte(data, 0)
+	if i < 1 {
+		return "", "", false
+	}
+	key, rest := latin1(data[:i]), data[i+1:]
+
+	switch typ {
+	case "tEXt":
+		return key, latin1(rest), true
+	case "zTXt":
+		if len(rest) < 1 {
+			return "", "", false
+		}
+		b, err := inflate(rest[1:])
+		return key, latin1(b), err == nil
+	}
+
+	// iTXt: 圧縮フラグ、圧縮方式、言語タグ、翻訳したキーワード、本文の順に並ぶ。
+	if len(rest) < 2 {
+		return "", "", false
+	}
+	compressed := rest[0] == 1
+	rest = rest[2:]
+	for n := 0; n < 2; n++ {
+		j := bytes.IndexByte(rest, 0)
+		if j < 0 {
+			return "", "", false
+		}
+		rest = rest[j+1:]
+	}
+	if compressed {
+		b, err := inflate(rest)
+		if err != nil {
+			return "", "", false
+		}
+		rest = b
+	}
+	return key, string(rest), utf8.Valid(rest)
+}
+
+// InsertPNG returns the PNG file data with the ICC profile, the EXIF and the text of m
+// inserted after the header. Text which is not Latin-1 is written in iTXt chunks.
+func InsertPNG(data []byte, m *Metadata) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(pngSignature)) {
+		return nil, FormatError("not a PNG file")
+	}
+	cs, err := chunks(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(cs) == 0 || cs[0].typ != "IHDR" {
+		return nil, FormatError("PNG header is missing")
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data[:cs[0].end])
+	if len(m.ICC) > 0 {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(m.ICC)
+		zw.Close()
+		writeChunk(&buf, "iCCP", []byte("ICC Profile\x00\x00"), z.Bytes())
+	}
+	if len(m.EXIF) > 0 {
+		writeChunk(&buf, "eXIf", m.EXIF)
+	}
+	for _, key := range sortedKeys(m.Text) {
+		if !ValidKeyword(key) {
+			return nil, FormatError("PNG text keyword must be 1 to 79 Latin-1 characters: " + key)
+		}
+		k, _ := toLatin1(key)
+		if t, ok := toLatin1(m.Text[key]); ok {
+			writeChunk(&buf, "tEXt", k, []byte{0}, t)
+		} else {
+			// iTXt のキーワードの後は、非圧縮、言語タグと翻訳したキーワードは空。
+			writeChunk(&buf, "iTXt", k, []byte{0, 0, 0, 0, 0}, []byte(m.Text[key]))
+		}
+	}
+	buf.Write(data[cs[0].end:])
+
+	return buf.Bytes(), nil
+}
+
+// ValidKeyword reports whether key can be the keyword of a PNG text chunk:
+// 1 to 79 printable Latin-1 characters without leading, trailing or consecutive spaces.
+func ValidKeyword(key string) bool {
+	b, ok := toLatin1(key)
+	if !ok || len(b) < 1 || len(b) > 79 || b[0] == ' ' || b[len(b)-1] == ' ' || strings.Contains(key, "  ") {
+		return false
+	}
+	for _, c := range b {
+		if c < 0x20 || c > 0x7e && c < 0xa1 {
+			return false
+		}
+	}
+	return true
+}
+
+func writeChunk(buf *bytes.Buffer, typ string, parts ...[]byte) {
+	n := 0
+	for _, p := range parts {
+		n += len(p)
+	}
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(n))
+	buf.Write(b[:])
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	buf.WriteString(typ)
+	for _, p := range parts {
+		crc.Write(p)
+		buf.Write(p)
+	}
+	binary.BigEndian.PutUint32(b[:], crc.Sum32())
+	buf.Write(b[:])
+}
+
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// latin1 converts Latin-1 bytes to UTF-8.
+func latin1(b []byte) string {
+	rs := make([]rune, len(b))
+	for i, c := range b {
+		rs[i] = rune(c)
+	}
+	return string(rs)
+}
+
+// toLatin1 converts s to Latin-1. ok is false if s has other characters.
+func toLatin1(s string) (b []byte, ok bool) {
+	for _, r := range s {
+		if r > 0xff {
+			return nil, false
+		}
+		b = append(b, byte(r))
+	}
+	return b, true
+}
@@ -0,0 +1,430 @@
+// Package netpbm implements a decoder and an encoder for the Netpbm formats
+// PBM, PGM and PPM, in both their plain (P1-P3) and raw (P4-P6) variants.
+package netpbm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// Format is a Netpbm format.
+type Format int
+
+const (
+	// PPM is the portable pixmap format.
+	PPM Format = iota
+	// PGM is the portable graymap format.
+	PGM
+	// PBM is the portable bitmap format.
+	PBM
+)
+
+// 巨大なヘッダで大量のメモリを確保しないための上限。
+const maxPixels = 1 << 28
+
+// FormatError reports that the input is not a valid Netpbm image.
+type FormatError string
+
+func (e FormatError) Error() string { return "netpbm: invalid format: " + string(e) }
+
+type header struct {
+	magic  byte // '1' - '6'
+	width  int
+	height int
+	maxval int
+}
+
+func (h header) plain() bool { return h.magic <= '3' }
+
+func (h header) format() Format {
+	switch h.magic {
+	case '1', '4':
+		return PBM
+	case '2', '5':
+		return PGM
+	}
+	return PPM
+}
+
+func (h header) colorModel() color.Model {
+	switch h.format() {
+	case PBM:
+		return color.GrayModel
+	case PGM:
+		if h.maxval > 255 {
+			return color.Gray16Model
+		}
+		return color.GrayModel
+	}
+	if h.maxval > 255 {
+		return color.RGBA64Model
+	}
+	return color.RGBAModel
+}
+
+func readHeader(r *bufio.Reader) (header, error) {
+	var h header
+	var magic [2]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return h, err
+	}
+	if magic[0] != 'P' || magic[1] < '1' || magic[1] > '6' {
+		return h, FormatError("bad magic number")
+	}
+	h.magic = magic[1]
+
+	var err error
+	if h.width, err = readInt(r); err != nil {
+		return h, err
+	}
+	if h.height, err = readInt(r); err != nil {
+		return h, err
+	}
+	h.maxval = 1
+	if h.format() != PBM {
+		if h.maxval, err = readInt(r); err != nil {
+			return h, err
+		}
+	}
+	if h.width <= 0 || h.height <= 0 || int64(h.width)*int64(h.height) > maxPixels {
+		return h, FormatError("bad dimensions")
+	}
+	if h.maxval <= 0 || h.maxval > 65535 {
+		return h, FormatError("bad maxval")
+	}
+
+	// raw 形式ではヘッダの後に空白が1文字だけ入る。
+	if !h.plain() {
+		if c, err := r.ReadByte(); err != nil {
+			return h, err
+		} else if !isSpace(c) {
+			return h, FormatError("missing whitespace after header")
+		}
+	}
+
+	return h, nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+// skipSpace skips whitespace and comments and returns the next byte.
+func skipSpace(r *bufio.Reader) (byte, error) {
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if c == '#' {
+			if _, err := r.ReadString('\n'); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if !isSpace(c) {
+			return c, nil
+		}
+	}
+}
+
+func readInt(r *bufio.Reader) (int, error) {
+	c, err := skipSpace(r)
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	if c < '0' || c > '9' {
+		return 0, FormatError("expected a number")
+	}
+
+	n := 0
+	for {
+		n = n*10 + int(c-'0')
+		if n > 1<<30 {
+			return 0, FormatError("number too large")
+		}
+		c, err = r.ReadByte()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if c < '0' || c > '9' {
+			// 区切りの空白は raw 形式の判定に使うので読み戻しておく。
+			r.UnreadByte()
+			return n, nil
+		}
+	}
+}
+
+// readBit reads a single digit of a plain PBM raster, which may not be separated by whitespace.
+func readBit(r *bufio.Reader) (int, error) {
+	c, err := skipSpace(r)
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	if c != '0' && c != '1' {
+		return 0, FormatError("expected 0 or 1")
+	}
+
+	return int(c - '0'), nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// DecodeConfig returns the color model and dimensions of a Netpbm image without decoding the entire image.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	h, err := readHeader(bufio.NewReader(r))
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	return image.Config{ColorModel: h.colorModel(), Width: h.width, Height: h.height}, nil
+}
+
+// Decode reads a Netpbm image from r and returns it as an image.Image.
+// Bitmaps and graymaps are returned as *image.Gray or *image.Gray16,
+// pixmaps as *image.RGBA or *image.RGBA64 depending on the maxval.
+func Decode(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	h, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	rect := image.Rect(0, 0, h.width, h.height)
+	switch h.format() {
+	case PBM:
+		return decodePBM(br, h, image.NewGray(rect))
+	case PGM:
+		if h.maxval > 255 {
+			img := image.NewGray16(rect)
+			return img, decodeSamples(br, h, 1, func(i int, v uint16) {
+				img.Pix[2*i] = uint8(v >> 8)
+				img.Pix[2*i+1] = uint8(v)
+			})
+		}
+		img := image.NewGray(rect)
+		return img, decodeSamples(br, h, 1, func(i int, v uint16) {
+			img.Pix[i] = uint8(v >> 8)
+		})
+	}
+
+	if h.maxval > 255 {
+		img := image.NewRGBA64(rect)
+		return img, decodeSamples(br, h, 3, func(i int, v uint16) {
+			p := i / 3 * 8
+			img.Pix[p+i%3*2] = uint8(v >> 8)
+			img.Pix[p+i%3*2+1] = uint8(v)
+			img.Pix[p+6] = 0xff
+			img.Pix[p+7] = 0xff
+		})
+	}
+	img := image.NewRGBA(rect)
+	return img, decodeSamples(br, h, 3, func(i int, v uint16) {
+		p := i / 3 * 4
+		img.Pix[p+i%3] = uint8(v >> 8)
+		img.Pix[p+3] = 0xff
+	})
+}
+
+func decodePBM(r *bufio.Reader, h header, img *image.Gray) (image.Image, error) {
+	if h.plain() {
+		for i := range img.Pix {
+			bit, err := readBit(r)
+			if err != nil {
+				return nil, err
+			}
+			// PBM では 1 が黒。
+			if bit == 0 {
+				img.Pix[i] = 0xff
+			}
+		}
+		return img, nil
+	}
+
+	row := make([]byte, (h.width+7)/8)
+	for y := 0; y < h.height; y++ {
+		if _, err := io.ReadFull(r, row); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		for x := 0; x < h.width; x++ {
+			if row[x/8]&(0x80>>uint(x%8)) == 0 {
+				img.Pix[y*img.Stride+x] = 0xff
+			}
+		}
+	}
+
+	return img, nil
+}
+
+// decodeSamples reads width*height*channels samples and passes them to set,
+// scaled to the 16-bit range.
+func decodeSamples(r *bufio.Reader, h header, channels int, set func(i int, v uint16)) error {
+	n := h.width * h.height * channels
+	wide := h.maxval > 255
+	for i := 0; i < n; i++ {
+		var v int
+		if h.plain() {
+			var err error
+			if v, err = readInt(r); err != nil {
+				return err
+			}
+		} else if wide {
+			hi, err := r.ReadByte()
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			lo, err := r.ReadByte()
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			v = int(hi)<<8 | int(lo)
+		} else {
+			c, err := r.ReadByte()
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			v = int(c)
+		}
+		if v > h.maxval {
+			return FormatError("sample exceeds maxval")
+		}
+		set(i, uint16((v*0xffff+h.maxval/2)/h.maxval))
+	}
+
+	return nil
+}
+
+// Options are the encoding parameters.
+type Options struct {
+	// Format is the format to write. The default is PPM.
+	Format Format
+	// Plain writes the ASCII variant (P1-P3) instead of the raw one (P4-P6).
+	Plain bool
+}
+
+// Encode writes the image m to w in a Netpbm format. If o is nil, it writes a raw PPM.
+// Bitmaps are written by thresholding the luminance at the middle gray.
+// Graymaps and pixmaps of images with 16-bit samples are written with the maxval 65535.
+func Encode(w io.Writer, m image.Image, o *Options) error {
+	if o == nil {
+		o = &Options{}
+	}
+
+	b := m.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 {
+		return errors.New("netpbm: image is empty")
+	}
+
+	bw := bufio.NewWriter(w)
+	magic := map[Format]int{PBM: 1, PGM: 2, PPM: 3}[o.Format]
+	if magic == 0 {
+		return fmt.Errorf("netpbm: unknown format %d", o.Format)
+	}
+	if !o.Plain {
+		magic += 3
+	}
+	fmt.Fprintf(bw, "P%d\n%d %d\n", magic, b.Dx(), b.Dy())
+	wide := o.Format != PBM && highBitDepth(m)
+	switch {
+	case wide:
+		fmt.Fprint(bw, "65535\n")
+	case o.Format != PBM:
+		fmt.Fprint(bw, "255\n")
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		var bits byte
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := m.At(x, y)
+			switch o.Format {
+			case PBM:
+				bit := byte(0)
+				if color.GrayModel.Convert(c).(color.Gray).Y < 0x80 {
+					bit = 1
+				}
+				if o.Plain {
+					bw.WriteByte('0' + bit)
+					continue
+				}
+				i := uint(x - b.Min.X)
+				bits |= bit << (7 - i%8)
+				if i%8 == 7 || x == b.Max.X-1 {
+					bw.WriteByte(bits)
+					bits = 0
+				}
+			case PGM:
+				if wide {
+					writeSamples16(bw, o.Plain, color.Gray16Model.Convert(c).(color.Gray16).Y)
+					continue
+				}
+				writeSamples(bw, o.Plain, color.GrayModel.Convert(c).(color.Gray).Y)
+			default:
+				// アルファは捨てて、黒背景に合成した値を書き出す。
+				if wide {
+					rgba := color.RGBA64Model.Convert(c).(color.RGBA64)
+					writeSamples16(bw, o.Plain, rgba.R, rgba.G, rgba.B)
+					continue
+				}
+				rgba := color.RGBAModel.Convert(c).(color.RGBA)
+				writeSamples(bw, o.Plain, rgba.R, rgba.G, rgba.B)
+			}
+		}
+		if o.Plain {
+			bw.WriteByte('\n')
+		}
+	}
+
+	return bw.Flush()
+}
+
+func writeSamples(w *bufio.Writer, plain bool, samples ...uint8) {
+	for _, s := range samples {
+		if plain {
+			fmt.Fprintf(w, "%d ", s)
+		} else {
+			w.WriteByte(s)
+		}
+	}
+}
+
+// writeSamples16 writes the samples in big-endian order as the maxval is above 255.
+func writeSamples16(w *bufio.Writer, plain bool, samples ...uint16) {
+	for _, s := range samples {
+		if plain {
+			fmt.Fprintf(w, "%d ", s)
+		} else {
+			w.WriteByte(byte(s >> 8))
+			w.WriteByte(byte(s))
+		}
+	}
+}
+
+// highBitDepth reports whether m has 16-bit samples.
+func highBitDepth(m image.Image) bool {
+	switch m.ColorModel() {
+	case color.RGBA64Model, color.NRGBA64Model, color.Gray16Model, color.Alpha16Model:
+		return true
+	}
+	return false
+}
+
+func init() {
+	for _, f := range []struct{ name, magic string }{
+		{"pbm", "P1"}, {"pgm", "P2"}, {"ppm", "P3"},
+		{"pbm", "P4"}, {"pgm", "P5"}, {"ppm", "P6"},
+	} {
+		image.RegisterFormat(f.name, f.magic, Decode, DecodeConfig)
+	}
+}
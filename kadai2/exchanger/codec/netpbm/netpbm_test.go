@@ -0,0 +1,114 @@
+package netpbm
+
+import (
+	"bytes"
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		want      []color.Color
+		wantError bool
+	}{
+		{"plain pbm", "P1\n# comment\n2 2\n0110", []color.Color{
+			color.Gray{0xff}, color.Gray{0}, color.Gray{0}, color.Gray{0xff}}, false},
+		{"raw pbm", "P4\n2 2\n\x80\x40", []color.Color{
+			color.Gray{0}, color.Gray{0xff}, color.Gray{0xff}, color.Gray{0}}, false},
+		{"plain pgm", "P2 2 1 15 0 15", []color.Color{color.Gray{0}, color.Gray{0xff}}, false},
+		{"raw pgm", "P5 2 1 255\n\x10\x20", []color.Color{color.Gray{0x10}, color.Gray{0x20}}, false},
+		{"raw pgm 16bit", "P5 1 1 65535\n\x12\x34", []color.Color{color.Gray16{0x1234}}, false},
+		{"plain ppm", "P3 1 1 255\n1 2 3", []color.Color{color.RGBA{1, 2, 3, 0xff}}, false},
+		{"raw ppm", "P6 1 1 255 \x0a\x0b\x0c", []color.Color{color.RGBA{10, 11, 12, 0xff}}, false},
+		{"bad magic", "P7 1 1 255\n", nil, true},
+		{"exceeds maxval", "P2 1 1 10 11", nil, true},
+		{"truncated", "P6 2 2 255\n\x00\x00", nil, true},
+		{"too many pixels", "P6 65536 65536 255\n\x00\x00", nil, true},
+	}
+
+	for _, tt := range tests {
+		img, err := Decode(strings.NewReader(tt.data))
+		helper.TestWantError(t, err, tt.wantError)
+		if err != nil {
+			continue
+		}
+		for i, want := range tt.want {
+			x, y := i%img.Bounds().Dx(), i/img.Bounds().Dx()
+			if got := img.At(x, y); got != want {
+				t.Errorf("%s: pixel (%d, %d) = %v, want %v", tt.name, x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	src := helper.Gradient(11, 5)
+
+	for _, f := range []Format{PPM, PGM, PBM} {
+		for _, plain := range []bool{false, true} {
+			var buf bytes.Buffer
+			if err := Encode(&buf, src, &Options{Format: f, Plain: plain}); err != nil {
+				t.Fatal(err)
+			}
+			img, name, err := image.Decode(&buf)
+			if err != nil {
+				t.Fatalf("format %d plain %v: %v", f, plain, err)
+			}
+			if want := []string{"ppm", "pgm", "pbm"}[f]; name != want {
+				t.Errorf("format name = %s, want %s", name, want)
+			}
+
+			// 変換後の色を期待値として比較する。
+			model := img.ColorModel()
+			for y := 0; y < 5; y++ {
+				for x := 0; x < 11; x++ {
+					want := model.Convert(src.At(x, y))
+					if f == PBM {
+						want = color.Gray{0}
+						if color.GrayModel.Convert(src.At(x, y)).(color.Gray).Y >= 0x80 {
+							want = color.Gray{0xff}
+						}
+					}
+					if got := img.At(x, y); got != want {
+						t.Fatalf("format %d plain %v: pixel (%d, %d) = %v, want %v", f, plain, x, y, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestEncode16(t *testing.T) {
+	src := helper.Gradient16(7, 3)
+
+	for _, f := range []Format{PPM, PGM} {
+		for _, plain := range []bool{false, true} {
+			var buf bytes.Buffer
+			if err := Encode(&buf, src, &Options{Format: f, Plain: plain}); err != nil {
+				t.Fatal(err)
+			}
+			img, _, err := image.Decode(&buf)
+			if err != nil {
+				t.Fatalf("format %d plain %v: %v", f, plain, err)
+			}
+
+			// 16 ビットのまま書き出されていれば下位バイトも一致する。
+			model := img.ColorModel()
+			if model != color.RGBA64Model && model != color.Gray16Model {
+				t.Fatalf("format %d plain %v: decoded as 8 bits", f, plain)
+			}
+			for y := 0; y < 3; y++ {
+				for x := 0; x < 7; x++ {
+					if got, want := img.At(x, y), model.Convert(src.At(x, y)); got != want {
+						t.Fatalf("format %d plain %v: pixel (%d, %d) = %v, want %v", f, plain, x, y, got, want)
+					}
+				}
+			}
+		}
+	}
+}
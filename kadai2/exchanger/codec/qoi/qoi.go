@@ -0,0 +1,246 @@
+// Package qoi implements a decoder and an encoder for the QOI image format.
+//
+// The format is specified at https://qoiformat.org/qoi-specification.pdf.
+package qoi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+)
+
+const (
+	magic      = "qoif"
+	headerSize = 14
+
+	opIndex = 0x00
+	opDiff  = 0x40
+	opLuma  = 0x80
+	opRun   = 0xc0
+	opRGB   = 0xfe
+	opRGBA  = 0xff
+	opMask  = 0xc0
+
+	// 画像サイズの上限。仕様で推奨されている値に合わせている。
+	maxPixels = 400000000
+)
+
+var endMarker = [8]byte{0, 0, 0, 0, 0, 0, 0, 1}
+
+// FormatError reports that the input is not a valid QOI image.
+type FormatError string
+
+func (e FormatError) Error() string { return "qoi: invalid format: " + string(e) }
+
+type header struct {
+	width, height uint32
+	channels      uint8
+	colorspace    uint8
+}
+
+func readHeader(r io.Reader) (header, error) {
+	var h header
+	var b [headerSize]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return h, err
+	}
+	if string(b[:4]) != magic {
+		return h, FormatError("bad magic number")
+	}
+
+	h.width = binary.BigEndian.Uint32(b[4:])
+	h.height = binary.BigEndian.Uint32(b[8:])
+	h.channels = b[12]
+	h.colorspace = b[13]
+	if h.width == 0 || h.height == 0 || uint64(h.width)*uint64(h.height) > maxPixels {
+		return h, FormatError("bad dimensions")
+	}
+	if h.channels != 3 && h.channels != 4 {
+		return h, FormatError("bad channels")
+	}
+	if h.colorspace > 1 {
+		return h, FormatError("bad colorspace")
+	}
+
+	return h, nil
+}
+
+func hash(c color.NRGBA) int {
+	return (int(c.R)*3 + int(c.G)*5 + int(c.B)*7 + int(c.A)*11) % 64
+}
+
+// DecodeConfig returns the color model and dimensions of a QOI image without decoding the entire image.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	return image.Config{ColorModel: color.NRGBAModel, Width: int(h.width), Height: int(h.height)}, nil
+}
+
+// Decode reads a QOI image from r and returns it as an *image.NRGBA.
+func Decode(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	h, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, int(h.width), int(h.height)))
+	var index [64]color.NRGBA
+	px := color.NRGBA{0, 0, 0, 255}
+	run := 0
+	for i := 0; i < len(img.Pix); i += 4 {
+		if run > 0 {
+			run--
+		} else {
+			b, err := br.ReadByte()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+
+			switch {
+			case b == opRGB:
+				var c [3]byte
+				if _, err := io.ReadFull(br, c[:]); err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				px.R, px.G, px.B = c[0], c[1], c[2]
+			case b == opRGBA:
+				var c [4]byte
+				if _, err := io.ReadFull(br, c[:]); err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				px = color.NRGBA{c[0], c[1], c[2], c[3]}
+			case b&opMask == opIndex:
+				px = index[b]
+			case b&opMask == opDiff:
+				px.R += (b>>4)&0x03 - 2
+				px.G += (b>>2)&0x03 - 2
+				px.B += b&0x03 - 2
+			case b&opMask == opLuma:
+				b2, err := br.ReadByte()
+				if err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				dg := b&0x3f - 32
+				px.R += dg - 8 + (b2>>4)&0x0f
+				px.G += dg
+				px.B += dg - 8 + b2&0x0f
+			default:
+				run = int(b & 0x3f)
+			}
+			index[hash(px)] = px
+		}
+
+		img.Pix[i+0] = px.R
+		img.Pix[i+1] = px.G
+		img.Pix[i+2] = px.B
+		img.Pix[i+3] = px.A
+	}
+
+	var end [8]byte
+	if _, err := io.ReadFull(br, end[:]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if end != endMarker {
+		return nil, FormatError("bad end marker")
+	}
+
+	return img, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Encode writes the image m to w in QOI format.
+// Opaque images are written with 3 channels, others with 4.
+func Encode(w io.Writer, m image.Image) error {
+	b := m.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 {
+		return errors.New("qoi: image is empty")
+	}
+	if uint64(b.Dx())*uint64(b.Dy()) > maxPixels {
+		return errors.New("qoi: image is too large")
+	}
+
+	channels := uint8(4)
+	if o, ok := m.(interface{ Opaque() bool }); ok && o.Opaque() {
+		channels = 3
+	}
+
+	bw := bufio.NewWriter(w)
+	var hdr [headerSize]byte
+	copy(hdr[:], magic)
+	binary.BigEndian.PutUint32(hdr[4:], uint32(b.Dx()))
+	binary.BigEndian.PutUint32(hdr[8:], uint32(b.Dy()))
+	hdr[12] = channels
+	bw.Write(hdr[:])
+
+	var index [64]color.NRGBA
+	prev := color.NRGBA{0, 0, 0, 255}
+	run := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			px := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+			if channels == 3 {
+				px.A = 255
+			}
+
+			if px == prev {
+				run++
+				if run == 62 {
+					bw.WriteByte(opRun | byte(run-1))
+					run = 0
+				}
+				continue
+			}
+			if run > 0 {
+				bw.WriteByte(opRun | byte(run-1))
+				run = 0
+			}
+
+			h := hash(px)
+			switch {
+			case index[h] == px:
+				bw.WriteByte(opIndex | byte(h))
+			case px.A != prev.A:
+				bw.Write([]byte{opRGBA, px.R, px.G, px.B, px.A})
+			default:
+				dr := int(int8(px.R - prev.R))
+				dg := int(int8(px.G - prev.G))
+				db := int(int8(px.B - prev.B))
+				drg := dr - dg
+				dbg := db - dg
+				switch {
+				case dr >= -2 && dr <= 1 && dg >= -2 && dg <= 1 && db >= -2 && db <= 1:
+					bw.WriteByte(opDiff | byte(dr+2)<<4 | byte(dg+2)<<2 | byte(db+2))
+				case dg >= -32 && dg <= 31 && drg >= -8 && drg <= 7 && dbg >= -8 && dbg <= 7:
+					bw.Write([]byte{opLuma | byte(dg+32), byte(drg+8)<<4 | byte(dbg+8)})
+				default:
+					bw.Write([]byte{opRGB, px.R, px.G, px.B})
+				}
+			}
+			index[h] = px
+			prev = px
+		}
+	}
+	if run > 0 {
+		bw.WriteByte(opRun | byte(run-1))
+	}
+	bw.Write(endMarker[:])
+
+	return bw.Flush()
+}
+
+func init() {
+	image.RegisterFormat("qoi", magic, Decode, DecodeConfig)
+}
@@ -0,0 +1,93 @@
+package qoi
+
+import (
+	"bytes"
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"opaque", pattern(image.NewNRGBA(image.Rect(0, 0, 37, 19)), 255)},
+		{"translucent", pattern(image.NewNRGBA(image.Rect(0, 0, 37, 19)), 100)},
+		{"offset bounds", pattern(image.NewNRGBA(image.Rect(5, 7, 130, 20)), 255)},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := Encode(&buf, tt.img); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got, name, err := image.Decode(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if name != "qoi" {
+			t.Errorf("%s: format name = %s, want qoi", tt.name, name)
+		}
+
+		b := tt.img.Bounds()
+		if got.Bounds().Size() != b.Size() {
+			t.Fatalf("%s: size = %v, want %v", tt.name, got.Bounds().Size(), b.Size())
+		}
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				want := color.NRGBAModel.Convert(tt.img.At(b.Min.X+x, b.Min.Y+y))
+				if c := got.At(x, y); c != want {
+					t.Fatalf("%s: pixel (%d, %d) = %v, want %v", tt.name, x, y, c, want)
+				}
+			}
+		}
+	}
+}
+
+// pattern fills img with runs, small and large steps and repeated colors
+// so that every chunk type appears in the encoded stream.
+func pattern(img *image.NRGBA, alpha uint8) *image.NRGBA {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var c color.NRGBA
+			switch {
+			case x < 10:
+				c = color.NRGBA{10, 20, 30, alpha}
+			case x%7 == 0:
+				c = color.NRGBA{uint8(x * 31), uint8(y * 17), uint8(x ^ y), uint8(x * y)}
+			default:
+				c = color.NRGBA{uint8(x), uint8(x + y), uint8(y*3 + x), alpha}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	return img
+}
+
+func TestDecodeError(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, pattern(image.NewNRGBA(image.Rect(0, 0, 4, 4)), 255)); err != nil {
+		t.Fatal(err)
+	}
+	valid := buf.Bytes()
+
+	tests := []struct {
+		name      string
+		data      []byte
+		wantError bool
+	}{
+		{"valid", valid, false},
+		{"bad magic", append([]byte("qoix"), valid[4:]...), true},
+		{"truncated", valid[:len(valid)-3], true},
+		{"no end marker", append(append([]byte{}, valid[:len(valid)-8]...), 1, 2, 3, 4, 5, 6, 7, 8), true},
+	}
+
+	for _, tt := range tests {
+		_, err := Decode(bytes.NewReader(tt.data))
+		helper.TestWantError(t, err, tt.wantError)
+	}
+}
@@ -0,0 +1,162 @@
+package tiff
+
+import "errors"
+
+// TIFF の LZW は compress/lzw と違い、MSB ファーストで、符号長を1つ早く切り替える。
+const (
+	lzwClear    = 256
+	lzwEOI      = 257
+	lzwMinWidth = 9
+	lzwMaxWidth = 12
+	lzwMaxCode  = 1<<lzwMaxWidth - 2
+)
+
+var errLZW = errors.New("tiff: invalid LZW data")
+
+type bitWriter struct {
+	buf   []byte
+	acc   uint32
+	nbits uint
+}
+
+func (w *bitWriter) write(code int, width uint) {
+	w.acc |= uint32(code) << (32 - width - w.nbits)
+	w.nbits += width
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc>>24))
+		w.acc <<= 8
+		w.nbits -= 8
+	}
+}
+
+func (w *bitWriter) flush() []byte {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.acc>>24))
+	}
+	return w.buf
+}
+
+// lzwCompress compresses data with the LZW variant of the TIFF specification.
+func lzwCompress(data []byte) []byte {
+	w := &bitWriter{}
+	width := uint(lzwMinWidth)
+	w.write(lzwClear, width)
+	if len(data) == 0 {
+		w.write(lzwEOI, width)
+		return w.flush()
+	}
+
+	table := make(map[uint32]int)
+	hi := lzwEOI
+	// incHi は新しい符号を割り当て、必要に応じて符号長を伸ばすかテーブルをリセットする。
+	incHi := func() {
+		hi++
+		if hi+1 == 1<<width && width < lzwMaxWidth {
+			width++
+		}
+		if hi == lzwMaxCode {
+			w.write(lzwClear, width)
+			width = lzwMinWidth
+			hi = lzwEOI
+			table = make(map[uint32]int)
+		}
+	}
+
+	code := int(data[0])
+	for _, c := range data[1:] {
+		key := uint32(code)<<8 | uint32(c)
+		if next, ok := table[key]; ok {
+			code = next
+			continue
+		}
+		w.write(code, width)
+		incHi()
+		// リセット直後でなければ、割り当てた符号を登録する。
+		if hi != lzwEOI {
+			table[key] = hi
+		}
+		code = int(c)
+	}
+	w.write(code, width)
+	incHi()
+	w.write(lzwEOI, width)
+
+	return w.flush()
+}
+
+// lzwDecompress decompresses TIFF LZW data. A missing EOI code is tolerated.
+func lzwDecompress(src []byte) ([]byte, error) {
+	var prefix [1 << lzwMaxWidth]uint16
+	var suffix [1 << lzwMaxWidth]byte
+	var length [1 << lzwMaxWidth]int
+	for i := 0; i < 256; i++ {
+		suffix[i] = byte(i)
+		length[i] = 1
+	}
+
+	var out []byte
+	var acc uint32
+	var nbits uint
+	pos := 0
+	width := uint(lzwMinWidth)
+	hi, last := lzwEOI, -1
+	for {
+		for nbits < width && pos < len(src) {
+			acc |= uint32(src[pos]) << (24 - nbits)
+			nbits += 8
+			pos++
+		}
+		if nbits < width {
+			return out, nil
+		}
+		code := int(acc >> (32 - width))
+		acc <<= width
+		nbits -= width
+
+		switch {
+		case code == lzwClear:
+			width, hi, last = lzwMinWidth, lzwEOI, -1
+			continue
+		case code == lzwEOI:
+			return out, nil
+		case code < lzwClear, code > lzwEOI && code < hi:
+		case code == hi && last >= 0:
+			// KwKwK: 直前の符号に、その先頭文字を足したもの
+		default:
+			return nil, errLZW
+		}
+
+		if last >= 0 {
+			// 直前の符号 + 今回の符号の先頭文字 を hi に登録する。
+			first := code
+			if code == hi {
+				first = last
+			}
+			for length[first] > 1 {
+				first = int(prefix[first])
+			}
+			prefix[hi] = uint16(last)
+			suffix[hi] = suffix[first]
+			length[hi] = length[last] + 1
+		}
+
+		n := length[code]
+		out = append(out, make([]byte, n)...)
+		for i, c := len(out)-1, code; i >= len(out)-n; i-- {
+			out[i] = suffix[c]
+			c = int(prefix[c])
+		}
+
+		last = code
+		hi++
+		if hi+1 >= 1<<width {
+			if width < lzwMaxWidth {
+				width++
+			} else {
+				// テーブルが一杯になったら Clear が来るまで登録しない。
+				hi--
+				last = -1
+			}
+		}
+	}
+}
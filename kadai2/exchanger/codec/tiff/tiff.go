@@ -0,0 +1,520 @@
+// Package tiff implements a decoder and an encoder for baseline TIFF images.
+//
+// The decoder reads the first image of a file stored in strips with no, LZW,
+// Deflate or PackBits compression. Tiled and planar images are not supported.
+package tiff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	leHeader = "II\x2A\x00"
+	beHeader = "MM\x00\x2A"
+
+	ifdEntrySize = 12
+
+	dtByte     = 1
+	dtASCII    = 2
+	dtShort    = 3
+	dtLong     = 4
+	dtRational = 5
+
+	tImageWidth                = 256
+	tImageLength               = 257
+	tBitsPerSample             = 258
+	tCompression               = 259
+	tPhotometricInterpretation = 262
+	tStripOffsets              = 273
+	tSamplesPerPixel           = 277
+	tRowsPerStrip              = 278
+	tStripByteCounts           = 279
+	tXResolution               = 282
+	tYResolution               = 283
+	tPlanarConfiguration       = 284
+	tResolutionUnit            = 296
+	tPredictor                 = 317
+	tColorMap                  = 320
+	tTileWidth                 = 322
+	tExtraSamples              = 338
+
+	cNone     = 1
+	cLZW      = 5
+	cDeflate  = 8
+	cPackBits = 32773
+	// Adobe 以前の Deflate の値
+	cDeflateOld = 32946
+
+	pWhiteIsZero = 0
+	pBlackIsZero = 1
+	pRGB         = 2
+	pPaletted    = 3
+
+	prHorizontal = 2
+
+	// 巨大なヘッダで大量のメモリを確保しないための上限。
+	maxPixels = 1 << 28
+)
+
+// FormatError reports that the input is not a valid TIFF image.
+type FormatError string
+
+func (e FormatError) Error() string { return "tiff: invalid format: " + string(e) }
+
+// UnsupportedError reports that the input uses a valid but unimplemented TIFF feature.
+type UnsupportedError string
+
+func (e UnsupportedError) Error() string { return "tiff: unsupported feature: " + string(e) }
+
+type decoder struct {
+	buf   []byte
+	order binary.ByteOrder
+	tags  map[int][]uint
+
+	width, height int
+	bps           int // bits per sample
+	spp           int // samples per pixel
+	photometric   uint
+	alpha         uint // ExtraSamples: 1 なら premultiplied、2 なら straight
+	palette       color.Palette
+}
+
+func newDecoder(r io.Reader) (*decoder, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{buf: buf, tags: make(map[int][]uint)}
+
+	if len(buf) < 8 {
+		return nil, FormatError("short header")
+	}
+	switch string(buf[:4]) {
+	case leHeader:
+		d.order = binary.LittleEndian
+	case beHeader:
+		d.order = binary.BigEndian
+	default:
+		return nil, FormatError("bad magic number")
+	}
+
+	offset := int64(d.order.Uint32(buf[4:]))
+	if offset < 8 || offset+2 > int64(len(buf)) {
+		return nil, FormatError("bad IFD offset")
+	}
+	n := int64(d.order.Uint16(buf[offset:]))
+	if offset+2+n*ifdEntrySize > int64(len(buf)) {
+		return nil, FormatError("truncated IFD")
+	}
+	for i := int64(0); i < n; i++ {
+		e := buf[offset+2+i*ifdEntrySize:]
+		tag := int(d.order.Uint16(e[0:]))
+		values, err := d.values(e)
+		if err != nil {
+			return nil, err
+		}
+		d.tags[tag] = values
+	}
+
+	if err := d.parse(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// values returns the integer values of the IFD entry e. Other types are ignored.
+func (d *decoder) values(e []byte) ([]uint, error) {
+	typ := d.order.Uint16(e[2:])
+	count := int64(d.order.Uint32(e[4:]))
+	var size int64
+	switch typ {
+	case dtByte, dtASCII:
+		size = 1
+	case dtShort:
+		size = 2
+	case dtLong:
+		size = 4
+	default:
+		return nil, nil
+	}
+
+	raw := e[8:12]
+	if count*size > 4 {
+		offset := int64(d.order.Uint32(e[8:]))
+		if count > int64(len(d.buf)) || offset+count*size > int64(len(d.buf)) {
+			return nil, FormatError("bad IFD entry")
+		}
+		raw = d.buf[offset : offset+count*size]
+	}
+
+	values := make([]uint, count)
+	for i := range values {
+		switch size {
+		case 1:
+			values[i] = uint(raw[i])
+		case 2:
+			values[i] = uint(d.order.Uint16(raw[2*i:]))
+		case 4:
+			values[i] = uint(d.order.Uint32(raw[4*i:]))
+		}
+	}
+	return values, nil
+}
+
+func (d *decoder) first(tag int, def uint) uint {
+	if v := d.tags[tag]; len(v) > 0 {
+		return v[0]
+	}
+	return def
+}
+
+func (d *decoder) parse() error {
+	d.width = int(d.first(tImageWidth, 0))
+	d.height = int(d.first(tImageLength, 0))
+	if d.width <= 0 || d.height <= 0 || d.width*d.height > maxPixels {
+		return FormatError("bad dimensions")
+	}
+	if _, ok := d.tags[tTileWidth]; ok {
+		return UnsupportedError("tiled image")
+	}
+	if d.first(tPlanarConfiguration, 1) != 1 {
+		return UnsupportedError("planar configuration")
+	}
+
+	d.spp = int(d.first(tSamplesPerPixel, 1))
+	d.bps = int(d.first(tBitsPerSample, 1))
+	for _, b := range d.tags[tBitsPerSample] {
+		if int(b) != d.bps {
+			return UnsupportedError("mixed bits per sample")
+		}
+	}
+	d.photometric = d.first(tPhotometricInterpretation, pBlackIsZero)
+
+	switch d.photometric {
+	case pWhiteIsZero, pBlackIsZero:
+		if d.spp != 1 && d.spp != 2 {
+			return UnsupportedError("gray samples per pixel")
+		}
+		if d.bps != 1 && d.bps != 2 && d.bps != 4 && d.bps != 8 && d.bps != 16 {
+			return UnsupportedError("gray bits per sample")
+		}
+	case pRGB:
+		if d.spp != 3 && d.spp != 4 {
+			return UnsupportedError("RGB samples per pixel")
+		}
+		if d.bps != 8 && d.bps != 16 {
+			return UnsupportedError("RGB bits per sample")
+		}
+	case pPaletted:
+		if d.spp != 1 || d.bps > 8 {
+			return UnsupportedError("paletted image")
+		}
+		cm := d.tags[tColorMap]
+		n := 1 << uint(d.bps)
+		if len(cm) != 3*n {
+			return FormatError("bad color map")
+		}
+		d.palette = make(color.Palette, n)
+		for i := range d.palette {
+			d.palette[i] = color.RGBA64{uint16(cm[i]), uint16(cm[n+i]), uint16(cm[2*n+i]), 0xffff}
+		}
+	default:
+		return UnsupportedError("photometric interpretation")
+	}
+
+	if d.spp == 2 || d.spp == 4 {
+		d.alpha = d.first(tExtraSamples, 0)
+	}
+	return nil
+}
+
+func (d *decoder) colorModel() color.Model {
+	switch d.photometric {
+	case pPaletted:
+		return d.palette
+	case pWhiteIsZero, pBlackIsZero:
+		if d.spp == 1 {
+			if d.bps == 16 {
+				return color.Gray16Model
+			}
+			return color.GrayModel
+		}
+	}
+
+	switch {
+	case d.bps == 16 && d.alpha == 1:
+		return color.RGBA64Model
+	case d.bps == 16:
+		return color.NRGBA64Model
+	case d.alpha == 1:
+		return color.RGBAModel
+	}
+	return color.NRGBAModel
+}
+
+// raster decompresses all strips and returns the pixel data.
+func (d *decoder) raster() ([]byte, error) {
+	offsets := d.tags[tStripOffsets]
+	counts := d.tags[tStripByteCounts]
+	if len(offsets) == 0 || len(offsets) != len(counts) {
+		return nil, FormatError("bad strips")
+	}
+
+	rowSize := (d.width*d.bps*d.spp + 7) / 8
+	rowsPerStrip := int(d.first(tRowsPerStrip, uint(d.height)))
+	if rowsPerStrip <= 0 || rowsPerStrip > d.height {
+		rowsPerStrip = d.height
+	}
+	compression := d.first(tCompression, cNone)
+	predictor := d.first(tPredictor, 1)
+
+	out := make([]byte, 0, rowSize*d.height)
+	for i := range offsets {
+		if int64(offsets[i])+int64(counts[i]) > int64(len(d.buf)) {
+			return nil, FormatError("strip out of range")
+		}
+		strip := d.buf[offsets[i] : offsets[i]+counts[i]]
+
+		var data []byte
+		var err error
+		switch compression {
+		case cNone:
+			data = strip
+		case cLZW:
+			data, err = lzwDecompress(strip)
+		case cDeflate, cDeflateOld:
+			var zr io.ReadCloser
+			if zr, err = zlib.NewReader(bytes.NewReader(strip)); err == nil {
+				data, err = ioutil.ReadAll(zr)
+				zr.Close()
+			}
+		case cPackBits:
+			data, err = unpackBits(strip)
+		default:
+			return nil, UnsupportedError("compression")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rows := rowsPerStrip
+		if remaining := d.height - i*rowsPerStrip; remaining < rows {
+			rows = remaining
+		}
+		if rows <= 0 {
+			break
+		}
+		if len(data) < rows*rowSize {
+			return nil, FormatError("short strip")
+		}
+		data = data[:rows*rowSize]
+
+		if predictor == prHorizontal {
+			for r := 0; r < rows; r++ {
+				if err := d.undoPredictor(data[r*rowSize : (r+1)*rowSize]); err != nil {
+					return nil, err
+				}
+			}
+		}
+		out = append(out, data...)
+	}
+	if len(out) < rowSize*d.height {
+		return nil, FormatError("missing strips")
+	}
+
+	return out, nil
+}
+
+func (d *decoder) undoPredictor(row []byte) error {
+	switch d.bps {
+	case 8:
+		for i := d.spp; i < len(row); i++ {
+			row[i] += row[i-d.spp]
+		}
+	case 16:
+		for i := 2 * d.spp; i+1 < len(row); i += 2 {
+			v := d.order.Uint16(row[i:]) + d.order.Uint16(row[i-2*d.spp:])
+			d.order.PutUint16(row[i:], v)
+		}
+	default:
+		return UnsupportedError("predictor with bits per sample")
+	}
+	return nil
+}
+
+func unpackBits(src []byte) ([]byte, error) {
+	var out []byte
+	for i := 0; i < len(src); {
+		n := int(int8(src[i]))
+		i++
+		switch {
+		case n >= 0:
+			if i+n+1 > len(src) {
+				return nil, FormatError("bad PackBits data")
+			}
+			out = append(out, src[i:i+n+1]...)
+			i += n + 1
+		case n != -128:
+			if i >= len(src) {
+				return nil, FormatError("bad PackBits data")
+			}
+			out = append(out, bytes.Repeat(src[i:i+1], 1-n)...)
+			i++
+		}
+	}
+	return out, nil
+}
+
+// DecodeConfig returns the color model and dimensions of a TIFF image without decoding the entire image.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	d, err := newDecoder(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	return image.Config{ColorModel: d.colorModel(), Width: d.width, Height: d.height}, nil
+}
+
+// Decode reads the first image of a TIFF file from r and returns it as an image.Image.
+// The type of the image follows the samples, e.g. *image.Gray16 for 16-bit grayscale
+// or *image.NRGBA for RGB with an unassociated alpha.
+func Decode(r io.Reader) (image.Image, error) {
+	d, err := newDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.raster()
+	if err != nil {
+		return nil, err
+	}
+
+	rect := image.Rect(0, 0, d.width, d.height)
+	rowSize := (d.width*d.bps*d.spp + 7) / 8
+	sample16 := func(row []byte, i int) uint16 { return d.order.Uint16(row[2*i:]) }
+
+	if d.photometric == pRGB || d.spp == 2 {
+		return d.decodeColor(rect, data, rowSize)
+	}
+
+	var img image.Image
+	var set func(x, y int, v int)
+	max := 1<<uint(d.bps) - 1
+	switch d.photometric {
+	case pPaletted:
+		m := image.NewPaletted(rect, d.palette)
+		set = func(x, y, v int) { m.Pix[y*m.Stride+x] = uint8(v) }
+		img = m
+	default:
+		invert := d.photometric == pWhiteIsZero
+		if d.bps == 16 {
+			m := image.NewGray16(rect)
+			set = func(x, y, v int) {
+				if invert {
+					v = max - v
+				}
+				m.SetGray16(x, y, color.Gray16{uint16(v)})
+			}
+			img = m
+		} else {
+			m := image.NewGray(rect)
+			set = func(x, y, v int) {
+				if invert {
+					v = max - v
+				}
+				m.Pix[y*m.Stride+x] = uint8(v * 0xff / max)
+			}
+			img = m
+		}
+	}
+
+	for y := 0; y < d.height; y++ {
+		row := data[y*rowSize : (y+1)*rowSize]
+		for x := 0; x < d.width; x++ {
+			var v int
+			if d.bps == 16 {
+				v = int(sample16(row, x))
+			} else {
+				bit := x * d.bps
+				v = int(row[bit/8]>>uint(8-d.bps-bit%8)) & max
+			}
+			set(x, y, v)
+		}
+	}
+
+	return img, nil
+}
+
+// decodeColor decodes RGB images and grayscale images with an alpha sample.
+func (d *decoder) decodeColor(rect image.Rectangle, data []byte, rowSize int) (image.Image, error) {
+	gray := d.photometric == pWhiteIsZero || d.photometric == pBlackIsZero
+	if gray && d.bps != 8 && d.bps != 16 {
+		return nil, UnsupportedError("gray alpha bits per sample")
+	}
+
+	var img image.Image
+	var set func(x, y int, s [4]uint16)
+	switch d.colorModel() {
+	case color.RGBA64Model:
+		m := image.NewRGBA64(rect)
+		set = func(x, y int, s [4]uint16) { m.SetRGBA64(x, y, color.RGBA64{s[0], s[1], s[2], s[3]}) }
+		img = m
+	case color.NRGBA64Model:
+		m := image.NewNRGBA64(rect)
+		set = func(x, y int, s [4]uint16) { m.SetNRGBA64(x, y, color.NRGBA64{s[0], s[1], s[2], s[3]}) }
+		img = m
+	case color.RGBAModel:
+		m := image.NewRGBA(rect)
+		set = func(x, y int, s [4]uint16) {
+			m.SetRGBA(x, y, color.RGBA{uint8(s[0] >> 8), uint8(s[1] >> 8), uint8(s[2] >> 8), uint8(s[3] >> 8)})
+		}
+		img = m
+	default:
+		m := image.NewNRGBA(rect)
+		set = func(x, y int, s [4]uint16) {
+			m.SetNRGBA(x, y, color.NRGBA{uint8(s[0] >> 8), uint8(s[1] >> 8), uint8(s[2] >> 8), uint8(s[3] >> 8)})
+		}
+		img = m
+	}
+
+	var s [4]uint16
+	for y := 0; y < d.height; y++ {
+		row := data[y*rowSize : (y+1)*rowSize]
+		for x := 0; x < d.width; x++ {
+			for i := 0; i < d.spp; i++ {
+				j := x*d.spp + i
+				if d.bps == 16 {
+					s[i] = d.order.Uint16(row[2*j:])
+				} else {
+					s[i] = uint16(row[j]) * 0x101
+				}
+			}
+			switch {
+			case gray:
+				v := s[0]
+				if d.photometric == pWhiteIsZero {
+					v = 0xffff - v
+				}
+				s = [4]uint16{v, v, v, s[1]}
+			case d.spp == 3:
+				s[3] = 0xffff
+			}
+			if d.alpha == 0 {
+				s[3] = 0xffff
+			}
+			set(x, y, s)
+		}
+	}
+
+	return img, nil
+}
+
+func init() {
+	image.RegisterFormat("tiff", leHeader, Decode, DecodeConfig)
+	image.RegisterFormat("tiff", beHeader, Decode, DecodeConfig)
+}
@@ -0,0 +1,121 @@
+package tiff
+
+import (
+	"bytes"
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	rect := image.Rect(0, 0, 57, 31)
+	rgba := image.NewRGBA(rect)
+	nrgba := image.NewNRGBA(rect)
+	gray := image.NewGray(rect)
+	gray16 := image.NewGray16(rect)
+	nrgba64 := image.NewNRGBA64(rect)
+	paletted := image.NewPaletted(rect, color.Palette{
+		color.RGBA{0, 0, 0, 0xff}, color.RGBA{0xff, 0x80, 0, 0xff}, color.RGBA{10, 20, 30, 0xff},
+	})
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			rgba.SetRGBA(x, y, color.RGBA{uint8(x * 19), uint8(y * 37), uint8(x ^ y), 0xff})
+			nrgba.SetNRGBA(x, y, color.NRGBA{uint8(x * 19), uint8(y * 37), uint8(x ^ y), uint8(x * 20)})
+			gray.SetGray(x, y, color.Gray{uint8(x * y * 3)})
+			gray16.SetGray16(x, y, color.Gray16{uint16(x*y*1000 + 1)})
+			nrgba64.SetNRGBA64(x, y, color.NRGBA64{uint16(x * 1001), uint16(y * 2003), 7, uint16(x*y*97 + 1)})
+			paletted.SetColorIndex(x, y, uint8((x+y)%3))
+		}
+	}
+
+	images := []struct {
+		name string
+		img  image.Image
+	}{
+		{"rgb", rgba},
+		{"rgba", nrgba},
+		{"gray", gray},
+		{"gray16", gray16},
+		{"rgba64", nrgba64},
+		{"paletted", paletted},
+	}
+	options := []*Options{
+		nil,
+		{Compression: LZW},
+		{Compression: LZW, Predictor: true},
+		{Compression: Deflate},
+		{Compression: Deflate, Predictor: true},
+	}
+
+	for _, tt := range images {
+		for _, o := range options {
+			var buf bytes.Buffer
+			if err := Encode(&buf, tt.img, o); err != nil {
+				t.Fatalf("%s %+v: %v", tt.name, o, err)
+			}
+			got, name, err := image.Decode(&buf)
+			if err != nil {
+				t.Fatalf("%s %+v: %v", tt.name, o, err)
+			}
+			if name != "tiff" {
+				t.Errorf("%s: format name = %s, want tiff", tt.name, name)
+			}
+
+			// 16 ビットの精度も含めて一致することを確認する。
+			for y := 0; y < rect.Dy(); y++ {
+				for x := 0; x < rect.Dx(); x++ {
+					want := color.RGBA64Model.Convert(tt.img.At(x, y))
+					if c := color.RGBA64Model.Convert(got.At(x, y)); c != want {
+						t.Fatalf("%s %+v: pixel (%d, %d) = %v, want %v", tt.name, o, x, y, c, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestLZW(t *testing.T) {
+	// テーブルが何度も一杯になる程度の長さの、繰り返しの少ないデータ
+	data := make([]byte, 200000)
+	seed := uint32(1)
+	for i := range data {
+		seed = seed*1103515245 + 12345
+		data[i] = byte(seed >> 16)
+	}
+
+	tests := [][]byte{nil, {1}, []byte("TOBEORNOTTOBEORTOBEORNOT"), bytes.Repeat([]byte{7}, 10000), data}
+	for _, tt := range tests {
+		got, err := lzwDecompress(lzwCompress(tt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, tt) {
+			t.Errorf("round trip of %d bytes failed", len(tt))
+		}
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+	valid := buf.Bytes()
+
+	tests := []struct {
+		name      string
+		data      []byte
+		wantError bool
+	}{
+		{"valid", valid, false},
+		{"bad magic", append([]byte("XX"), valid[2:]...), true},
+		{"short", valid[:6], true},
+		{"bad IFD offset", append([]byte(leHeader+"\xff\xff\x00\x00"), valid[8:]...), true},
+	}
+
+	for _, tt := range tests {
+		_, err := Decode(bytes.NewReader(tt.data))
+		helper.TestWantError(t, err, tt.wantError)
+	}
+}
@@ -0,0 +1,260 @@
+package tiff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+)
+
+// CompressionType is the compression of an encoded image.
+type CompressionType int
+
+const (
+	// Uncompressed writes the samples as they are.
+	Uncompressed CompressionType = iota
+	// LZW compresses the samples with the TIFF variant of LZW.
+	LZW
+	// Deflate compresses the samples with zlib.
+	Deflate
+)
+
+// Options are the encoding parameters.
+type Options struct {
+	// Compression is the compression of the image data.
+	Compression CompressionType
+	// Predictor applies horizontal differencing before compression,
+	// which usually makes photographic images smaller.
+	Predictor bool
+}
+
+type ifdEntry struct {
+	tag    int
+	typ    int
+	values []uint32
+}
+
+// Encode writes the image m to w in little-endian TIFF format. If o is nil, the image is not compressed.
+// Gray and paletted images keep their type, 16-bit images are written with 16 bits per sample
+// and the others as 8-bit RGB, with an unassociated alpha sample unless they are opaque.
+func Encode(w io.Writer, m image.Image, o *Options) error {
+	if o == nil {
+		o = &Options{}
+	}
+	b := m.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 {
+		return errors.New("tiff: image is empty")
+	}
+
+	var (
+		photometric = uint32(pRGB)
+		bps         = 8
+		spp         = 3
+		extra       []uint32
+		colorMap    []uint32
+		sample      func(x, y int, s []uint16)
+	)
+	opaque := false
+	if op, ok := m.(interface{ Opaque() bool }); ok {
+		opaque = op.Opaque()
+	}
+
+	switch img := m.(type) {
+	case *image.Gray:
+		photometric, spp = pBlackIsZero, 1
+		sample = func(x, y int, s []uint16) { s[0] = uint16(img.GrayAt(x, y).Y) }
+	case *image.Gray16:
+		photometric, spp, bps = pBlackIsZero, 1, 16
+		sample = func(x, y int, s []uint16) { s[0] = img.Gray16At(x, y).Y }
+	case *image.Paletted:
+		if len(img.Palette) <= 256 && opaque {
+			photometric, spp = pPaletted, 1
+			colorMap = make([]uint32, 3*256)
+			for i, c := range img.Palette {
+				r, g, bl, _ := c.RGBA()
+				colorMap[i], colorMap[256+i], colorMap[512+i] = r, g, bl
+			}
+			sample = func(x, y int, s []uint16) { s[0] = uint16(img.ColorIndexAt(x, y)) }
+		}
+	case *image.RGBA64, *image.NRGBA64:
+		bps = 16
+	}
+
+	if sample == nil {
+		if !opaque {
+			spp, extra = 4, []uint32{2}
+		}
+		sample = func(x, y int, s []uint16) {
+			var c color.NRGBA64
+			// NRGBA はアルファを掛けた値を経由すると精度が落ちるので、そのまま使う。
+			switch img := m.(type) {
+			case *image.NRGBA:
+				n := img.NRGBAAt(x, y)
+				c = color.NRGBA64{uint16(n.R) * 0x101, uint16(n.G) * 0x101, uint16(n.B) * 0x101, uint16(n.A) * 0x101}
+			case *image.NRGBA64:
+				c = img.NRGBA64At(x, y)
+			default:
+				c = color.NRGBA64Model.Convert(m.At(x, y)).(color.NRGBA64)
+			}
+			s[0], s[1], s[2] = c.R, c.G, c.B
+			if spp == 4 {
+				s[3] = c.A
+			}
+			if bps == 8 {
+				for i := range s {
+					s[i] >>= 8
+				}
+			}
+		}
+	}
+
+	rowSize := b.Dx() * spp * bps / 8
+	raw := make([]byte, 0, rowSize*b.Dy())
+	row := make([]byte, rowSize)
+	s := make([]uint16, spp)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			sample(x, y, s)
+			i := (x - b.Min.X) * spp
+			for j, v := range s {
+				if bps == 16 {
+					binary.LittleEndian.PutUint16(row[2*(i+j):], v)
+				} else {
+					row[i+j] = uint8(v)
+				}
+			}
+		}
+		if o.Predictor && o.Compression != Uncompressed {
+			applyPredictor(row, spp, bps)
+		}
+		raw = append(raw, row...)
+	}
+
+	compression := uint32(cNone)
+	data := raw
+	switch o.Compression {
+	case LZW:
+		compression, data = cLZW, lzwCompress(raw)
+	case Deflate:
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write(raw)
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		compression, data = cDeflate, buf.Bytes()
+	}
+
+	bitsPerSample := make([]uint32, spp)
+	for i := range bitsPerSample {
+		bitsPerSample[i] = uint32(bps)
+	}
+	dataOffset := uint32(8)
+	entries := []ifdEntry{
+		{tImageWidth, dtLong, []uint32{uint32(b.Dx())}},
+		{tImageLength, dtLong, []uint32{uint32(b.Dy())}},
+		{tBitsPerSample, dtShort, bitsPerSample},
+		{tCompression, dtShort, []uint32{compression}},
+		{tPhotometricInterpretation, dtShort, []uint32{photometric}},
+		{tStripOffsets, dtLong, []uint32{dataOffset}},
+		{tSamplesPerPixel, dtShort, []uint32{uint32(spp)}},
+		{tRowsPerStrip, dtLong, []uint32{uint32(b.Dy())}},
+		{tStripByteCounts, dtLong, []uint32{uint32(len(data))}},
+		// 72 dpi
+		{tXResolution, dtRational, []uint32{72, 1}},
+		{tYResolution, dtRational, []uint32{72, 1}},
+		{tResolutionUnit, dtShort, []uint32{2}},
+	}
+	if o.Predictor && o.Compression != Uncompressed {
+		entries = append(entries, ifdEntry{tPredictor, dtShort, []uint32{prHorizontal}})
+	}
+	if colorMap != nil {
+		entries = append(entries, ifdEntry{tColorMap, dtShort, colorMap})
+	}
+	if extra != nil {
+		entries = append(entries, ifdEntry{tExtraSamples, dtShort, extra})
+	}
+
+	// 画像データの直後に IFD を置く。IFD は2バイト境界に揃える必要がある。
+	ifdOffset := dataOffset + uint32(len(data))
+	pad := ifdOffset % 2
+	ifdOffset += pad
+
+	var hdr [8]byte
+	copy(hdr[:], leHeader)
+	binary.LittleEndian.PutUint32(hdr[4:], ifdOffset)
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if pad != 0 {
+		if _, err := w.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write(writeIFD(entries, ifdOffset))
+	return err
+}
+
+func applyPredictor(row []byte, spp, bps int) {
+	if bps == 16 {
+		for i := len(row) - 2; i >= 2*spp; i -= 2 {
+			v := binary.LittleEndian.Uint16(row[i:]) - binary.LittleEndian.Uint16(row[i-2*spp:])
+			binary.LittleEndian.PutUint16(row[i:], v)
+		}
+		return
+	}
+	for i := len(row) - 1; i >= spp; i-- {
+		row[i] -= row[i-spp]
+	}
+}
+
+// writeIFD returns the IFD located at offset followed by the values which do not fit in the entries.
+func writeIFD(entries []ifdEntry, offset uint32) []byte {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+
+	size := 2 + len(entries)*ifdEntrySize + 4
+	ifd := make([]byte, size)
+	var extra []byte
+	le := binary.LittleEndian
+	le.PutUint16(ifd, uint16(len(entries)))
+	for i, e := range entries {
+		var v []byte
+		switch e.typ {
+		case dtShort:
+			v = make([]byte, 2*len(e.values))
+			for j, x := range e.values {
+				le.PutUint16(v[2*j:], uint16(x))
+			}
+		default:
+			v = make([]byte, 4*len(e.values))
+			for j, x := range e.values {
+				le.PutUint32(v[4*j:], x)
+			}
+		}
+
+		count := len(e.values)
+		if e.typ == dtRational {
+			count /= 2
+		}
+		p := ifd[2+i*ifdEntrySize:]
+		le.PutUint16(p[0:], uint16(e.tag))
+		le.PutUint16(p[2:], uint16(e.typ))
+		le.PutUint32(p[4:], uint32(count))
+		if len(v) <= 4 {
+			copy(p[8:], v)
+		} else {
+			le.PutUint32(p[8:], offset+uint32(size+len(extra)))
+			extra = append(extra, v...)
+		}
+	}
+
+	return append(ifd, extra...)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/converter"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func (cli *CLI) runConvert(args []string) int {
+	def := converter.DefaultOptions()
+	fs := cli.flagSet("convert", "Converts the images under the target directory, or the images listed in a file.")
+	from := fs.String("from", "", "extension of the images to convert (required)")
+	list := fs.String("list", "", "file listing the images to convert instead of a directory, or - for the standard input")
+	nul := fs.Bool("0", false, "the entries of -list are separated by NUL instead of newlines")
+	to := fs.String("to", "", "extension to convert to (required unless -preset is set)")
+	out := fs.String("o", "output", "output directory")
+	layout := fs.String("layout", "flat", "flat or mirror to keep the directories of the sources")
+	quality := fs.Int("quality", def.Quality, "JPEG quality from 1 to 100")
+	maxBytes := fs.Int("max-bytes", 0, "maximum size of each JPEG output, lowering the quality to fit (0 means no limit)")
+	minQuality := fs.Int("min-quality", def.MinQuality, "lowest JPEG quality tried for -max-bytes")
+	colors := fs.Int("colors", 0, "maximum number of colors of PNG outputs (0 keeps full color)")
+	dither := fs.Bool("dither", false, "dither PNG outputs reduced by -colors")
+	tiffCompression := fs.String("tiff-compression", "none", "compression of TIFF outputs: none, lzw or deflate")
+	icoSizes := intList{}
+	fs.Var(&icoSizes, "ico-sizes", "comma-separated sizes packed in ICO outputs (default 16,32,48,256)")
+	depth := fs.Int("depth", 0, "8 to round 16-bit samples to 8 bits (0 keeps them in PNG, TIFF, PGM and PPM)")
+	verify := fs.Bool("verify", false, "decode each output again and fail if it differs too much")
+	minPSNR := fs.Float64("min-psnr", def.MinPSNR, "lowest PSNR in dB of lossy outputs with -verify")
+	minSSIM := fs.Float64("min-ssim", def.MinSSIM, "lowest SSIM of lossy outputs with -verify")
+	maxPixels := fs.Int64("max-pixels", def.MaxPixels, "largest number of pixels of a source (0 means no limit)")
+	memoryBudget := fs.Int64("memory-budget", def.MemoryBudget>>20, "estimated memory in MiB of the sources converted at the same time (0 means no limit)")
+	workers := fs.Int("workers", 0, "number of sources converted at the same time (0 means the number of CPUs)")
+	trim := fs.Bool("trim", false, "remove the uniform borders of each image")
+	trimMode := fs.String("trim-mode", "color", "color to trim the pixels close to the top-left one, or alpha to trim transparent pixels")
+	trimTolerance := fs.Float64("trim-tolerance", 0, "largest difference from the border color, or largest alpha, from 0 to 1")
+	trimPadding := fs.Int("trim-padding", 0, "width in pixels of the border added after trimming, up to 1024")
+	filters := stringList{}
+	fs.Var(&filters, "filter", "filter applied to each image in the order given: "+strings.Join(converter.FilterUsages(), ", ")+" (repeatable)")
+	watermark := fs.String("watermark", "", "image composited onto each output")
+	anchor := fs.String("watermark-anchor", "bottom-right", "position of the watermark, such as top-left or center")
+	margin := fs.Int("watermark-margin", 0, "distance in pixels of the watermark from the edges")
+	opacity := fs.Float64("watermark-opacity", 1, "opacity of the watermark, above 0 and up to 1")
+	scale := fs.Float64("watermark-scale", 0, "width of the watermark relative to the output (0 keeps its size)")
+	caption := fs.String("caption", "", "text drawn onto each output, with {name}, {path}, {date} and {time} of the source")
+	captionAnchor := fs.String("caption-anchor", "bottom-left", "position of the caption, such as top-left or center")
+	captionMargin := fs.Int("caption-margin", 0, "distance in pixels of the caption from the edges")
+	captionScale := fs.Int("caption-scale", 1, "size in pixels of each dot of the 5x8 font")
+	captionColor := fs.String("caption-color", "#ffffff", "color of the caption in #rrggbb or #rrggbbaa")
+	captionBackground := fs.String("caption-background", "", "color of the box behind the caption, such as #00000080 (default no box)")
+	captionPadding := fs.Int("caption-padding", 0, "space in pixels between the caption and the edges of its box")
+	preset := fs.String("preset", "", "favicon for favicon bundles or responsive for srcset images")
+	widths := intList{}
+	fs.Var(&widths, "widths", "comma-separated widths of -preset responsive (default 320,640,1280)")
+	nameTemplate := fs.String("name-template", converter.DefaultNameTemplate, "names of the outputs of -preset responsive")
+	meta := fs.String("metadata", "strip", "keep to copy the ICC profile and EXIF without GPS of JPEG and PNG sources and write text chunks to PNG outputs, or strip")
+	text := textFlag{}
+	fs.Var(text, "text", "key=value text chunk written to PNG outputs, such as Copyright=ACME, whose Copyright also goes to JPEG outputs (repeatable)")
+	commands := commandFlags{}
+	fs.Var(commands.part("decode"), "decoder", "ext=command converting the unsupported format ext from stdin to a supported format on stdout (repeatable)")
+	fs.Var(commands.part("encode"), "encoder", "ext=command converting PNG from stdin to the unsupported format ext on stdout, with {quality}, {width} and {height} (repeatable)")
+	commandTimeout := fs.Float64("command-timeout", def.CommandTimeout, "time limit in seconds of each run of -decoder and -encoder (0 means no limit)")
+	if err := fs.Parse(args); err != nil {
+		return exitCode(err)
+	}
+	// 対象はディレクトリか -list のどちらか一方で指定する。
+	if (fs.NArg() == 1) == (*list != "") || fs.NArg() > 1 || *from == "" || (*to == "" && *preset == "") {
+		fs.Usage()
+		return 1
+	}
+
+	opts := &converter.Options{
+		Quality:         *quality,
+		MaxBytes:        *maxBytes,
+		MinQuality:      *minQuality,
+		Colors:          *colors,
+		Dither:          *dither,
+		TIFFCompression: *tiffCompression,
+		Verify:          *verify,
+		MinPSNR:         *minPSNR,
+		MinSSIM:         *minSSIM,
+		Depth:           *depth,
+		ICOSizes:        icoSizes,
+		Preset:          *preset,
+		Widths:          widths,
+		NameTemplate:    *nameTemplate,
+		MaxPixels:       *maxPixels,
+		MemoryBudget:    *memoryBudget << 20,
+		Workers:         *workers,
+		Metadata:        *meta,
+		Text:            text,
+		Commands:        commands,
+		CommandTimeout:  *commandTimeout,
+		OutDir:          *out,
+		Layout:          *layout,
+	}
+	if *trim {
+		opts.Trim = &converter.Trim{Mode: *trimMode, Tolerance: *trimTolerance, Padding: *trimPadding}
+	}
+	opts.Filters = filters
+	if *watermark != "" {
+		opts.Watermark = &converter.Watermark{
+			Path:    *watermark,
+			Anchor:  *anchor,
+			Margin:  *margin,
+			Opacity: opacity,
+			Scale:   *scale,
+		}
+	}
+	if *caption != "" {
+		opts.Caption = &converter.Caption{
+			Text:       *caption,
+			Anchor:     *captionAnchor,
+			Margin:     *captionMargin,
+			Scale:      *captionScale,
+			Color:      *captionColor,
+			Background: *captionBackground,
+			Padding:    *captionPadding,
+		}
+	}
+
+	var report *converter.Report
+	var err error
+	if *list != "" {
+		var paths []string
+		if paths, err = cli.readList(*list, *nul); err == nil {
+			report, err = converter.ConvertList(paths, *from, *to, opts)
+		}
+	} else {
+		report, err = converter.Convert(fs.Arg(0), *from, *to, opts)
+	}
+	if err != nil {
+		fmt.Fprintln(cli.errStream, err)
+		return 1
+	}
+	for _, s := range report.Skipped {
+		fmt.Fprintf(cli.errStream, "skipped %s: not a file\n", s)
+	}
+	if len(report.Files) == 0 {
+		fmt.Fprintln(cli.outStream, "Files with extension you specified not found")
+		return 0
+	}
+	fmt.Fprintf(cli.outStream, "%d files converted! see under %s\n", len(report.Files), *out)
+
+	flagged := report.Flagged()
+	for _, f := range flagged {
+		printFlagged(cli, f)
+	}
+	if len(flagged) > 0 {
+		fmt.Fprintf(cli.errStream, "%d files failed verification\n", len(flagged))
+		return 1
+	}
+
+	return 0
+}
+
+// readList reads the paths listed in the file name, or in the input stream if name is "-".
+func (cli *CLI) readList(name string, nul bool) ([]string, error) {
+	if name == "-" {
+		return converter.ReadList(cli.inStream, nul)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return converter.ReadList(f, nul)
+}
+
+func printFlagged(cli *CLI, f converter.FileResult) {
+	v := f.Verification
+	if v.Lossless {
+		fmt.Fprintf(cli.outStream, "flagged %s: lossless output differs, PSNR %.2f dB\n", f.Dst, v.PSNR)
+		return
+	}
+	fmt.Fprintf(cli.outStream, "flagged %s: PSNR %.2f dB, SSIM %.4f\n", f.Dst, v.PSNR, v.SSIM)
+}
+
+// commandFlags are the commands given by -decoder and -encoder, by extension.
+type commandFlags map[string]converter.Command
+
+// part returns the flag which sets the decode or encode command of the extensions.
+func (c commandFlags) part(kind string) flag.Value {
+	return &commandFlag{commands: c, kind: kind}
+}
+
+// commandFlag is a flag of ext=command, where the command is split at white spaces.
+type commandFlag struct {
+	commands commandFlags
+	kind     string
+}
+
+var _ flag.Value = (*commandFlag)(nil)
+
+func (f *commandFlag) String() string {
+	return ""
+}
+
+func (f *commandFlag) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i < 0 {
+		return errors.New("want ext=command")
+	}
+	ext, args := strings.ToLower(strings.TrimPrefix(value[:i], ".")), strings.Fields(value[i+1:])
+	if ext == "" || len(args) == 0 {
+		return errors.New("want ext=command")
+	}
+
+	c := f.commands[ext]
+	if f.kind == "decode" {
+		c.Decode = args
+	} else {
+		c.Encode = args
+	}
+	f.commands[ext] = c
+	return nil
+}
+
+// textFlag is a flag of key=value, which adds a text chunk.
+type textFlag map[string]string
+
+var _ flag.Value = textFlag(nil)
+
+func (f textFlag) String() string {
+	return ""
+}
+
+func (f textFlag) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i < 1 {
+		return errors.New("want key=value")
+	}
+	f[value[:i]] = value[i+1:]
+	return nil
+}
+
+// stringList is a flag which adds its value each time it is set.
+type stringList []string
+
+var _ flag.Value = (*stringList)(nil)
+
+func (l *stringList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// intList is a flag of comma-separated integers.
+type intList []int
+
+var _ flag.Value = (*intList)(nil)
+
+func (l *intList) String() string {
+	var s []string
+	for _, n := range *l {
+		s = append(s, strconv.Itoa(n))
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *intList) Set(value string) error {
+	*l = nil
+	for _, s := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		*l = append(*l, n)
+	}
+	return nil
+}
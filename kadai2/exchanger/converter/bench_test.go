@@ -0,0 +1,170 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"image/draw"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// benchSizes are the sizes of the images the benchmarks convert.
+var benchSizes = []int{64, 512, 2048}
+
+// benchFormats are the formats the benchmarks decode and encode.
+var benchFormats = []string{"jpg", "png", "bmp", "qoi", "tiff", "ppm"}
+
+// benchImage returns a square image of size which has both smooth and sharp areas.
+func benchImage(size int) image.Image {
+	img := helper.Gradient(size, size)
+	// 下半分は市松模様にする。
+	checker := helper.Checker(size, size, 8, color.Black, color.White)
+	draw.Draw(img, image.Rect(0, size/2, size, size), checker, image.Point{0, size / 2}, draw.Src)
+	return img
+}
+
+func encodeBench(b *testing.B, ext string, img image.Image, opts *Options) []byte {
+	b.Helper()
+	var buf bytes.Buffer
+	if err := formats[ext].encode(&buf, img, opts); err != nil {
+		b.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func BenchmarkDecode(b *testing.B) {
+	for _, size := range benchSizes {
+		img := benchImage(size)
+		for _, ext := range benchFormats {
+			data := encodeBench(b, ext, img, DefaultOptions())
+			b.Run(fmt.Sprintf("%s/%d", ext, size), func(b *testing.B) {
+				b.SetBytes(int64(size * size * 4))
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	for _, size := range benchSizes {
+		img := benchImage(size)
+		for _, ext := range benchFormats {
+			ext := ext
+			b.Run(fmt.Sprintf("%s/%d", ext, size), func(b *testing.B) {
+				benchmarkEncode(b, ext, img, DefaultOptions())
+			})
+		}
+	}
+}
+
+// BenchmarkEncodeSettings measures the encoder settings which trade time for size.
+func BenchmarkEncodeSettings(b *testing.B) {
+	img := benchImage(512)
+	tests := []struct {
+		name, ext string
+		set       func(opts *Options)
+	}{
+		{"jpg/quality-50", "jpg", func(opts *Options) { opts.Quality = 50 }},
+		{"jpg/quality-95", "jpg", func(opts *Options) { opts.Quality = 95 }},
+		{"jpg/max-bytes", "jpg", func(opts *Options) { opts.MaxBytes = 20000 }},
+		{"png/colors-16", "png", func(opts *Options) { opts.Colors = 16 }},
+		{"png/colors-16-dither", "png", func(opts *Options) { opts.Colors, opts.Dither = 16, true }},
+		{"tiff/lzw", "tiff", func(opts *Options) { opts.TIFFCompression = "lzw" }},
+		{"tiff/deflate", "tiff", func(opts *Options) { opts.TIFFCompression = "deflate" }},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		b.Run(tt.name, func(b *testing.B) {
+			opts := DefaultOptions()
+			tt.set(opts)
+			benchmarkEncode(b, tt.ext, img, opts)
+		})
+	}
+}
+
+func benchmarkEncode(b *testing.B, ext string, img image.Image, opts *Options) {
+	size := img.Bounds().Size()
+	b.SetBytes(int64(size.X * size.Y * 4))
+	b.ReportAllocs()
+	var n int
+	for i := 0; i < b.N; i++ {
+		n = len(encodeBench(b, ext, img, opts))
+	}
+	b.ReportMetric(float64(n), "out-bytes")
+}
+
+// benchTree writes n images of size in directories of 10 files and returns the root.
+func benchTree(b *testing.B, n, size int) string {
+	b.Helper()
+	dir := b.TempDir()
+	data := encodeBench(b, "png", benchImage(size), DefaultOptions())
+	for i := 0; i < n; i++ {
+		sub := filepath.Join(dir, fmt.Sprintf("d%03d", i/10))
+		if err := os.MkdirAll(sub, 0777); err != nil {
+			b.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(sub, fmt.Sprintf("img%04d.png", i)), data, 0666); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func BenchmarkWalk(b *testing.B) {
+	for _, n := range []int{100, 1000} {
+		dir := benchTree(b, n, 1)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				fileNames := make(chan string)
+				go func() {
+					walkDir(dir, hasExt("png"), fileNames)
+					close(fileNames)
+				}()
+				count := 0
+				for range fileNames {
+					count++
+				}
+				if count != n {
+					b.Fatalf("walked %d files, want %d", count, n)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkConvert measures the conversion of a tree by the number of workers.
+func BenchmarkConvert(b *testing.B) {
+	src := benchTree(b, 32, 512)
+	workers := []int{1, 2, 4}
+	if n := runtime.GOMAXPROCS(0); n > 4 {
+		workers = append(workers, n)
+	}
+	for _, w := range workers {
+		w := w
+		b.Run(fmt.Sprintf("workers-%d", w), func(b *testing.B) {
+			opts := DefaultOptions()
+			opts.Workers = w
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				opts.OutDir = b.TempDir()
+				b.StartTimer()
+				if _, err := Convert(src, "png", "jpg", opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package converter
+
+import (
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/imaging"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Caption is text drawn onto each output with the built-in 5x8 bitmap font.
+type Caption struct {
+	// Text is drawn with {name}, {path}, {date} and {time} replaced by the base name and the path
+	// of the source, and the date and the time it was modified, or converted if it is not a file.
+	// Lines are separated by "\n" and the characters outside ASCII are drawn as "?".
+	Text string `json:"text"`
+	// Anchor is where the text is placed, as in Watermark. Empty means "bottom-left".
+	Anchor string `json:"anchor"`
+	// Margin is the distance in pixels of the box from the edges of the output.
+	Margin int `json:"margin"`
+	// Scale is the size in pixels of each dot of the font. Zero is treated as 1.
+	Scale int `json:"scale"`
+	// Color is the color of the text in "#rrggbb" or "#rrggbbaa". Empty means white.
+	Color string `json:"color"`
+	// Background is the color of the box behind the text. Empty draws no box.
+	Background string `json:"background"`
+	// Padding is the space in pixels between the text and the edges of the box.
+	Padding int `json:"padding"`
+}
+
+func captionOperation(c *Caption) (operation, error) {
+	name := c.Anchor
+	if name == "" {
+		name = "bottom-left"
+	}
+	anchor, err := imaging.ParseAnchor(name)
+	if err != nil {
+		return nil, err
+	}
+	if c.Margin < 0 || c.Padding < 0 {
+		return nil, fmt.Errorf("caption margin and padding must not be negative")
+	}
+	if c.Scale < 0 || c.Scale > 64 {
+		return nil, fmt.Errorf("caption scale must be between 1 and 64")
+	}
+	scale := c.Scale
+	if scale == 0 {
+		scale = 1
+	}
+
+	fg := color.NRGBA{255, 255, 255, 255}
+	if c.Color != "" {
+		if fg, err = imaging.ParseColor(c.Color); err != nil {
+			return nil, fmt.Errorf("caption: %w", err)
+		}
+	}
+	var bg *image.Uniform
+	if c.Background != "" {
+		b, err := imaging.ParseColor(c.Background)
+		if err != nil {
+			return nil, fmt.Errorf("caption: %w", err)
+		}
+		bg = image.NewUniform(b)
+	}
+
+	return func(img image.Image, src string) (image.Image, error) {
+		text := expandCaption(c.Text, src)
+		size := imaging.TextSize(text, scale)
+		if size.X == 0 {
+			return img, nil
+		}
+
+		dst := imaging.Clone(img)
+		box := imaging.Place(img.Bounds(), size.Add(image.Pt(2*c.Padding, 2*c.Padding)), anchor, c.Margin)
+		if bg != nil {
+			draw.Draw(dst, box, bg, image.Point{}, draw.Over)
+		}
+		imaging.DrawText(dst, box.Min.Add(image.Pt(c.Padding, c.Padding)), text, scale, fg)
+
+		return dst, nil
+	}, nil
+}
+
+// expandCaption replaces the placeholders of text with the name and the time of the source file src.
+func expandCaption(text, src string) string {
+	t, name := now(), ""
+	if src != "" {
+		if st, err := os.Stat(src); err == nil {
+			t = st.ModTime()
+		}
+		name = filepath.Base(src)
+	}
+
+	return strings.NewReplacer(
+		"{name}", name,
+		"{path}", filepath.ToSlash(src),
+		"{date}", t.Format("2006-01-02"),
+		"{time}", t.Format("15:04:05"),
+	).Replace(text)
+}
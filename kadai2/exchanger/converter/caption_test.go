@@ -0,0 +1,64 @@
+package converter
+
+import (
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConvertCaption(t *testing.T) {
+	gray := color.NRGBA{128, 128, 128, 255}
+	src := helper.WriteTree(t, map[string]image.Image{
+		"shot.png": helper.Framed(64, 32, 1, gray, color.Black),
+	}, nil)
+	modified := time.Date(2021, 3, 4, 5, 6, 7, 0, time.Local)
+	if err := os.Chtimes(filepath.Join(src, "shot.png"), modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		caption   Caption
+		wantError bool
+	}{
+		{"caption-name", Caption{Text: "{name}", Anchor: "top-left", Margin: 2, Color: "#ff0", Background: "#000000c0", Padding: 1}, false},
+		{"caption-timestamp", Caption{Text: "{date}\n{time}", Background: "#000", Padding: 2}, false},
+		{"caption-bottom-right", Caption{Text: "OK", Anchor: "bottom-right", Margin: 1, Scale: 3, Color: "#00ff00"}, false},
+		{"", Caption{Text: "x", Anchor: "middle"}, true},
+		{"", Caption{Text: "x", Color: "red"}, true},
+		{"", Caption{Text: "x", Background: "#12345"}, true},
+		{"", Caption{Text: "x", Scale: -1}, true},
+		{"", Caption{Text: "x", Padding: -1}, true},
+	}
+
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.OutDir = t.TempDir()
+		c := tt.caption
+		opts.Caption = &c
+		r, err := Convert(src, "png", "png", opts)
+		helper.TestWantError(t, err, tt.wantError)
+		if err != nil {
+			continue
+		}
+		golden := filepath.Join("testdata", "golden", tt.name, "shot.png")
+		helper.TestGoldenImage(t, helper.DecodeFile(t, r.Files[0].Dst), golden, 0)
+	}
+}
+
+func TestExpandCaption(t *testing.T) {
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC) }
+
+	if got, want := expandCaption("{name} {path} {date} {time}", ""), "  2021-03-04 05:06:07"; got != want {
+		t.Errorf("without a file = %q, want %q", got, want)
+	}
+	got := expandCaption("{name}: {path}", filepath.Join("testdata", "verify", "gradient.jpg"))
+	if want := "gradient.jpg: testdata/verify/gradient.jpg"; got != want {
+		t.Errorf("with a file = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,142 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrCommandTimeout is returned when a command runs longer than Options.CommandTimeout.
+var ErrCommandTimeout = errors.New("command timed out")
+
+// Command is a pair of external programs which read and write a format the converter does
+// not support, such as HEIC. Each is a program and its arguments, run without a shell,
+// which reads the whole input from its standard input and writes the output to its standard output.
+type Command struct {
+	// Decode converts a file of the format to any format the converter supports natively, such as PNG.
+	Decode []string `json:"decode"`
+	// Encode converts a PNG image to the format. {quality}, {width} and {height} in the
+	// arguments are replaced with Options.Quality and the size of the image.
+	Encode []string `json:"encode"`
+}
+
+// decodes reports whether the format ext can be read natively or with a command of o.
+func (o *Options) decodes(ext string) bool {
+	_, ok := formats[ext]
+	return ok || len(o.Commands[ext].Decode) > 0
+}
+
+// encodes reports whether the format ext can be written natively or with a command of o.
+func (o *Options) encodes(ext string) bool {
+	_, ok := formats[ext]
+	return ok || len(o.Commands[ext].Encode) > 0
+}
+
+// decoder returns the decode command of the file path, or nil if it is read natively.
+func (o *Options) decoder(path string) []string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if _, ok := formats[ext]; ok {
+		return nil
+	}
+	return o.Commands[ext].Decode
+}
+
+// decodeCommand converts data with the command args and checks the header of the result
+// against the limits of opts before decoding it. name is used in the errors.
+func decodeCommand(name string, data []byte, args []string, opts *Options) (image.Image, error) {
+	out, err := runCommand(args, data, nil, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(out))
+	if err != nil {
+		return nil, fmt.Errorf("%s: output of %s: %w", name, args[0], err)
+	}
+	if _, err := checkConfig(name, cfg, opts); err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(out))
+	return img, err
+}
+
+// encodeCommand writes img encoded by the command args to w.
+func encodeCommand(w io.Writer, img image.Image, args []string, opts *Options) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	size := img.Bounds().Size()
+	vars := map[string]string{
+		"{quality}": strconv.Itoa(opts.Quality),
+		"{width}":   strconv.Itoa(size.X),
+		"{height}":  strconv.Itoa(size.Y),
+	}
+
+	out, err := runCommand(args, buf.Bytes(), vars, opts)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// runCommand runs args with the variables vars replaced, giving it stdin, and returns its
+// standard output. The standard error is added to the error if the command fails.
+func runCommand(args []string, stdin []byte, vars map[string]string, opts *Options) ([]byte, error) {
+	ctx := context.Background()
+	if opts.CommandTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(opts.CommandTimeout*float64(time.Second)))
+		defer cancel()
+	}
+
+	expanded := make([]string, len(args))
+	for i, a := range args {
+		for k, v := range vars {
+			a = strings.ReplaceAll(a, k, v)
+		}
+		expanded[i] = a
+	}
+	cmd := exec.CommandContext(ctx, expanded[0], expanded[1:]...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("%s: %w after %gs", args[0], ErrCommandTimeout, opts.CommandTimeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", args[0], err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", args[0], err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func validateCommands(commands map[string]Command) error {
+	for ext, c := range commands {
+		if ext != strings.ToLower(ext) || ext == "" || strings.HasPrefix(ext, ".") {
+			return fmt.Errorf("command format %q must be a lower case extension without a dot", ext)
+		}
+		if _, ok := formats[ext]; ok {
+			return fmt.Errorf("%s is supported without a command", ext)
+		}
+		if len(c.Decode) == 0 && len(c.Encode) == 0 {
+			return fmt.Errorf("command for %s has neither decode nor encode", ext)
+		}
+	}
+
+	return nil
+}
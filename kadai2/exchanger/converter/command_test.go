@@ -0,0 +1,97 @@
+package converter
+
+import (
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvertCommand(t *testing.T) {
+	for _, name := range []string{"cat", "sh", "sleep"} {
+		if _, err := exec.LookPath(name); err != nil {
+			t.Skipf("%s is not found", name)
+		}
+	}
+
+	// raw は PNG をそのまま入れた形式として扱う。
+	cat := map[string]Command{"raw": {Decode: []string{"cat"}, Encode: []string{"cat"}}}
+	tests := []struct {
+		name     string
+		from, to string
+		commands map[string]Command
+		opts     func(opts *Options)
+		// エラーメッセージの正規表現
+		err string
+	}{
+		{"encode", "png", "raw", cat, nil, ""},
+		{"decode", "raw", "bmp", cat, nil, ""},
+		{"raw to raw", "raw", "raw", cat, func(opts *Options) { opts.Trim = &Trim{} }, ""},
+		{"verify", "png", "raw", cat, func(opts *Options) { opts.Verify = true }, ""},
+		{"template", "png", "raw", map[string]Command{"raw": {Encode: []string{"sh", "-c", "test {quality}:{width}x{height} = 42:8x4 && cat"}}},
+			func(opts *Options) { opts.Quality = 42 }, ""},
+		{"failure", "png", "raw", map[string]Command{"raw": {Encode: []string{"sh", "-c", "echo broken >&2; exit 3"}}}, nil,
+			"sh: exit status 3: broken"},
+		{"timeout", "png", "raw", map[string]Command{"raw": {Encode: []string{"sleep", "5"}}},
+			func(opts *Options) { opts.CommandTimeout = 0.2 }, "command timed out after 0.2s"},
+		{"too large", "raw", "png", cat, func(opts *Options) { opts.MaxPixels = 10 }, "image is too large"},
+		{"not an image", "raw", "png", map[string]Command{"raw": {Decode: []string{"echo", "hello"}}}, nil, "output of echo: image: unknown format"},
+		{"no decoder", "raw", "png", map[string]Command{"raw": {Encode: []string{"cat"}}}, nil, "from is not supported"},
+		{"native", "png", "jpg", map[string]Command{"jpg": {Encode: []string{"cat"}}}, nil, "jpg is supported without a command"},
+		{"upper case", "png", "raw", map[string]Command{"RAW": {Encode: []string{"cat"}}}, nil, "must be a lower case extension"},
+		{"empty", "png", "raw", map[string]Command{"raw": {}}, nil, "neither decode nor encode"},
+	}
+
+	src := helper.WriteTree(t, map[string]image.Image{"png/a.png": helper.Gradient(8, 4), "raw/b.raw": helper.Gradient(8, 4)},
+		map[string]helper.Encoder{"raw": helper.Encoders["png"]})
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.OutDir = t.TempDir()
+		opts.Commands = tt.commands
+		if tt.opts != nil {
+			tt.opts(opts)
+		}
+		r, err := Convert(filepath.Join(src, tt.from), tt.from, tt.to, opts)
+		helper.TestErrorMatch(t, err, tt.err)
+		if err != nil {
+			continue
+		}
+
+		if len(r.Files) != 1 || filepath.Ext(r.Files[0].Dst) != "."+tt.to {
+			t.Errorf("%s: wrote %+v", tt.name, r.Files)
+			continue
+		}
+		if v := r.Files[0].Verification; opts.Verify && (v == nil || v.Flagged) {
+			t.Errorf("%s: verification = %+v", tt.name, v)
+		}
+		// raw の出力は PNG として読める。
+		if err := helper.CompareImages(helper.Gradient(8, 4), helper.DecodeFile(t, r.Files[0].Dst), 0); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
+
+func TestIncludesCommand(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Commands = map[string]Command{"heic": {Decode: []string{"heif-dec"}}}
+	match, err := includes("src", nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		path string
+		want bool
+	}{
+		{"src/a.HEIC", true}, {"src/a.png", true}, {"src/a.webp", false},
+	} {
+		if got := match(tt.path); got != tt.want {
+			t.Errorf("includes(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	if !strings.Contains(opts.decoder("a.HEIC")[0], "heif") || opts.decoder("a.png") != nil {
+		t.Error("decoder does not pick the command of the unsupported format")
+	}
+}
@@ -1,95 +1,568 @@
 package converter
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"gopher-dojo/kadai2/exchanger/codec/metadata"
+	"gopher-dojo/kadai2/exchanger/imaging"
 	"image"
 	"image/jpeg"
-	"image/png"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 )
 
+// ErrBudgetExceeded is returned when an image cannot be encoded within Options.MaxBytes.
+var ErrBudgetExceeded = errors.New("cannot fit in max bytes")
+
+// ErrInvalidInput is matched by the errors of ConvertImage caused by its input: the format to,
+// the options or the image data.
+var ErrInvalidInput = errors.New("invalid input")
+
+// Options configures a conversion.
+type Options struct {
+	// Quality is the JPEG quality, ranging from 1 to 100 inclusive.
+	Quality int `json:"quality"`
+	// MaxBytes is the upper limit of the encoded size of each JPEG output. Zero means no limit.
+	MaxBytes int `json:"maxBytes"`
+	// MinQuality is the lowest JPEG quality tried to fit an image within MaxBytes.
+	MinQuality int `json:"minQuality"`
+	// Colors is the maximum number of colors of each PNG output.
+	// If it is set, the output is written as a paletted PNG. Zero keeps full color.
+	Colors int `json:"colors"`
+	// Dither applies Floyd-Steinberg dithering when Colors is set.
+	Dither bool `json:"dither"`
+	// TIFFCompression is the compression of TIFF outputs: "none", "lzw" or "deflate".
+	// Empty means "none".
+	TIFFCompression string `json:"tiffCompression"`
+	// Verify re-decodes every output and compares it with the image which was encoded.
+	Verify bool `json:"verify"`
+	// MinPSNR is the lowest PSNR in decibels a lossy output may have without being flagged.
+	MinPSNR float64 `json:"minPSNR"`
+	// MinSSIM is the lowest SSIM a lossy output may have without being flagged.
+	MinSSIM float64 `json:"minSSIM"`
+	// ICOSizes are the sizes of the square renditions packed in each ICO output,
+	// up to 256. Empty means 16, 32, 48 and 256.
+	ICOSizes []int `json:"icoSizes"`
+	// Preset writes a set of outputs for each source instead of a single file.
+	// "favicon" writes the favicon bundle under <OutDir>/<name>/ and ignores the target format.
+	// "responsive" writes each source in Widths and the srcset manifests in the target
+	// format, or in the format of the source if it is empty.
+	Preset string `json:"preset"`
+	// Widths are the widths of the responsive preset. Sources are not enlarged.
+	// Empty means 320, 640 and 1280.
+	Widths []int `json:"widths"`
+	// Depth is 8 to round 16-bit samples to 8 bits in every output.
+	// Zero keeps 16-bit samples in the formats which support them: PNG, TIFF, PGM and PPM.
+	Depth int `json:"depth"`
+	// NameTemplate names the outputs of the responsive preset with {name}, {width},
+	// {height} and {ext}. Empty means DefaultNameTemplate.
+	NameTemplate string `json:"nameTemplate"`
+	// OutDir is the directory the outputs are written to. Empty means "output".
+	OutDir string `json:"-"`
+	// MaxPixels is the largest number of pixels of a source. Zero means no limit.
+	MaxPixels int64 `json:"maxPixels"`
+	// MemoryBudget limits the estimated memory in bytes of the sources converted at the same time.
+	// A source which needs more than the budget alone fails with ErrTooLarge. Zero means no limit.
+	MemoryBudget int64 `json:"memoryBudget"`
+	// Workers is the number of sources converted at the same time. Zero means GOMAXPROCS.
+	Workers int `json:"workers"`
+	// Commands are the external programs which read and write the formats the converter
+	// does not support natively, by their lower case extensions.
+	Commands map[string]Command `json:"commands"`
+	// CommandTimeout is the time limit in seconds of each run of a command. Zero means no limit.
+	CommandTimeout float64 `json:"commandTimeout"`
+	// Metadata is "keep" to copy the ICC profile and the EXIF of JPEG and PNG sources into
+	// JPEG and PNG outputs, without the GPS tags, and to write the name of the source and
+	// the copyright into PNG text chunks. The copyright of PNG sources goes into the EXIF
+	// of JPEG outputs. Empty or "strip" writes no metadata.
+	// ICC profiles are copied only if they match the colors of the output.
+	Metadata string `json:"metadata"`
+	// Text are PNG text chunks by their keywords, such as "Copyright", written to PNG
+	// outputs in addition to or instead of those of Metadata "keep". Of them, JPEG outputs
+	// carry only the Copyright, as the copyright of the EXIF.
+	Text map[string]string `json:"text"`
+	// Layout is "flat" to write all the outputs in OutDir, or "mirror" to keep
+	// the directories of the sources. Empty means "flat".
+	Layout string `json:"-"`
+
+	Operations `json:"-"`
+}
+
+// Operations are applied to each image before it is encoded.
+type Operations struct {
+	// Trim removes the uniform borders of each image if it is set.
+	Trim *Trim `json:"trim"`
+	// Filters adjust the colors of each image in order, before the watermark and the caption
+	// are drawn. Each is a name of FilterUsages with its arguments, such as "gamma=2.2",
+	// "unsharp=1,0.8" or "invert".
+	Filters []string `json:"filters"`
+	// Watermark is composited onto each output if it is set.
+	Watermark *Watermark `json:"watermark"`
+	// Caption is drawn onto each output, over the watermark, if it is set.
+	Caption *Caption `json:"caption"`
+}
+
+// DefaultOptions returns the options ConvertEtx uses.
+func DefaultOptions() *Options {
+	return &Options{
+		Quality:    jpeg.DefaultQuality,
+		MinQuality: 10,
+		MinPSNR:    30,
+		MinSSIM:    0.9,
+		// 20000x20000 のような画像で数 GB を確保しないようにする。
+		MaxPixels:      100_000_000,
+		MemoryBudget:   2 << 30,
+		CommandTimeout: 60,
+	}
+}
+
 // ConvertEtx converts the image files in the specified directories to specified extension.
 func ConvertEtx(src, from, to string) (int, error) {
+	r, err := Convert(src, from, to, DefaultOptions())
+	return len(r.Files), err
+}
+
+// Convert converts the image files in the specified directories to specified extension with opts.
+// The returned report is never nil and contains the files converted before an error occurred.
+// The sources are converted concurrently within opts.Workers and opts.MemoryBudget, and each
+// source is checked against opts.MaxPixels by its header before it is decoded.
+// Converting a format to itself is allowed only if opts changes the images.
+func Convert(src, from, to string, opts *Options) (*Report, error) {
 	from = strings.ToLower(from)
 	to = strings.ToLower(to)
 
-	if err := validateArgs(from, to); err != nil {
-		return 0, err
+	report := &Report{}
+	if err := validateOptions(opts); err != nil {
+		return report, err
+	}
+	if err := validateArgs(from, to, opts); err != nil {
+		return report, err
+	}
+
+	return report, convert(src, walked(src, hasExt(from)), []string{to}, opts, report)
+}
+
+// convert decodes each file which files sends once and writes it in every format of targets,
+// adding the outputs to report. The outputs are named after the paths relative to src.
+// The outputs written before an error occurred are also recorded in the history log.
+func convert(src string, files func(fileNames chan<- string), targets []string, opts *Options, report *Report) (err error) {
+	defer func() {
+		if herr := recordRun(report, opts); herr != nil && err == nil {
+			err = fmt.Errorf("history: %w", herr)
+		}
+	}()
+
+	ops, err := operations(opts)
+	if err != nil {
+		return err
+	}
+
+	var ps []preset
+	if opts.Preset != "" {
+		for _, to := range targets {
+			ps = append(ps, presets[opts.Preset](to, opts))
+		}
 	}
 
 	fileNames := make(chan string)
 	go func() {
-		walkDir(src, from, fileNames)
+		files(fileNames)
 		close(fileNames)
 	}()
+	// 途中で return しても送る側の goroutine が止まらないように読み捨てる。
+	defer func() {
+		for range fileNames {
+		}
+	}()
 
-	fileCnt := 0
-	uniqCheck := make(map[string]int)
+	// 同時に変換するファイルの数と、見積もったメモリの合計を制限する。
+	g, ctx := errgroup.WithContext(context.Background())
+	workers := semaphore.NewWeighted(int64(opts.workers()))
+	var memory *semaphore.Weighted
+	if opts.MemoryBudget > 0 {
+		memory = semaphore.NewWeighted(opts.MemoryBudget)
+	}
+
+	// 出力名と結果の順序は walk の順に決め、並行に変換しても変わらないようにする。
+	var mu sync.Mutex
+	var results [][]FileResult
+	var checkErr error
+	names := uniqueNames{}
 	for fn := range fileNames {
-		file, err := os.Open(fn)
+		fn := fn
+		fileName := names.next(outputName(src, fn, opts.Layout))
+		mem, err := checkSize(fn, opts)
 		if err != nil {
-			return fileCnt, err
+			checkErr = err
+			break
+		}
+		if err := workers.Acquire(ctx, 1); err != nil {
+			break
+		}
+		if memory != nil {
+			if err := memory.Acquire(ctx, mem); err != nil {
+				workers.Release(1)
+				break
+			}
 		}
 
-		defer file.Close()
-		img, _, err := image.Decode(file)
+		mu.Lock()
+		i := len(results)
+		results = append(results, nil)
+		mu.Unlock()
+		g.Go(func() error {
+			defer workers.Release(1)
+			if memory != nil {
+				defer memory.Release(mem)
+			}
+
+			rs, err := convertFile(fn, fileName, targets, ps, ops, opts)
+			mu.Lock()
+			results[i] = rs
+			mu.Unlock()
+			return err
+		})
+	}
+
+	err = g.Wait()
+	for _, rs := range results {
+		report.Files = append(report.Files, rs...)
+	}
+	if err != nil {
+		return err
+	}
+	if checkErr != nil {
+		return checkErr
+	}
+
+	for _, p := range ps {
+		results, err := p.close()
+		report.Files = append(report.Files, results...)
 		if err != nil {
-			return fileCnt, err
+			return err
 		}
+	}
 
-		fileName := filename(fn)
-		if _, ok := uniqCheck[fileName]; !ok {
-			uniqCheck[fileName] = 0
-		} else {
-			uniqCheck[fileName]++
-			fileName = fileName + "(" + strconv.Itoa(uniqCheck[fileName]) + ")"
+	return nil
+}
+
+// convertFile converts the file src named name to each format of targets, or with
+// the presets if ps is not nil. It returns the outputs written before an error occurred.
+func convertFile(src, name string, targets []string, ps []preset, ops []operation, opts *Options) ([]FileResult, error) {
+	img, err := load(src, ops, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []FileResult
+	for i, to := range targets {
+		if ps != nil {
+			rs, err := ps[i].write(src, name, img)
+			results = append(results, rs...)
+			if err != nil {
+				return results, err
+			}
+			continue
 		}
 
-		dstFile, err := os.Create(fmt.Sprintf("output/%s.%s", fileName, to))
+		result, err := writeImage(src, filepath.Join(opts.outDir(), name+"."+to), img, to, opts)
 		if err != nil {
-			return fileCnt, err
+			return results, err
 		}
-		defer dstFile.Close()
+		results = append(results, *result)
+	}
 
-		switch to {
-		case "jpg", "jpeg":
-			err = jpeg.Encode(dstFile, img, nil)
-		case "png":
-			err = png.Encode(dstFile, img)
+	return results, nil
+}
+
+// load decodes the file src, with a command of opts if it is not supported natively,
+// and applies ops to it.
+func load(src string, ops []operation, opts *Options) (image.Image, error) {
+	var img image.Image
+	var err error
+	if args := opts.decoder(src); args != nil {
+		var data []byte
+		if data, err = ioutil.ReadFile(src); err == nil {
+			img, err = decodeCommand(src, data, args, opts)
 		}
-		if err != nil {
-			return fileCnt, err
+	} else {
+		img, err = decodeFile(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if img, err = apply(img, src, ops); err != nil {
+		return nil, fmt.Errorf("%s: %w", src, err)
+	}
+	if err := checkImage(src, img, opts); err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
+
+// writeImage encodes img converted from src in the format to, writes it to dst
+// and verifies it if opts asks for it.
+func writeImage(src, dst string, img image.Image, to string, opts *Options) (*FileResult, error) {
+	// 丸めた画像を検証にも使うよう、エンコードの前に 8 ビットにする。
+	img = roundDepth(img, to, opts)
+
+	var buf bytes.Buffer
+	if err := encode(&buf, img, to, opts); err != nil {
+		return nil, fmt.Errorf("%s: %w", src, err)
+	}
+	var data []byte
+	if opts.Metadata == "keep" && carriesMetadata(to) {
+		var err error
+		if data, err = ioutil.ReadFile(src); err != nil {
+			return nil, err
+		}
+	}
+	out, err := withMetadata(buf.Bytes(), data, src, img, to, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", src, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(dst, out, 0666); err != nil {
+		return nil, err
+	}
+
+	result := &FileResult{Src: src, Dst: dst}
+	if opts.Verify {
+		var err error
+		if result.Verification, err = verify(img, dst, to, opts); err != nil {
+			return nil, fmt.Errorf("%s: %w", dst, err)
 		}
-		fileCnt++
 	}
 
-	return fileCnt, nil
+	return result, nil
+}
+
+// ConvertImage decodes an image from r, applies the operations of opts and encodes it in
+// the format to into w. The image is checked against opts.MaxPixels and opts.MemoryBudget
+// before it is decoded. Presets and Verify are not supported since they need files.
+// Errors caused by to, opts or the image data match ErrInvalidInput.
+func ConvertImage(w io.Writer, r io.Reader, to string, opts *Options) error {
+	to = strings.ToLower(to)
+	if !opts.encodes(to) {
+		return invalid(errors.New("to is not supported"))
+	}
+	if err := validateOptions(opts); err != nil {
+		return invalid(err)
+	}
+	if opts.Preset != "" {
+		return invalid(errors.New("presets are not supported"))
+	}
+	ops, err := operations(opts)
+	if err != nil {
+		return invalid(err)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return invalid(err)
+	}
+	if _, err := checkConfig("image", cfg, opts); err != nil {
+		return err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return invalid(err)
+	}
+	if img, err = apply(img, "", ops); err != nil {
+		return err
+	}
+	if err := checkImage("image", img, opts); err != nil {
+		return err
+	}
+
+	img = roundDepth(img, to, opts)
+	var buf bytes.Buffer
+	if err := encode(&buf, img, to, opts); err != nil {
+		return err
+	}
+	out, err := withMetadata(buf.Bytes(), data, "", img, to, opts)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// invalidError marks an error as caused by the input of ConvertImage, keeping its message.
+type invalidError struct {
+	err error
+}
+
+func invalid(err error) error { return &invalidError{err} }
+
+func (e *invalidError) Error() string        { return e.err.Error() }
+func (e *invalidError) Unwrap() error        { return e.err }
+func (e *invalidError) Is(target error) bool { return target == ErrInvalidInput }
+
+// roundDepth rounds the 16-bit samples of img to 8 bits if the format to does not keep them
+// or opts asks for it. The formats written by commands get 8-bit samples.
+func roundDepth(img image.Image, to string, opts *Options) image.Image {
+	if opts.Depth == 8 || !formats[to].deep {
+		return imaging.To8Bit(img)
+	}
+	return img
+}
+
+// SaveImage encodes img in the format of the extension of path with opts and writes it to path.
+func SaveImage(path string, img image.Image, opts *Options) error {
+	to := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if err := validateOptions(opts); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := encode(&buf, img, to, opts); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0666)
+}
+
+func encode(w io.Writer, img image.Image, to string, opts *Options) error {
+	f, ok := formats[to]
+	if !ok {
+		if args := opts.Commands[to].Encode; len(args) > 0 {
+			return encodeCommand(w, img, args, opts)
+		}
+		return fmt.Errorf("%s is not supported", to)
+	}
+
+	return f.encode(w, img, opts)
+}
+
+// encodeJPEG encodes img at opts.Quality. If opts.MaxBytes is set, it searches
+// the highest quality between opts.MinQuality and opts.Quality that fits in the budget.
+func encodeJPEG(w io.Writer, img image.Image, opts *Options) error {
+	if opts.MaxBytes <= 0 {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: opts.Quality})
+	}
+
+	var best []byte
+	var smallest int
+	lo, hi := opts.MinQuality, opts.Quality
+	for lo <= hi {
+		q := (lo + hi) / 2
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: q}); err != nil {
+			return err
+		}
+		if buf.Len() <= opts.MaxBytes {
+			best = buf.Bytes()
+			lo = q + 1
+		} else {
+			smallest = buf.Len()
+			hi = q - 1
+		}
+	}
+	if best == nil {
+		return fmt.Errorf("%w: %d bytes at quality %d, max %d bytes",
+			ErrBudgetExceeded, smallest, opts.MinQuality, opts.MaxBytes)
+	}
+
+	_, err := w.Write(best)
+	return err
+}
+
+// uniqueNames counts the names given so far to add "(n)" to the duplicates.
+type uniqueNames map[string]int
+
+func (u uniqueNames) next(name string) string {
+	if _, ok := u[name]; !ok {
+		u[name] = 0
+		return name
+	}
+
+	u[name]++
+	return name + "(" + strconv.Itoa(u[name]) + ")"
+}
+
+// outputName returns the name of the outputs of the file path under src without the extension.
+// It keeps the directories relative to src for the mirror layout.
+func outputName(src, path, layout string) string {
+	if layout != "mirror" {
+		return filename(path)
+	}
+
+	// src の外のファイルは出力ディレクトリの外に書かないよう、ベース名にする。
+	rel, err := filepath.Rel(src, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filename(path)
+	}
+	return rel[:len(rel)-len(filepath.Ext(rel))]
+}
+
+// changes reports whether o changes the images, which makes converting a format to itself useful.
+func (o *Options) changes() bool {
+	return o.Trim != nil || len(o.Filters) > 0 || o.Watermark != nil || o.Caption != nil || o.Colors > 0 || o.MaxBytes > 0 || o.Depth == 8
+}
+
+func (o *Options) outDir() string {
+	if o.OutDir == "" {
+		return "output"
+	}
+	return o.OutDir
 }
 
 func filename(path string) string {
 	return filepath.Base(path[:len(path)-len(filepath.Ext(path))])
 }
 
-func walkDir(dir, ext string, fileNames chan<- string) {
-	ue := strings.ToUpper(ext)
+// walkDir sends the paths of the files under dir which match accepts to fileNames.
+func walkDir(dir string, match func(path string) bool, fileNames chan<- string) {
 	for _, ent := range dirents(dir) {
-		if !strings.HasSuffix(ent.Name(), ext) && !strings.HasSuffix(ent.Name(), ue) && !ent.IsDir() {
-			continue
-		}
+		path := filepath.Join(dir, ent.Name())
 		if ent.IsDir() {
-			subdir := filepath.Join(dir, ent.Name())
-			walkDir(subdir, ext, fileNames)
-		} else {
-			fileNames <- filepath.Join(dir, ent.Name())
+			walkDir(path, match, fileNames)
+		} else if match(path) {
+			fileNames <- path
 		}
 	}
 }
 
+// walked returns a source of convert which sends the files under dir which match accepts.
+func walked(dir string, match func(path string) bool) func(fileNames chan<- string) {
+	return func(fileNames chan<- string) {
+		walkDir(dir, match, fileNames)
+	}
+}
+
+// hasExt returns a matcher for the names with ext in lower or upper case.
+func hasExt(ext string) func(name string) bool {
+	ue := strings.ToUpper(ext)
+	return func(name string) bool {
+		return strings.HasSuffix(name, ext) || strings.HasSuffix(name, ue)
+	}
+}
+
+// isSupported reports whether name has the extension of a supported format.
+func isSupported(name string) bool {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	_, ok := formats[ext]
+	return ok
+}
+
 func dirents(dir string) []os.FileInfo {
 	ents, err := ioutil.ReadDir(dir)
 	if err != nil {
@@ -99,30 +572,88 @@ func dirents(dir string) []os.FileInfo {
 	return ents
 }
 
-func validateArgs(from, to string) error {
-	ae := allowedExt{"jpeg", "png", "jpg"}
-
-	if from == to {
+func validateArgs(from, to string, opts *Options) error {
+	if opts.Preset != "" {
+		// プリセットは出力形式を自分で決めるので、to は空でも元と同じでもよい。
+		if !opts.decodes(from) {
+			return errors.New("from is not supported")
+		}
+		if to != "" && !opts.encodes(to) {
+			return errors.New("to is not supported")
+		}
+		return nil
+	}
+	if from == to && !opts.changes() {
 		return errors.New("from and to are same")
 	}
-	if !ae.contains(from) {
+	if !opts.decodes(from) {
 		return errors.New("from is not supported")
 	}
-	if !ae.contains(to) {
+	if !opts.encodes(to) {
 		return errors.New("to is not supported")
 	}
 
 	return nil
 }
 
-type allowedExt []string
-
-func (e allowedExt) contains(name string) bool {
-	set := make(map[string]struct{}, len(e))
-	for _, v := range e {
-		set[v] = struct{}{}
+func validateOptions(opts *Options) error {
+	if opts.Quality < 1 || opts.Quality > 100 {
+		return errors.New("quality must be between 1 and 100")
+	}
+	if opts.MaxBytes < 0 {
+		return errors.New("max bytes must not be negative")
+	}
+	if opts.MaxBytes > 0 && (opts.MinQuality < 1 || opts.MinQuality > opts.Quality) {
+		return errors.New("min quality must be between 1 and quality")
+	}
+	if opts.Colors < 0 || opts.Colors > 256 {
+		return errors.New("colors must be between 0 and 256 (0 disables quantization)")
+	}
+	if opts.MinSSIM > 1 {
+		return errors.New("min SSIM must not be greater than 1")
+	}
+	if _, ok := tiffCompressions[opts.TIFFCompression]; !ok {
+		return fmt.Errorf("unknown TIFF compression %q", opts.TIFFCompression)
+	}
+	for _, s := range opts.ICOSizes {
+		if s < 1 || s > 256 {
+			return errors.New("ICO sizes must be between 1 and 256")
+		}
+	}
+	if _, ok := presets[opts.Preset]; opts.Preset != "" && !ok {
+		return fmt.Errorf("unknown preset %q", opts.Preset)
+	}
+	for _, w := range opts.Widths {
+		if w < 1 {
+			return errors.New("widths must be positive")
+		}
+	}
+	if err := validateNameTemplate(opts.NameTemplate); err != nil {
+		return err
+	}
+	if opts.MaxPixels < 0 || opts.MemoryBudget < 0 || opts.Workers < 0 {
+		return errors.New("max pixels, memory budget and workers must not be negative")
+	}
+	if opts.Depth != 0 && opts.Depth != 8 {
+		return errors.New("depth must be 0 or 8")
+	}
+	if opts.Layout != "" && opts.Layout != "flat" && opts.Layout != "mirror" {
+		return fmt.Errorf("unknown layout %q", opts.Layout)
+	}
+	if err := validateCommands(opts.Commands); err != nil {
+		return err
+	}
+	if opts.Metadata != "" && opts.Metadata != "strip" && opts.Metadata != "keep" {
+		return fmt.Errorf("unknown metadata %q", opts.Metadata)
+	}
+	for k := range opts.Text {
+		if !metadata.ValidKeyword(k) {
+			return fmt.Errorf("invalid PNG text keyword %q", k)
+		}
+	}
+	if opts.CommandTimeout < 0 {
+		return errors.New("command timeout must not be negative")
 	}
 
-	_, ok := set[name]
-	return ok
+	return nil
 }
@@ -1,12 +1,19 @@
 package converter
 
 import (
+	"bytes"
 	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/png"
+	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 )
 
 func TestConvertEtx(t *testing.T) {
+	src := sampleTree(t)
+	jpgs, pngs := countSamples("jpg"), countSamples("png")
 	tests := []struct {
 		src         string
 		from        string
@@ -14,13 +21,19 @@ func TestConvertEtx(t *testing.T) {
 		resultCount int
 		wantError   bool
 	}{
-		{"testdata/sample", "jpg", "png", 2, false},
-		{"testdata/sample", "PNG", "jpeg", 7, false},
-		{"testdata/sample", "png", "jpg", 7, false},
-		{"testdata/sample", "hoge", "jpg", 0, true},
-		{"testdata/sample", "jpg", "hoge", 0, true},
-		{"testdata/sample", "jpg", "jpg", 0, true},
-		{"testdata/sample", "", "", 0, true},
+		{src, "jpg", "png", jpgs, false},
+		{src, "PNG", "jpeg", pngs, false},
+		{src, "png", "jpg", pngs, false},
+		{src, "png", "qoi", pngs, false},
+		{src, "jpg", "ppm", jpgs, false},
+		{src, "jpg", "pbm", jpgs, false},
+		{src, "jpg", "bmp", jpgs, false},
+		{src, "jpg", "tiff", jpgs, false},
+		{src, "jpg", "ico", jpgs, false},
+		{src, "hoge", "jpg", 0, true},
+		{src, "jpg", "hoge", 0, true},
+		{src, "jpg", "jpg", 0, true},
+		{src, "", "", 0, true},
 	}
 
 	if err := os.MkdirAll("output", 0777); err != nil {
@@ -43,3 +56,291 @@ func TestConvertEtx(t *testing.T) {
 		t.Error("failed to delete an output folder")
 	}
 }
+
+func TestConvertMaxBytes(t *testing.T) {
+	tests := []struct {
+		maxBytes   int
+		minQuality int
+		wantError  bool
+	}{
+		{0, 10, false},
+		{200000, 10, false},
+		{3000, 10, false},
+		{100, 10, true},
+		{3000, 0, true},
+	}
+	src := sampleTree(t)
+
+	if err := os.MkdirAll("output", 0777); err != nil {
+		t.Error("failed to make an output folder")
+	}
+	defer os.RemoveAll("output")
+
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.MaxBytes = tt.maxBytes
+		opts.MinQuality = tt.minQuality
+		_, err := Convert(src, "png", "jpg", opts)
+		helper.TestWantError(t, err, tt.wantError)
+		if err != nil || tt.maxBytes == 0 {
+			continue
+		}
+
+		// 全ての出力が予算内に収まっているかの確認
+		ents, _ := ioutil.ReadDir("output")
+		for _, ent := range ents {
+			if ent.Name() != HistoryFile && ent.Size() > int64(tt.maxBytes) {
+				t.Errorf("%s is %d bytes, want <= %d", ent.Name(), ent.Size(), tt.maxBytes)
+			}
+		}
+	}
+}
+
+func TestConvertColors(t *testing.T) {
+	tests := []struct {
+		colors int
+		dither bool
+		err    string
+	}{
+		{16, false, ""},
+		{256, true, ""},
+		{257, false, `colors must be between 0 and 256 \(0 disables quantization\)`},
+		{-1, false, `colors must be between 0 and 256`},
+	}
+	src := sampleTree(t)
+
+	if err := os.MkdirAll("output", 0777); err != nil {
+		t.Error("failed to make an output folder")
+	}
+	defer os.RemoveAll("output")
+
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.Colors = tt.colors
+		opts.Dither = tt.dither
+		_, err := Convert(src, "jpg", "png", opts)
+		helper.TestErrorMatch(t, err, tt.err)
+		if err != nil {
+			continue
+		}
+
+		// パレット画像として書き出されているかの確認
+		f, err := os.Open("output/dojo5.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, ok := img.(*image.Paletted)
+		if !ok {
+			t.Fatalf("got %T, want *image.Paletted", img)
+		}
+		if len(p.Palette) > tt.colors {
+			t.Errorf("got %d colors, want <= %d", len(p.Palette), tt.colors)
+		}
+	}
+}
+
+func TestConvertTIFFCompression(t *testing.T) {
+	tests := []struct {
+		compression string
+		wantError   bool
+	}{
+		{"", false},
+		{"lzw", false},
+		{"deflate", false},
+		{"zip", true},
+	}
+	src := sampleTree(t)
+
+	if err := os.MkdirAll("output", 0777); err != nil {
+		t.Error("failed to make an output folder")
+	}
+	defer os.RemoveAll("output")
+
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.TIFFCompression = tt.compression
+		r, err := Convert(src, "jpg", "tif", opts)
+		helper.TestWantError(t, err, tt.wantError)
+		if want := countSamples("jpg"); err == nil && len(r.Files) != want {
+			t.Errorf("TIFF compression %q converted %d files, want %d", tt.compression, len(r.Files), want)
+		}
+	}
+}
+
+func TestConvertVerify(t *testing.T) {
+	tests := []struct {
+		to        string
+		colors    int
+		minPSNR   float64
+		identical bool
+		flagged   bool
+	}{
+		{"png", 0, 30, true, false},
+		{"qoi", 0, 30, true, false},
+		{"jpg", 0, 30, false, false},
+		// 閾値を上げれば非可逆な出力は検出される。
+		{"jpg", 0, 100, false, true},
+		{"png", 4, 0, false, false},
+	}
+
+	if err := os.MkdirAll("output", 0777); err != nil {
+		t.Error("failed to make an output folder")
+	}
+	defer os.RemoveAll("output")
+
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.Verify = true
+		opts.Colors = tt.colors
+		opts.MinPSNR = tt.minPSNR
+		opts.MinSSIM = 0
+		from := "jpg"
+		if tt.to == "jpg" {
+			from = "jpeg"
+		}
+		r, err := Convert("testdata/verify", from, tt.to, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(r.Files) == 0 {
+			t.Fatal("no files converted")
+		}
+
+		for _, f := range r.Files {
+			v := f.Verification
+			if v == nil {
+				t.Fatalf("%s: not verified", f.Dst)
+			}
+			if v.Identical != tt.identical || v.Flagged != tt.flagged {
+				t.Errorf("%s: identical = %v, flagged = %v, want %v, %v (PSNR %v, SSIM %v)",
+					f.Dst, v.Identical, v.Flagged, tt.identical, tt.flagged, v.PSNR, v.SSIM)
+			}
+		}
+		if got := len(r.Flagged()) > 0; got != tt.flagged {
+			t.Errorf("to %s: Flagged() = %v", tt.to, r.Flagged())
+		}
+	}
+}
+
+func TestConvertVerifyTransparent(t *testing.T) {
+	// アルファが 0 から 255 まで変わる画像
+	alpha := helper.Gradient(48, 48)
+	for i := 3; i < len(alpha.Pix); i += 4 {
+		alpha.Pix[i] = uint8(i / 4 % 48 * 255 / 47)
+	}
+	src := helper.WriteTree(t, map[string]image.Image{"alpha.png": alpha}, nil)
+
+	tests := []struct {
+		to      string
+		filters []string
+	}{
+		// アルファのない形式は黒に合成した画像と比べる。
+		{"ppm", nil},
+		{"pnm", nil},
+		{"bmp", nil},
+		{"qoi", nil},
+		{"tiff", nil},
+		{"ico", nil},
+		// フィルタはアルファを掛けた RGBA を返す。
+		{"png", []string{"invert"}},
+		{"qoi", []string{"sepia"}},
+	}
+
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.OutDir = t.TempDir()
+		opts.Verify = true
+		opts.ICOSizes = []int{48}
+		opts.Filters = tt.filters
+		r, err := Convert(src, "png", tt.to, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.to, err)
+		}
+		for _, f := range r.Flagged() {
+			t.Errorf("%s %v: flagged (PSNR %v, SSIM %v)", tt.to, tt.filters, f.Verification.PSNR, f.Verification.SSIM)
+		}
+	}
+}
+
+func TestConvertImageInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	err := ConvertImage(&buf, strings.NewReader("hello"), "png", DefaultOptions())
+	helper.TestErrorIs(t, err, ErrInvalidInput)
+	// 印を付けても元のメッセージのまま返す。
+	if err != nil && !strings.Contains(err.Error(), "unknown format") {
+		t.Errorf("got an error %q, want the error of the decoder", err)
+	}
+}
+
+func TestCompareFiles(t *testing.T) {
+	tests := []struct {
+		want, got string
+		minPSNR   float64
+		identical bool
+		flagged   bool
+		wantError bool
+	}{
+		{"testdata/verify/gradient.jpg", "testdata/verify/gradient.jpeg", 30, true, false, false},
+		// 大きさが違う画像は比べられない。
+		{"testdata/verify/gradient.jpg", "testdata/sample/dojo1.png", 30, false, false, true},
+		{"testdata/verify/gradient.jpg", "testdata/nothing.png", 30, false, false, true},
+	}
+
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.MinPSNR = tt.minPSNR
+		v, err := CompareFiles(tt.want, tt.got, opts)
+		helper.TestWantError(t, err, tt.wantError)
+		if err != nil {
+			continue
+		}
+		if v.Identical != tt.identical || v.Flagged != tt.flagged {
+			t.Errorf("CompareFiles(%s, %s) = %+v", tt.want, tt.got, v)
+		}
+	}
+}
+
+func TestConvertImage(t *testing.T) {
+	tests := []struct {
+		to        string
+		maxPixels int64
+		preset    string
+		err       error
+	}{
+		{"png", 0, "", nil},
+		{"BMP", 64 * 48, "", nil},
+		{"hoge", 0, "", ErrInvalidInput},
+		{"png", 64*48 - 1, "", ErrTooLarge},
+		{"png", 0, "favicon", ErrInvalidInput},
+	}
+
+	for _, tt := range tests {
+		src, err := os.Open("testdata/verify/gradient.jpg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := DefaultOptions()
+		opts.MaxPixels = tt.maxPixels
+		opts.Preset = tt.preset
+		var buf bytes.Buffer
+		err = ConvertImage(&buf, src, tt.to, opts)
+		src.Close()
+		helper.TestErrorIs(t, err, tt.err)
+		if err != nil {
+			continue
+		}
+
+		cfg, format, err := image.DecodeConfig(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := strings.ToLower(tt.to); format != want || cfg.Width != 64 {
+			t.Errorf("ConvertImage to %s = %s %dx%d", tt.to, format, cfg.Width, cfg.Height)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package converter
+
+import (
+	"gopher-dojo/kadai2/exchanger/imaging"
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvertHighBitDepth(t *testing.T) {
+	// 下位バイトが上位バイトと異なる 16 ビットの画像
+	rgba := image.NewRGBA64(image.Rect(0, 0, 16, 8))
+	gray := image.NewGray16(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			rgba.SetRGBA64(x, y, color.RGBA64{uint16(x*4000 + 7), uint16(y*8000 + 1), 0x1234, 0xffff})
+			gray.SetGray16(x, y, color.Gray16{uint16(x*y*500 + 3)})
+		}
+	}
+	src := t.TempDir()
+	for name, img := range map[string]image.Image{"rgba.png": rgba, "gray.png": gray} {
+		f, err := os.Create(filepath.Join(src, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		png.Encode(f, img)
+		f.Close()
+	}
+
+	tests := []struct {
+		to    string
+		depth int
+		trim  bool
+		// 16 ビットのまま書き出されるか
+		deep      bool
+		wantError bool
+	}{
+		{"tiff", 0, false, true, false},
+		{"ppm", 0, false, true, false},
+		{"bmp", 0, false, false, false},
+		{"tiff", 8, false, false, false},
+		// 同じ形式への変換は、画像を変えるオプションがあればできる。
+		{"png", 0, true, true, false},
+		{"png", 8, false, false, false},
+		{"png", 0, false, false, true},
+		{"png", 16, false, false, true},
+	}
+
+	for _, tt := range tests {
+		out := t.TempDir()
+		opts := DefaultOptions()
+		opts.OutDir = out
+		opts.Depth = tt.depth
+		opts.Verify = true
+		if tt.trim {
+			opts.Trim = &Trim{}
+		}
+		r, err := Convert(src, "png", tt.to, opts)
+		helper.TestWantError(t, err, tt.wantError)
+		if err != nil {
+			continue
+		}
+
+		// 可逆な形式は、丸めた場合もその画像と一致する。
+		if f := r.Flagged(); len(f) != 0 {
+			t.Errorf("%s depth %d: flagged %+v", tt.to, tt.depth, f[0].Verification)
+		}
+		for _, name := range []string{"rgba", "gray"} {
+			img, err := decodeFile(filepath.Join(out, name+"."+tt.to))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := imaging.HighBitDepth(img); got != tt.deep {
+				t.Errorf("%s depth %d: %s has 16-bit samples %v, want %v", tt.to, tt.depth, name, got, tt.deep)
+			}
+		}
+	}
+}
+
+func TestConvertCMYK(t *testing.T) {
+	info, err := Inspect("testdata/cmyk/video-001.cmyk.jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ColorModel != "CMYK" {
+		t.Fatalf("color model = %s, want CMYK", info.ColorModel)
+	}
+
+	out := t.TempDir()
+	opts := DefaultOptions()
+	opts.OutDir = out
+	opts.Verify = true
+	r, err := Convert("testdata/cmyk", "jpeg", "png", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := r.Flagged(); len(f) != 0 {
+		t.Errorf("flagged %+v", f[0].Verification)
+	}
+
+	// RGB に変換されて、標準ライブラリの期待画像とほぼ一致する。
+	dst := filepath.Join(out, "video-001.cmyk.png")
+	if info, err := Inspect(dst); err != nil || info.ColorModel == "CMYK" {
+		t.Fatalf("output = %+v, %v", info, err)
+	}
+	v, err := CompareFiles("testdata/video-001.cmyk.png", dst, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.PSNR < 40 {
+		t.Errorf("PSNR against the expected image = %.2f dB", v.PSNR)
+	}
+}
@@ -0,0 +1,221 @@
+package converter
+
+import (
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/imaging"
+	"image/png"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DiffOptions configures DiffTrees.
+type DiffOptions struct {
+	// Tolerance is the largest difference of an 8-bit channel which is not counted as a change.
+	Tolerance int
+	// Visualize is the directory to write the PNG images of the changed pixels to.
+	// No images are written if it is empty.
+	Visualize string
+	// MaxPixels is the largest number of pixels of an image. Zero means no limit.
+	MaxPixels int64
+}
+
+// Statuses of a Difference.
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+	DiffSame    = "same"
+)
+
+// Difference is an image found in either or both of the trees DiffTrees compares.
+type Difference struct {
+	Status string `json:"status"`
+	// A and B are the paths of the image in the trees. One of them is empty if it is added or removed.
+	A string `json:"a,omitempty"`
+	B string `json:"b,omitempty"`
+	// Pixels is the number of changed pixels and Max is the largest difference of the channels.
+	Pixels int `json:"pixels,omitempty"`
+	Max    int `json:"max,omitempty"`
+	// Size is set to the sizes of the images, as in "64x48 -> 32x24", if they are resized.
+	Size string `json:"size,omitempty"`
+	// Visual is the path of the image of the changed pixels.
+	Visual string `json:"visual,omitempty"`
+}
+
+// DiffTrees pairs the images of the supported formats under the directories a and b
+// regardless of their formats, and compares the pixels of each pair.
+// Images are paired by the relative paths without the extensions, and then those left
+// by the base names if they are unique in both trees, so moved images are also found.
+// The differences are sorted by the paths, with the removed images last.
+// Each image is checked against opts.MaxPixels by its header before it is decoded,
+// and fails with ErrTooLarge if it exceeds it.
+func DiffTrees(a, b string, opts *DiffOptions) ([]Difference, error) {
+	if opts.Tolerance < 0 || opts.Tolerance > 255 {
+		return nil, fmt.Errorf("tolerance must be between 0 and 255")
+	}
+	if opts.MaxPixels < 0 {
+		return nil, fmt.Errorf("max pixels must not be negative")
+	}
+	as, err := imageKeys(a)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := imageKeys(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []Difference
+	pair := func(ka, kb string) error {
+		d, err := diffPair(as[ka], bs[kb], kb, opts)
+		if err != nil {
+			return err
+		}
+		diffs = append(diffs, *d)
+		delete(as, ka)
+		delete(bs, kb)
+		return nil
+	}
+
+	for _, k := range sortedKeys(as) {
+		if _, ok := bs[k]; ok {
+			if err := pair(k, k); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// 残りは、どちらの木でも一つしかない名前同士を移動したものとみなす。
+	ba, bb := byBase(as), byBase(bs)
+	for _, k := range sortedKeys(as) {
+		base := path.Base(k)
+		if len(ba[base]) == 1 && len(bb[base]) == 1 {
+			if err := pair(k, bb[base][0]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, k := range sortedKeys(bs) {
+		diffs = append(diffs, Difference{Status: DiffAdded, B: bs[k]})
+	}
+	sort.SliceStable(diffs, func(i, j int) bool { return diffs[i].B < diffs[j].B })
+	for _, k := range sortedKeys(as) {
+		diffs = append(diffs, Difference{Status: DiffRemoved, A: as[k]})
+	}
+
+	return diffs, nil
+}
+
+// imageKeys returns the supported files under dir by their slash-separated paths
+// relative to dir without the extensions. Files which differ from an earlier file only in
+// the extension keep it, so they are paired with the files of the same format.
+func imageKeys(dir string) (map[string]string, error) {
+	st, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !st.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	fileNames := make(chan string)
+	go func() {
+		walkDir(dir, isSupported, fileNames)
+		close(fileNames)
+	}()
+
+	keys := make(map[string]string)
+	for fn := range fileNames {
+		rel, err := filepath.Rel(dir, fn)
+		if err != nil {
+			rel = filepath.Base(fn)
+		}
+		k := strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel))
+		// 拡張子だけが違うファイルは、名前順で後のものを拡張子付きの名前で区別する。
+		if _, ok := keys[k]; ok {
+			k = filepath.ToSlash(rel)
+		}
+		keys[k] = fn
+	}
+
+	return keys, nil
+}
+
+// byBase returns the keys by their base names.
+func byBase(keys map[string]string) map[string][]string {
+	m := make(map[string][]string)
+	for k := range keys {
+		base := path.Base(k)
+		m[base] = append(m[base], k)
+	}
+	return m
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// diffPair compares the images a and b. The image of the changed pixels is written
+// to key in opts.Visualize.
+func diffPair(a, b, key string, opts *DiffOptions) (*Difference, error) {
+	d := &Difference{Status: DiffSame, A: a, B: b}
+	limits := &Options{MaxPixels: opts.MaxPixels}
+	for _, fn := range []string{a, b} {
+		if _, err := checkSize(fn, limits); err != nil {
+			return nil, err
+		}
+	}
+	ia, err := decodeFile(a)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", a, err)
+	}
+	ib, err := decodeFile(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b, err)
+	}
+
+	sa, sb := ia.Bounds().Size(), ib.Bounds().Size()
+	if sa != sb {
+		d.Status = DiffChanged
+		d.Size = fmt.Sprintf("%dx%d -> %dx%d", sa.X, sa.Y, sb.X, sb.Y)
+		return d, nil
+	}
+	pd, err := imaging.ComparePixels(ia, ib, opts.Tolerance)
+	if err != nil {
+		return nil, err
+	}
+	d.Pixels, d.Max = pd.Changed, pd.Max
+	if pd.Changed == 0 {
+		return d, nil
+	}
+	d.Status = DiffChanged
+
+	if opts.Visualize == "" {
+		return d, nil
+	}
+	visual, err := imaging.DiffImage(ia, ib, opts.Tolerance)
+	if err != nil {
+		return nil, err
+	}
+	d.Visual = filepath.Join(opts.Visualize, filepath.FromSlash(key)+".png")
+	if err := os.MkdirAll(filepath.Dir(d.Visual), 0777); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(d.Visual)
+	if err != nil {
+		return nil, err
+	}
+	if err := png.Encode(file, visual); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return d, file.Close()
+}
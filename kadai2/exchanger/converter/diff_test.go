@@ -0,0 +1,74 @@
+package converter
+
+import (
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffTrees(t *testing.T) {
+	changed := helper.Gradient(16, 12)
+	changed.SetNRGBA(4, 4, color.NRGBA{0, 0, 0, 255})
+	a := helper.WriteTree(t, map[string]image.Image{
+		"same.png":        helper.Gradient(16, 12),
+		"photos/edit.png": helper.Gradient(16, 12),
+		"photos/big.qoi":  helper.Gradient(16, 12),
+		"old/moved.png":   helper.Gradient(8, 8),
+		"gone.png":        helper.Gradient(8, 8),
+	}, encoders())
+	b := helper.WriteTree(t, map[string]image.Image{
+		// 形式が変わっても同じ画像とみなす。
+		"same.bmp":        helper.Gradient(16, 12),
+		"photos/edit.png": changed,
+		"photos/big.png":  helper.Gradient(32, 24),
+		"new/moved.png":   helper.Gradient(8, 8),
+		"new.png":         helper.Gradient(8, 8),
+	}, encoders())
+	visual := t.TempDir()
+
+	diffs, err := DiffTrees(a, b, &DiffOptions{Visualize: visual})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Difference{
+		{Status: DiffAdded, B: filepath.Join(b, "new.png")},
+		{Status: DiffSame, A: filepath.Join(a, "old/moved.png"), B: filepath.Join(b, "new/moved.png")},
+		{Status: DiffChanged, A: filepath.Join(a, "photos/big.qoi"), B: filepath.Join(b, "photos/big.png"), Size: "16x12 -> 32x24"},
+		{Status: DiffChanged, A: filepath.Join(a, "photos/edit.png"), B: filepath.Join(b, "photos/edit.png"), Pixels: 1, Max: 128,
+			Visual: filepath.Join(visual, "photos/edit.png")},
+		{Status: DiffSame, A: filepath.Join(a, "same.png"), B: filepath.Join(b, "same.bmp")},
+		{Status: DiffRemoved, A: filepath.Join(a, "gone.png")},
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("got %d differences, want %d: %+v", len(diffs), len(want), diffs)
+	}
+	for i := range want {
+		if diffs[i] != want[i] {
+			t.Errorf("difference %d = %+v, want %+v", i, diffs[i], want[i])
+		}
+	}
+	if got := helper.Files(t, visual); len(got) != 1 || got[0] != "photos/edit.png" {
+		t.Errorf("visualized %v", got)
+	}
+
+	// 許容範囲内なら変わっていないとみなす。
+	diffs, err = DiffTrees(a, b, &DiffOptions{Tolerance: 128})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := diffs[3]; d.Status != DiffSame || d.Max != 128 || d.Visual != "" {
+		t.Errorf("within tolerance: %+v", d)
+	}
+
+	_, err = DiffTrees(a, filepath.Join(b, "nothing"), &DiffOptions{})
+	helper.TestWantError(t, err, true)
+	_, err = DiffTrees(a, b, &DiffOptions{Tolerance: 256})
+	helper.TestErrorMatch(t, err, "tolerance must be")
+	// 大きすぎる画像はデコードせずに断る。
+	_, err = DiffTrees(a, b, &DiffOptions{MaxPixels: 16*12 - 1})
+	helper.TestErrorIs(t, err, ErrTooLarge)
+	_, err = DiffTrees(a, b, &DiffOptions{MaxPixels: -1})
+	helper.TestErrorMatch(t, err, "must not be negative")
+}
@@ -0,0 +1,38 @@
+// Package converter converts the image files under a directory between formats.
+//
+// # Color handling
+//
+// Each source is decoded by the codec of its format and kept in the color model the codec
+// returns through the operations until it is encoded:
+//
+//   - YCbCr JPEGs are converted to RGB with the full range BT.601 matrix of JFIF
+//     when they are encoded, as image/color does.
+//   - CMYK and YCCK JPEGs, marked by the Adobe APP14 segment, are decoded to image.CMYK
+//     and converted to RGB by R = (1 - C) * (1 - K) and so on. ICC profiles are not applied,
+//     so the colors are close to, but not the same as, those of a color-managed viewer.
+//   - 16-bit samples of PNG, TIFF and Netpbm sources are kept by the operations and written
+//     as they are to PNG, TIFF, PGM and PPM. The other formats, and Options.Depth 8, round
+//     them to the nearest 8-bit values before encoding, without dithering.
+//   - Options.Filters compute on alpha-premultiplied 16-bit samples and return RGBA, or RGBA64
+//     for 16-bit sources, so grayscale and paletted sources leave the filters in color.
+//   - Formats without alpha, JPEG, PGM and PPM, drop it by compositing onto black.
+//   - Formats written by Options.Commands get 8-bit PNG on their standard input.
+//
+// # External commands
+//
+// Options.Commands delegates the formats without a codec here to external programs,
+// which read the whole image from their standard input and write the result to their
+// standard output within Options.CommandTimeout. A decode command writes any format
+// supported natively, whose header is checked against the limits before it is decoded.
+// The memory of such a source is not reserved from Options.MemoryBudget, since it is not
+// known before the command runs. The outputs of commands are named, reported and recorded
+// in the history like the others.
+//
+// # Metadata
+//
+// The outputs carry no metadata of the sources unless Options.Metadata is "keep". Then the
+// EXIF of JPEG and PNG sources is copied into JPEG and PNG outputs with its GPS directory
+// emptied, so the copyright and the other tags stay but the location does not. The copyright
+// of PNG text chunks and Options.Text is written to the EXIF of JPEG outputs. The other
+// formats are always written without metadata.
+package converter
@@ -0,0 +1,166 @@
+package converter
+
+import (
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/imaging"
+	"image"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DupesOptions configures FindDuplicates.
+type DupesOptions struct {
+	// Hash is the perceptual hash to compare: "ahash" or "dhash".
+	Hash string
+	// Distance is the maximum Hamming distance between the hashes of near-duplicates.
+	Distance int
+	// MaxPixels is the largest number of pixels of an image. Zero means no limit.
+	MaxPixels int64
+}
+
+// DefaultDupesOptions returns the options which find images that look the same
+// regardless of their formats and sizes, with the pixel limit of DefaultOptions.
+func DefaultDupesOptions() *DupesOptions {
+	return &DupesOptions{Hash: "dhash", Distance: 5, MaxPixels: DefaultOptions().MaxPixels}
+}
+
+// HashedFile is an image file and its perceptual hash.
+type HashedFile struct {
+	Path   string `json:"path"`
+	Hash   string `json:"hash"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// DuplicateGroup is a set of near-duplicate images.
+type DuplicateGroup struct {
+	Files []HashedFile `json:"files"`
+}
+
+// UndecodableError reports the files FindDuplicates could not decode, including those over
+// DupesOptions.MaxPixels. They are left out of the groups returned with it.
+type UndecodableError struct {
+	// Errors are the errors of the files prefixed with their paths, in the order of the walk.
+	Errors []error
+}
+
+func (e *UndecodableError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+var hashes = map[string]func(image.Image) uint64{
+	"ahash": imaging.AHash,
+	"dhash": imaging.DHash,
+}
+
+// FindDuplicates walks the images of the supported formats under src and groups the
+// near-duplicates. Images are in the same group if they are connected by hashes within
+// opts.Distance. Groups of a single image are not returned.
+// Each image is checked against opts.MaxPixels by its header before it is decoded.
+// The files which cannot be decoded or are too large are skipped and reported by an *UndecodableError
+// returned with the groups of the others.
+func FindDuplicates(src string, opts *DupesOptions) ([]DuplicateGroup, error) {
+	hash, ok := hashes[opts.Hash]
+	if !ok {
+		return nil, fmt.Errorf("unknown hash %q", opts.Hash)
+	}
+	if opts.Distance < 0 || opts.Distance > 64 {
+		return nil, fmt.Errorf("distance must be between 0 and 64")
+	}
+	if opts.MaxPixels < 0 {
+		return nil, fmt.Errorf("max pixels must not be negative")
+	}
+	limits := &Options{MaxPixels: opts.MaxPixels}
+
+	fileNames := make(chan string)
+	go func() {
+		walkDir(src, isSupported, fileNames)
+		close(fileNames)
+	}()
+	defer func() {
+		for range fileNames {
+		}
+	}()
+
+	var files []HashedFile
+	var values []uint64
+	var undecodable []error
+	for fn := range fileNames {
+		// 大きすぎる画像はヘッダで確かめて、デコードしない。
+		if _, err := checkSize(fn, limits); err != nil {
+			undecodable = append(undecodable, err)
+			continue
+		}
+		img, err := decodeFile(fn)
+		if err != nil {
+			// 壊れたファイルがあっても、残りの重複は探す。
+			undecodable = append(undecodable, fmt.Errorf("%s: %w", fn, err))
+			continue
+		}
+		h := hash(img)
+		files = append(files, HashedFile{
+			Path:   fn,
+			Hash:   fmt.Sprintf("%016x", h),
+			Width:  img.Bounds().Dx(),
+			Height: img.Bounds().Dy(),
+		})
+		values = append(values, h)
+	}
+
+	// 距離が近いもの同士を union-find でまとめる。
+	parent := make([]int, len(files))
+	for i := range parent {
+		parent[i] = i
+	}
+	var root func(i int) int
+	root = func(i int) int {
+		if parent[i] != i {
+			parent[i] = root(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range values {
+		for j := i + 1; j < len(values); j++ {
+			if imaging.Hamming(values[i], values[j]) <= opts.Distance {
+				parent[root(j)] = root(i)
+			}
+		}
+	}
+
+	members := make(map[int][]HashedFile)
+	for i, f := range files {
+		r := root(i)
+		members[r] = append(members[r], f)
+	}
+
+	var groups []DuplicateGroup
+	for _, m := range members {
+		if len(m) < 2 {
+			continue
+		}
+		sort.Slice(m, func(i, j int) bool { return m[i].Path < m[j].Path })
+		groups = append(groups, DuplicateGroup{Files: m})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Files[0].Path < groups[j].Files[0].Path })
+
+	if len(undecodable) > 0 {
+		return groups, &UndecodableError{Errors: undecodable}
+	}
+	return groups, nil
+}
+
+func decodeFile(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	return img, err
+}
@@ -0,0 +1,96 @@
+package converter
+
+import (
+	"errors"
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	black, white := color.NRGBA{0, 0, 0, 255}, color.NRGBA{255, 255, 255, 255}
+	// 同じ絵を形式や大きさを変えて置いたものと、別の絵
+	dir := helper.WriteTree(t, map[string]image.Image{
+		"a/gradient.png":   helper.Gradient(64, 48),
+		"b/gradient.jpg":   helper.Gradient(64, 48),
+		"c/gradient.bmp":   helper.Gradient(128, 96),
+		"a/checker.qoi":    helper.Checker(48, 48, 12, black, white),
+		"b/checker.tiff":   helper.Checker(96, 96, 24, black, white),
+		"c/frame.ppm":      helper.Framed(64, 48, 8, white, black),
+		"d/unrelated.tiff": helper.Checker(64, 48, 4, white, black),
+	}, encoders())
+
+	tests := []struct {
+		hash     string
+		distance int
+		// グループを最初のファイルのパス順に並べたときの、それぞれのファイル数
+		groupSizes []int
+		wantError  bool
+	}{
+		{"dhash", 5, []int{2, 3}, false},
+		{"ahash", 5, []int{2, 3}, false},
+		{"phash", 5, nil, true},
+		{"dhash", 65, nil, true},
+	}
+
+	for _, tt := range tests {
+		groups, err := FindDuplicates(dir, &DupesOptions{Hash: tt.hash, Distance: tt.distance})
+		helper.TestWantError(t, err, tt.wantError)
+		if len(groups) != len(tt.groupSizes) {
+			t.Errorf("%s/%d: got %d groups %+v, want %d", tt.hash, tt.distance, len(groups), groups, len(tt.groupSizes))
+			continue
+		}
+		for i, g := range groups {
+			if len(g.Files) != tt.groupSizes[i] {
+				t.Errorf("%s/%d: group %d has %d files, want %d", tt.hash, tt.distance, i, len(g.Files), tt.groupSizes[i])
+			}
+		}
+	}
+}
+
+func TestFindDuplicatesUndecodable(t *testing.T) {
+	img := helper.Gradient(32, 32)
+	dir := helper.WriteTree(t, map[string]image.Image{"a.png": img, "b/a.png": img}, nil)
+	// 拡張子だけが PNG の壊れたファイル
+	if err := ioutil.WriteFile(filepath.Join(dir, "broken.png"), []byte("not a png"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := FindDuplicates(dir, DefaultDupesOptions())
+	var undecodable *UndecodableError
+	if !errors.As(err, &undecodable) || len(undecodable.Errors) != 1 {
+		t.Fatalf("error = %v, want an UndecodableError of 1 file", err)
+	}
+	helper.TestErrorMatch(t, undecodable.Errors[0], "broken.png: ")
+	if len(groups) != 1 || len(groups[0].Files) != 2 {
+		t.Errorf("groups = %+v, want the 2 readable images", groups)
+	}
+}
+
+func TestFindDuplicatesTooLarge(t *testing.T) {
+	dir := helper.WriteTree(t, map[string]image.Image{
+		"a.png":     helper.Gradient(32, 24),
+		"b.png":     helper.Gradient(32, 24),
+		"large.png": helper.Gradient(64, 48),
+	}, nil)
+
+	opts := DefaultDupesOptions()
+	opts.MaxPixels = 32 * 24
+	groups, err := FindDuplicates(dir, opts)
+	var undecodable *UndecodableError
+	if !errors.As(err, &undecodable) || len(undecodable.Errors) != 1 {
+		t.Fatalf("error = %v, want an UndecodableError of 1 file", err)
+	}
+	helper.TestErrorIs(t, undecodable.Errors[0], ErrTooLarge)
+	helper.TestErrorMatch(t, undecodable.Errors[0], "large.png")
+	if len(groups) != 1 || len(groups[0].Files) != 2 {
+		t.Errorf("groups = %+v, want the 2 small images", groups)
+	}
+
+	opts.MaxPixels = -1
+	_, err = FindDuplicates(dir, opts)
+	helper.TestErrorMatch(t, err, "must not be negative")
+}
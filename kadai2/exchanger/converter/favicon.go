@@ -0,0 +1,128 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/imaging"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// favicon is a PNG icon in the favicon bundle.
+type favicon struct {
+	name string
+	size int
+	// manifest reports whether the icon is listed in the web app manifest.
+	manifest bool
+}
+
+var favicons = []favicon{
+	{"favicon-16x16.png", 16, false},
+	{"favicon-32x32.png", 32, false},
+	{"apple-touch-icon.png", 180, false},
+	{"android-chrome-192x192.png", 192, true},
+	{"android-chrome-512x512.png", 512, true},
+}
+
+// faviconPreset writes the favicon bundle of each source under <OutDir>/<name>/:
+// the PNG icons, favicon.ico, site.webmanifest and favicon.html, the snippet for the head element.
+type faviconPreset struct {
+	opts *Options
+}
+
+func newFaviconPreset(_ string, opts *Options) preset {
+	return &faviconPreset{opts: opts}
+}
+
+func (p *faviconPreset) write(src, name string, img image.Image) ([]FileResult, error) {
+	dir := filepath.Join(p.opts.outDir(), name)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+
+	var results []FileResult
+	for _, f := range favicons {
+		r, err := writeImage(src, filepath.Join(dir, f.name), imaging.Contain(img, f.size, f.size), "png", p.opts)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, *r)
+	}
+	r, err := writeImage(src, filepath.Join(dir, "favicon.ico"), img, "ico", p.opts)
+	if err != nil {
+		return results, err
+	}
+	results = append(results, *r)
+
+	manifest, err := webManifest(name)
+	if err != nil {
+		return results, err
+	}
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{"site.webmanifest", manifest},
+		{"favicon.html", []byte(faviconHTML())},
+	} {
+		dst := filepath.Join(dir, f.name)
+		if err := ioutil.WriteFile(dst, f.data, 0666); err != nil {
+			return results, err
+		}
+		results = append(results, FileResult{Src: src, Dst: dst})
+	}
+
+	return results, nil
+}
+
+func (p *faviconPreset) close() ([]FileResult, error) {
+	return nil, nil
+}
+
+// webManifest returns the web app manifest listing the icons for Android.
+func webManifest(name string) ([]byte, error) {
+	type icon struct {
+		Src   string `json:"src"`
+		Sizes string `json:"sizes"`
+		Type  string `json:"type"`
+	}
+	m := struct {
+		Name      string `json:"name"`
+		ShortName string `json:"short_name"`
+		Icons     []icon `json:"icons"`
+		Display   string `json:"display"`
+	}{Name: name, ShortName: name, Display: "standalone"}
+	for _, f := range favicons {
+		if f.manifest {
+			m.Icons = append(m.Icons, icon{"/" + f.name, fmt.Sprintf("%dx%d", f.size, f.size), "image/png"})
+		}
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// faviconHTML returns the link elements to paste into the head element.
+func faviconHTML() string {
+	var b strings.Builder
+	for _, f := range favicons {
+		switch {
+		case f.manifest:
+			continue
+		case strings.HasPrefix(f.name, "apple-touch-icon"):
+			fmt.Fprintf(&b, "<link rel=\"apple-touch-icon\" sizes=\"%dx%d\" href=\"/%s\">\n", f.size, f.size, f.name)
+		default:
+			fmt.Fprintf(&b, "<link rel=\"icon\" type=\"image/png\" sizes=\"%dx%d\" href=\"/%s\">\n", f.size, f.size, f.name)
+		}
+	}
+	b.WriteString("<link rel=\"icon\" href=\"/favicon.ico\" sizes=\"any\">\n")
+	b.WriteString("<link rel=\"manifest\" href=\"/site.webmanifest\">\n")
+
+	return b.String()
+}
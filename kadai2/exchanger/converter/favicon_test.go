@@ -0,0 +1,97 @@
+package converter
+
+import (
+	"encoding/json"
+	"gopher-dojo/kadai2/exchanger/codec/ico"
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvertFavicon(t *testing.T) {
+	tests := []struct {
+		preset    string
+		to        string
+		wantError bool
+	}{
+		{"favicon", "", false},
+		// プリセットでは出力形式が元と同じでもよい。
+		{"favicon", "jpg", false},
+		{"favicon", "hoge", true},
+		{"hoge", "", true},
+	}
+
+	if err := os.MkdirAll("output", 0777); err != nil {
+		t.Error("failed to make an output folder")
+	}
+	defer os.RemoveAll("output")
+
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.Preset = tt.preset
+		opts.Verify = true
+		r, err := Convert("testdata/verify", "jpg", tt.to, opts)
+		helper.TestWantError(t, err, tt.wantError)
+		if err != nil {
+			continue
+		}
+
+		// PNG 5種類、ICO、マニフェストと HTML
+		if len(r.Files) != 8 {
+			t.Errorf("%d files written, want 8", len(r.Files))
+		}
+		if f := r.Flagged(); len(f) != 0 {
+			t.Errorf("flagged %+v", f[0])
+		}
+
+		dir := filepath.Join("output", "gradient")
+		for name, size := range map[string]int{
+			"favicon-16x16.png":          16,
+			"apple-touch-icon.png":       180,
+			"android-chrome-512x512.png": 512,
+		} {
+			f, err := os.Open(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg, _, err := image.DecodeConfig(f)
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Width != size || cfg.Height != size {
+				t.Errorf("%s: %dx%d, want %dx%d", name, cfg.Width, cfg.Height, size, size)
+			}
+		}
+
+		f, err := os.Open(filepath.Join(dir, "favicon.ico"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		icons, err := ico.DecodeAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(icons) != 4 {
+			t.Errorf("favicon.ico has %d images, want 4", len(icons))
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "site.webmanifest"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var manifest struct {
+			Icons []struct{ Src string }
+		}
+		if err := json.Unmarshal(b, &manifest); err != nil {
+			t.Fatal(err)
+		}
+		if len(manifest.Icons) != 2 {
+			t.Errorf("manifest has %d icons, want 2", len(manifest.Icons))
+		}
+	}
+}
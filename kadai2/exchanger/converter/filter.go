@@ -0,0 +1,111 @@
+package converter
+
+import (
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/imaging"
+	"image"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// filterParam is an argument of a filter, ranging from min to max inclusive.
+type filterParam struct {
+	name     string
+	min, max float64
+	// def is the value of an optional argument which is left out.
+	def float64
+}
+
+// filterSpec is a filter Operations.Filters accepts.
+type filterSpec struct {
+	usage string
+	// required is the number of the params which cannot be left out.
+	required int
+	params   []filterParam
+	build    func(args []float64) imaging.Filter
+}
+
+var filterSpecs = map[string]filterSpec{
+	"gamma": {"gamma=G", 1, []filterParam{{"gamma", 0.01, 100, 0}},
+		func(a []float64) imaging.Filter { return imaging.Gamma(a[0]) }},
+	"saturation": {"saturation=S", 1, []filterParam{{"saturation", 0, 100, 0}},
+		func(a []float64) imaging.Filter { return imaging.Saturation(a[0]) }},
+	"hue": {"hue=DEGREES", 1, []filterParam{{"degrees", -360, 360, 0}},
+		func(a []float64) imaging.Filter { return imaging.HueShift(a[0]) }},
+	"sepia": {"sepia[=AMOUNT]", 0, []filterParam{{"amount", 0, 1, 1}},
+		func(a []float64) imaging.Filter { return imaging.Sepia(a[0]) }},
+	"invert": {"invert", 0, nil,
+		func(a []float64) imaging.Filter { return imaging.Invert() }},
+	"blur": {"blur=SIGMA", 1, []filterParam{{"sigma", 0.1, 100, 0}},
+		func(a []float64) imaging.Filter { return imaging.GaussianBlur(a[0]) }},
+	"sharpen": {"sharpen[=AMOUNT]", 0, []filterParam{{"amount", 0, 10, 1}},
+		func(a []float64) imaging.Filter { return imaging.UnsharpMask(1, a[0], 0) }},
+	"unsharp": {"unsharp=SIGMA,AMOUNT[,THRESHOLD]", 2, []filterParam{{"sigma", 0.1, 100, 0}, {"amount", 0, 10, 0}, {"threshold", 0, 1, 0}},
+		func(a []float64) imaging.Filter { return imaging.UnsharpMask(a[0], a[1], a[2]) }},
+	"threshold": {"threshold[=LEVEL]", 0, []filterParam{{"level", 0, 1, 0.5}},
+		func(a []float64) imaging.Filter { return imaging.Threshold(a[0]) }},
+}
+
+// FilterUsages returns the filters Operations.Filters accepts with their arguments,
+// such as "blur=SIGMA". Arguments in brackets can be left out.
+func FilterUsages() []string {
+	var us []string
+	for _, s := range filterSpecs {
+		us = append(us, s.usage)
+	}
+	sort.Strings(us)
+	return us
+}
+
+func filterOperation(specs []string) (operation, error) {
+	var fs []imaging.Filter
+	for _, spec := range specs {
+		f, err := parseFilter(spec)
+		if err != nil {
+			return nil, err
+		}
+		fs = append(fs, f)
+	}
+
+	return func(img image.Image, _ string) (image.Image, error) {
+		return imaging.ApplyFilters(img, fs...), nil
+	}, nil
+}
+
+// parseFilter parses a filter with its comma-separated arguments, such as "unsharp=1,0.8,0.02".
+func parseFilter(spec string) (imaging.Filter, error) {
+	name, arg := spec, ""
+	if i := strings.Index(spec, "="); i >= 0 {
+		name, arg = spec[:i], spec[i+1:]
+	}
+	fs, ok := filterSpecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown filter %q", name)
+	}
+
+	var args []float64
+	if arg != "" {
+		for _, s := range strings.Split(arg, ",") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil || math.IsNaN(v) {
+				return nil, fmt.Errorf("filter %s: invalid number %q", name, s)
+			}
+			args = append(args, v)
+		}
+	}
+	if len(args) < fs.required || len(args) > len(fs.params) {
+		return nil, fmt.Errorf("filter %s: want %s", name, fs.usage)
+	}
+	for i, p := range fs.params {
+		if i >= len(args) {
+			args = append(args, p.def)
+		}
+		if args[i] < p.min || args[i] > p.max {
+			return nil, fmt.Errorf("filter %s: %s must be between %g and %g", name, p.name, p.min, p.max)
+		}
+	}
+
+	return fs.build(args), nil
+}
@@ -0,0 +1,84 @@
+package converter
+
+import (
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		spec string
+		err  string
+	}{
+		{"gamma=2.2", ""},
+		{"saturation=0", ""},
+		{"hue=-90", ""},
+		{"sepia", ""},
+		{"sepia=0.5", ""},
+		{"invert", ""},
+		{"blur=1.5", ""},
+		{"sharpen", ""},
+		{"unsharp=1,0.8", ""},
+		{"unsharp=1, 0.8, 0.02", ""},
+		{"threshold", ""},
+		{"emboss", `unknown filter "emboss"`},
+		{"gamma", "filter gamma: want gamma=G"},
+		{"invert=1", "filter invert: want invert"},
+		{"unsharp=1", "filter unsharp: want unsharp=SIGMA,AMOUNT"},
+		{"gamma=0", "gamma must be between"},
+		{"sepia=2", "amount must be between 0 and 1"},
+		{"blur=x", `invalid number "x"`},
+		{"threshold=NaN", "invalid number"},
+	}
+
+	for _, tt := range tests {
+		_, err := parseFilter(tt.spec)
+		helper.TestErrorMatch(t, err, tt.err)
+	}
+	if us := FilterUsages(); len(us) != len(filterSpecs) || us[0] != "blur=SIGMA" {
+		t.Errorf("FilterUsages = %v", us)
+	}
+}
+
+func TestConvertFilters(t *testing.T) {
+	src := helper.WriteTree(t, map[string]image.Image{
+		"page.png": helper.Framed(32, 16, 4, color.NRGBA{200, 60, 60, 255}, color.NRGBA{30, 30, 30, 255}),
+	}, nil)
+
+	tests := []struct {
+		filters []string
+		// 枠の内側と枠の色として期待する色
+		inner, border color.Color
+		wantError     bool
+	}{
+		// OCR の前処理のように、白黒にしてから反転する。
+		{[]string{"saturation=0", "threshold=0.3", "invert"}, color.Gray{0}, color.Gray{255}, false},
+		// 順序を変えると結果も変わる。
+		{[]string{"invert", "saturation=0", "threshold=0.3"}, color.Gray{255}, color.Gray{255}, false},
+		{[]string{"sepia", "blur=1", "sharpen"}, nil, nil, false},
+		{[]string{"invert", "blur"}, nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.OutDir = t.TempDir()
+		opts.Filters = tt.filters
+		r, err := Convert(src, "png", "png", opts)
+		helper.TestWantError(t, err, tt.wantError)
+		if err != nil || tt.inner == nil {
+			continue
+		}
+
+		img := helper.DecodeFile(t, r.Files[0].Dst)
+		for _, p := range []struct {
+			pt   image.Point
+			want color.Color
+		}{{image.Pt(16, 8), tt.inner}, {image.Pt(1, 1), tt.border}} {
+			if got, want := color.GrayModel.Convert(img.At(p.pt.X, p.pt.Y)), color.GrayModel.Convert(p.want); got != want {
+				t.Errorf("%v: pixel %v = %v, want %v", tt.filters, p.pt, got, want)
+			}
+		}
+	}
+}
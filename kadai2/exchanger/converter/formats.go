@@ -0,0 +1,154 @@
+package converter
+
+import (
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/codec/bmp"
+	"gopher-dojo/kadai2/exchanger/codec/ico"
+	"gopher-dojo/kadai2/exchanger/codec/netpbm"
+	"gopher-dojo/kadai2/exchanger/codec/qoi"
+	"gopher-dojo/kadai2/exchanger/codec/tiff"
+	"gopher-dojo/kadai2/exchanger/imaging"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// format is an image format the converter can read and write.
+// The decoders are registered to the image package by importing the codec packages.
+type format struct {
+	encode func(w io.Writer, img image.Image, opts *Options) error
+	// mediaType is the MIME type of the format.
+	mediaType string
+	// lossless reports whether the format keeps the pixels of full color images.
+	lossless bool
+	// deep reports whether the format keeps 16-bit samples. Images with 16-bit
+	// samples are rounded to 8 bits before they are encoded in the other formats.
+	deep bool
+	// reference returns the image an output decodes to if it is not the encoded one,
+	// such as a rendition of different size. Nil means the encoded image.
+	reference func(img image.Image, opts *Options) image.Image
+}
+
+var formats = map[string]format{
+	"jpg":  {encode: encodeJPEG, mediaType: "image/jpeg"},
+	"jpeg": {encode: encodeJPEG, mediaType: "image/jpeg"},
+	"png":  {encode: encodePNG, mediaType: "image/png", lossless: true, deep: true},
+	"pbm":  {encode: netpbmEncoder(netpbm.PBM), mediaType: "image/x-portable-bitmap"},
+	"pgm":  {encode: netpbmEncoder(netpbm.PGM), mediaType: "image/x-portable-graymap", deep: true},
+	"ppm":  {encode: netpbmEncoder(netpbm.PPM), mediaType: "image/x-portable-pixmap", lossless: true, deep: true, reference: flatReference},
+	"pnm":  {encode: netpbmEncoder(netpbm.PPM), mediaType: "image/x-portable-anymap", lossless: true, deep: true, reference: flatReference},
+	"qoi":  {encode: encodeQOI, mediaType: "image/qoi", lossless: true},
+	"bmp":  {encode: encodeBMP, mediaType: "image/bmp", lossless: true},
+	"tif":  {encode: encodeTIFF, mediaType: "image/tiff", lossless: true, deep: true},
+	"tiff": {encode: encodeTIFF, mediaType: "image/tiff", lossless: true, deep: true},
+	"ico":  {encode: encodeICO, mediaType: "image/vnd.microsoft.icon", lossless: true, reference: icoReference},
+}
+
+// defaultICOSizes are the sizes of ICO renditions when Options.ICOSizes is empty.
+var defaultICOSizes = []int{16, 32, 48, 256}
+
+// MediaType returns the MIME type of the format named by the extension ext,
+// or an empty string if the format is not supported.
+func MediaType(ext string) string {
+	return formats[strings.ToLower(ext)].mediaType
+}
+
+// tiffCompressions maps the values of Options.TIFFCompression to the compressions.
+var tiffCompressions = map[string]tiff.CompressionType{
+	"":        tiff.Uncompressed,
+	"none":    tiff.Uncompressed,
+	"lzw":     tiff.LZW,
+	"deflate": tiff.Deflate,
+}
+
+func encodePNG(w io.Writer, img image.Image, opts *Options) error {
+	if opts.Colors > 0 {
+		img = imaging.Quantize(img, opts.Colors, opts.Dither)
+	}
+
+	return png.Encode(w, img)
+}
+
+func netpbmEncoder(f netpbm.Format) func(io.Writer, image.Image, *Options) error {
+	return func(w io.Writer, img image.Image, _ *Options) error {
+		return netpbm.Encode(w, img, &netpbm.Options{Format: f})
+	}
+}
+
+func encodeQOI(w io.Writer, img image.Image, _ *Options) error {
+	return qoi.Encode(w, img)
+}
+
+func encodeBMP(w io.Writer, img image.Image, _ *Options) error {
+	return bmp.Encode(w, img)
+}
+
+func encodeTIFF(w io.Writer, img image.Image, opts *Options) error {
+	c, ok := tiffCompressions[opts.TIFFCompression]
+	if !ok {
+		return fmt.Errorf("unknown TIFF compression %q", opts.TIFFCompression)
+	}
+
+	return tiff.Encode(w, img, &tiff.Options{Compression: c, Predictor: c != tiff.Uncompressed})
+}
+
+func icoSizes(opts *Options) []int {
+	if len(opts.ICOSizes) == 0 {
+		return defaultICOSizes
+	}
+	return opts.ICOSizes
+}
+
+// encodeICO packs the square renditions of img in the sizes of opts.ICOSizes.
+func encodeICO(w io.Writer, img image.Image, opts *Options) error {
+	var images []image.Image
+	for _, s := range icoSizes(opts) {
+		images = append(images, imaging.Contain(img, s, s))
+	}
+
+	return ico.Encode(w, images)
+}
+
+// flatReference returns img composited onto black, which is what the formats without alpha
+// write when img is not opaque.
+func flatReference(img image.Image, _ *Options) image.Image {
+	if o, ok := img.(interface{ Opaque() bool }); ok && o.Opaque() {
+		return img
+	}
+
+	b := img.Bounds()
+	if imaging.HighBitDepth(img) {
+		dst := image.NewRGBA64(b)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c := color.RGBA64Model.Convert(img.At(x, y)).(color.RGBA64)
+				c.A = 0xffff
+				dst.SetRGBA64(x, y, c)
+			}
+		}
+		return dst
+	}
+	dst := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			c.A = 0xff
+			dst.SetRGBA(x, y, c)
+		}
+	}
+	return dst
+}
+
+// icoReference returns the largest rendition, which is what ICO outputs decode to.
+func icoReference(img image.Image, opts *Options) image.Image {
+	largest := 0
+	for _, s := range icoSizes(opts) {
+		if s > largest {
+			largest = s
+		}
+	}
+
+	return imaging.Contain(img, largest, largest)
+}
@@ -0,0 +1,141 @@
+package converter
+
+import (
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// encoders returns the encoders of all the supported formats for helper.WriteTree.
+// ICO files have a single rendition of 48 pixels to keep the size of the test images.
+func encoders() map[string]helper.Encoder {
+	opts := DefaultOptions()
+	opts.ICOSizes = []int{48}
+	encs := map[string]helper.Encoder{}
+	for ext, f := range formats {
+		f := f
+		encs[ext] = func(w io.Writer, img image.Image) error { return f.encode(w, img, opts) }
+	}
+
+	return encs
+}
+
+// sampleImages are the images of sampleTree by their paths, laid out like testdata/sample
+// with the same names in different directories and an upper case extension.
+func sampleImages() map[string]image.Image {
+	g := helper.Gradient(64, 48)
+	return map[string]image.Image{
+		"dojo1.png":                 g,
+		"dojo2.png":                 g,
+		"sample2/dojo3.png":         g,
+		"sample2/dojo5.jpg":         g,
+		"sample2/sample3/dojo4.png": g,
+		"sample4/dojo2.png":         g,
+		"sample4/dojo3.jpg":         g,
+		"sample4/dojo5.png":         g,
+		"sample4/sample5/dojo6.PNG": g,
+	}
+}
+
+// sampleTree writes sampleImages to a temporary directory and returns it.
+func sampleTree(t *testing.T) string {
+	return helper.WriteTree(t, sampleImages(), encoders())
+}
+
+// countSamples returns the number of sampleImages with the extension ext in any case.
+func countSamples(ext string) int {
+	n := 0
+	for name := range sampleImages() {
+		if strings.EqualFold(filepath.Ext(name), "."+ext) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestConvertFromEachFormat(t *testing.T) {
+	black, white := color.NRGBA{0, 0, 0, 255}, color.NRGBA{255, 255, 255, 255}
+	checker := helper.Checker(48, 48, 8, black, white)
+	gradient := helper.Gradient(48, 48)
+	tests := []struct {
+		ext       string
+		img       image.Image
+		tolerance int
+	}{
+		{"jpg", gradient, 16},
+		{"jpeg", gradient, 16},
+		{"png", gradient, 0},
+		{"pbm", checker, 0},
+		{"pgm", checker, 0},
+		{"ppm", gradient, 0},
+		{"pnm", gradient, 0},
+		{"qoi", gradient, 0},
+		{"bmp", gradient, 0},
+		{"tif", gradient, 0},
+		{"tiff", gradient, 0},
+		{"ico", gradient, 0},
+	}
+	if len(tests) != len(formats) {
+		t.Fatalf("%d formats are tested, want all the %d formats", len(tests), len(formats))
+	}
+
+	images := map[string]image.Image{}
+	for _, tt := range tests {
+		images["src/"+tt.ext+"/image."+tt.ext] = tt.img
+	}
+	dir := helper.WriteTree(t, images, encoders())
+
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.OutDir = filepath.Join(dir, "out", tt.ext)
+		// 元の形式が png の場合は、変換が必要になるよう 8 ビットに丸める。
+		opts.Depth = 8
+		r, err := Convert(filepath.Join(dir, "src", tt.ext), tt.ext, "png", opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.ext, err)
+			continue
+		}
+		if got := helper.Files(t, opts.OutDir); strings.Join(got, " ") != "image.png" {
+			t.Errorf("%s: wrote %v, want image.png", tt.ext, got)
+			continue
+		}
+		if err := helper.CompareImages(tt.img, helper.DecodeFile(t, r.Files[0].Dst), tt.tolerance); err != nil {
+			t.Errorf("%s: %v", tt.ext, err)
+		}
+	}
+}
+
+func TestConvertGolden(t *testing.T) {
+	tests := []struct {
+		name      string
+		to        string
+		opts      func(opts *Options)
+		tolerance int
+	}{
+		{"jpeg-quality-50", "jpg", func(opts *Options) { opts.Quality = 50 }, 2},
+		{"png-16-colors-dithered", "png", func(opts *Options) { opts.Colors = 16; opts.Dither = true }, 0},
+		{"trim-alpha-padded", "png", func(opts *Options) { opts.Trim = &Trim{Mode: "alpha", Padding: 2} }, 0},
+		{"ico-contained", "ico", func(opts *Options) { opts.ICOSizes = []int{16, 32} }, 0},
+	}
+
+	framed := helper.Framed(64, 48, 8, color.NRGBA{200, 40, 40, 255}, color.NRGBA{})
+	src := helper.WriteTree(t, map[string]image.Image{"gradient.png": helper.Gradient(64, 48), "framed.png": framed}, nil)
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.OutDir = t.TempDir()
+		tt.opts(opts)
+		r, err := Convert(src, "png", tt.to, opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		for _, f := range r.Files {
+			golden := filepath.Join("testdata", "golden", tt.name, filename(f.Dst)+".png")
+			helper.TestGoldenImage(t, helper.DecodeFile(t, f.Dst), golden, tt.tolerance)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package converter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistoryFile is the name of the history log written in the output directory.
+const HistoryFile = ".exchanger-history.jsonl"
+
+// ErrUnknownRun is returned when a run is not in the history.
+var ErrUnknownRun = errors.New("unknown run")
+
+// Run is a conversion recorded in the history log, one JSON object per line.
+type Run struct {
+	// ID is the time of the run in seconds, with a suffix if another run has the same one.
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Files are the outputs with their sources, in the order of the report.
+	Files      []RunFile  `json:"files"`
+	Options    *Options   `json:"options"`
+	Operations Operations `json:"operations"`
+	Layout     string     `json:"layout,omitempty"`
+}
+
+// RunFile is an output of a run.
+type RunFile struct {
+	// Source is the absolute path of the source. It is empty for the files which cover
+	// all the sources, such as a manifest.
+	Source string `json:"source,omitempty"`
+	// Output is the slash-separated path of the output relative to the output directory.
+	Output string `json:"output"`
+}
+
+// now is replaced in the tests.
+var now = time.Now
+
+// recordRun appends the files of report to the history log of the output directory of opts.
+// Nothing is recorded if no file was written.
+func recordRun(report *Report, opts *Options) error {
+	if len(report.Files) == 0 {
+		return nil
+	}
+	dir := opts.outDir()
+	runs, err := ReadHistory(dir)
+	if err != nil {
+		return err
+	}
+
+	t := now()
+	run := Run{ID: t.Format("20060102-150405"), Time: t, Options: opts, Operations: opts.Operations, Layout: opts.Layout}
+	for i := 2; findRun(runs, run.ID) >= 0; i++ {
+		run.ID = t.Format("20060102-150405") + "-" + strconv.Itoa(i)
+	}
+	for _, f := range report.Files {
+		rf := RunFile{}
+		if f.Src != "" {
+			if rf.Source, err = filepath.Abs(f.Src); err != nil {
+				return err
+			}
+		}
+		rel, err := filepath.Rel(dir, f.Dst)
+		if err != nil {
+			return err
+		}
+		rf.Output = filepath.ToSlash(rel)
+		run.Files = append(run.Files, rf)
+	}
+
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, HistoryFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// ReadHistory returns the runs recorded in the output directory dir from the oldest.
+// A directory without a history log has no runs.
+func ReadHistory(dir string) ([]Run, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, HistoryFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []Run
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(nil, len(data)+1)
+	for line := 1; s.Scan(); line++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		var r Run
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", HistoryFile, line, err)
+		}
+		runs = append(runs, r)
+	}
+
+	return runs, s.Err()
+}
+
+// CleanRun deletes the outputs of the run id in the output directory dir and removes the run
+// from the history. id may be "last" for the latest run. The outputs which a later run wrote
+// again are kept. It returns the deleted paths, or the paths it would delete if dryRun is set.
+func CleanRun(dir, id string, dryRun bool) ([]string, error) {
+	runs, err := ReadHistory(dir)
+	if err != nil {
+		return nil, err
+	}
+	i := findRun(runs, id)
+	if id == "last" {
+		i = len(runs) - 1
+	}
+	if i < 0 {
+		return nil, fmt.Errorf("%w %q", ErrUnknownRun, id)
+	}
+
+	later := map[string]bool{}
+	for _, r := range runs[i+1:] {
+		for _, f := range r.Files {
+			later[f.Output] = true
+		}
+	}
+	var outputs []string
+	for _, f := range runs[i].Files {
+		if !later[f.Output] {
+			outputs = append(outputs, f.Output)
+		}
+	}
+	if dryRun {
+		return existing(dir, outputs)
+	}
+
+	removed, err := removeOutputs(dir, outputs)
+	if err != nil {
+		return removed, err
+	}
+	return removed, writeHistory(dir, append(runs[:i:i], runs[i+1:]...))
+}
+
+// CleanStale deletes the outputs in the output directory dir whose sources no longer exist,
+// as recorded by the latest run which wrote each of them, and removes them from the history.
+// The files without a source are stale when none of the sources of their run exist.
+// It returns the deleted paths, or the paths it would delete if dryRun is set.
+func CleanStale(dir string, dryRun bool) ([]string, error) {
+	runs, err := ReadHistory(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// 後の実行で書き直された出力は、その実行のソースで判断する。
+	latest := map[string]int{}
+	for i, r := range runs {
+		for _, f := range r.Files {
+			latest[f.Output] = i
+		}
+	}
+	stale := map[string]bool{}
+	var outputs []string
+	for i, r := range runs {
+		alive := false
+		for _, f := range r.Files {
+			if f.Source != "" && exists(f.Source) {
+				alive = true
+			}
+		}
+		for _, f := range r.Files {
+			if latest[f.Output] != i || stale[f.Output] {
+				continue
+			}
+			if f.Source != "" && !exists(f.Source) || f.Source == "" && !alive {
+				stale[f.Output] = true
+				outputs = append(outputs, f.Output)
+			}
+		}
+	}
+	if dryRun {
+		return existing(dir, outputs)
+	}
+
+	removed, err := removeOutputs(dir, outputs)
+	if err != nil {
+		return removed, err
+	}
+	var kept []Run
+	for _, r := range runs {
+		files := r.Files[:0:0]
+		for _, f := range r.Files {
+			if !stale[f.Output] {
+				files = append(files, f)
+			}
+		}
+		if len(files) > 0 {
+			r.Files = files
+			kept = append(kept, r)
+		}
+	}
+	return removed, writeHistory(dir, kept)
+}
+
+func findRun(runs []Run, id string) int {
+	for i, r := range runs {
+		if r.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeOutputs deletes outputs relative to dir and the directories they leave empty.
+// The outputs which were already deleted are ignored.
+func removeOutputs(dir string, outputs []string) ([]string, error) {
+	// 履歴は手で書き換えられるので、一つでも出力ディレクトリの外を指していれば何も消さない。
+	for _, o := range outputs {
+		if _, err := outputPath(dir, o); err != nil {
+			return nil, err
+		}
+	}
+
+	var removed []string
+	for _, o := range outputs {
+		p, _ := outputPath(dir, o)
+		if err := os.Remove(p); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return removed, err
+		}
+		removed = append(removed, p)
+
+		// ミラー配置で空になったディレクトリを dir の手前まで消す。
+		for d := filepath.Dir(p); d != filepath.Clean(dir) && d != "."; d = filepath.Dir(d) {
+			if os.Remove(d) != nil {
+				break
+			}
+		}
+	}
+
+	return removed, nil
+}
+
+// existing returns the paths of the outputs relative to dir which exist.
+func existing(dir string, outputs []string) ([]string, error) {
+	var paths []string
+	for _, o := range outputs {
+		p, err := outputPath(dir, o)
+		if err != nil {
+			return nil, err
+		}
+		if exists(p) {
+			paths = append(paths, p)
+		}
+	}
+	return paths, nil
+}
+
+// outputPath returns the path of the output o recorded in the history of dir.
+// It is an error if o is absolute or does not name a file under dir.
+func outputPath(dir, o string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(o))
+	if filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" || rel == "." || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: output %q is outside the output directory", HistoryFile, o)
+	}
+	return filepath.Join(dir, rel), nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// writeHistory replaces the history log of dir with runs, or deletes it if runs is empty.
+func writeHistory(dir string, runs []Run) error {
+	path := filepath.Join(dir, HistoryFile)
+	if len(runs) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range runs {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	// 途中で失敗しても元の履歴が壊れないよう、書き終えてから置き換える。
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,139 @@
+package converter
+
+import (
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC) }
+
+	// 消せるように、ソースを一時ディレクトリに書く。
+	src := helper.WriteTree(t, map[string]image.Image{"a/dojo1.png": helper.Gradient(64, 48), "b/dojo2.png": helper.Gradient(32, 24)}, nil)
+
+	opts := DefaultOptions()
+	opts.OutDir = t.TempDir()
+	opts.Layout = "mirror"
+	if _, err := Convert(src, "png", "jpg", opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Convert(src, "png", "qoi", opts); err != nil {
+		t.Fatal(err)
+	}
+	// 手で置いたファイルは消さない。
+	if err := ioutil.WriteFile(filepath.Join(opts.OutDir, "a", "hand.txt"), nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := ReadHistory(opts.OutDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].ID != "20210304-050607" || runs[1].ID != "20210304-050607-2" {
+		t.Fatalf("runs = %+v", runs)
+	}
+	if f := runs[0].Files[0]; f.Source != filepath.Join(src, "a", "dojo1.png") || f.Output != "a/dojo1.jpg" {
+		t.Errorf("first file = %+v", f)
+	}
+	if runs[1].Layout != "mirror" || runs[1].Options.Quality != opts.Quality {
+		t.Errorf("run options = %+v, layout %q", runs[1].Options, runs[1].Layout)
+	}
+
+	// ソースを消すと、その出力だけが古くなる。
+	if err := os.Remove(filepath.Join(src, "b", "dojo2.png")); err != nil {
+		t.Fatal(err)
+	}
+	dry, err := CleanStale(opts.OutDir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err := CleanStale(opts.OutDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "b/dojo2.jpg b/dojo2.qoi"; outputs(opts.OutDir, removed) != want || outputs(opts.OutDir, dry) != want {
+		t.Errorf("CleanStale removed %v, dry run %v, want %s", removed, dry, want)
+	}
+	if _, err := os.Stat(filepath.Join(opts.OutDir, "b")); !os.IsNotExist(err) {
+		t.Errorf("empty directory is left: %v", err)
+	}
+
+	removed, err = CleanRun(opts.OutDir, "last", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a/dojo1.qoi"; outputs(opts.OutDir, removed) != want {
+		t.Errorf("CleanRun(last) removed %v, want %s", removed, want)
+	}
+	_, err = CleanRun(opts.OutDir, "20210304-050607-2", false)
+	helper.TestErrorIs(t, err, ErrUnknownRun)
+	if _, err := CleanRun(opts.OutDir, "20210304-050607", false); err != nil {
+		t.Fatal(err)
+	}
+
+	// 履歴が空になればログも消え、手で置いたファイルは残る。
+	left, _ := filepath.Glob(filepath.Join(opts.OutDir, "*", "*"))
+	if len(left) != 1 || filepath.Base(left[0]) != "hand.txt" {
+		t.Errorf("left %v, want only hand.txt", left)
+	}
+	if _, err := os.Stat(filepath.Join(opts.OutDir, HistoryFile)); !os.IsNotExist(err) {
+		t.Errorf("history log is left: %v", err)
+	}
+}
+
+func outputs(dir string, paths []string) string {
+	var rels []string
+	for _, p := range paths {
+		rel, _ := filepath.Rel(dir, p)
+		rels = append(rels, filepath.ToSlash(rel))
+	}
+	sort.Strings(rels)
+	return strings.Join(rels, " ")
+}
+
+func TestCleanOutsideOutputs(t *testing.T) {
+	// 出力ディレクトリの外のファイルを指すように書き換えられた履歴
+	root := t.TempDir()
+	dir := filepath.Join(root, "out")
+	if err := os.Mkdir(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	victim := filepath.Join(root, "victim.png")
+	if err := ioutil.WriteFile(victim, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		output string
+		err    string
+	}{
+		{"parent", "../victim.png", "outside the output directory"},
+		{"nested parent", "a/../../victim.png", "outside the output directory"},
+		{"absolute", filepath.ToSlash(victim), "outside the output directory"},
+		{"directory itself", ".", "outside the output directory"},
+		{"inside", "a/../dojo1.png", ""},
+	}
+
+	for _, tt := range tests {
+		line := `{"id": "1", "files": [{"output": "` + tt.output + `"}]}` + "\n"
+		if err := ioutil.WriteFile(filepath.Join(dir, HistoryFile), []byte(line), 0666); err != nil {
+			t.Fatal(err)
+		}
+		_, err := CleanRun(dir, "1", true)
+		helper.TestErrorMatch(t, err, tt.err)
+		_, err = CleanRun(dir, "1", false)
+		helper.TestErrorMatch(t, err, tt.err)
+		if _, err := os.Stat(victim); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+)
+
+// ImageInfo describes an image file without decoding its pixels.
+type ImageInfo struct {
+	Path       string `json:"path"`
+	Format     string `json:"format"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	ColorModel string `json:"colorModel"`
+	Bytes      int64  `json:"bytes"`
+}
+
+// Inspect reads the header of the image file path.
+func Inspect(path string) (*ImageInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	st, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	cfg, format, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &ImageInfo{
+		Path:       path,
+		Format:     format,
+		Width:      cfg.Width,
+		Height:     cfg.Height,
+		ColorModel: modelName(cfg.ColorModel),
+		Bytes:      st.Size(),
+	}, nil
+}
+
+// InspectAll inspects the supported files under dir in the order Convert visits them.
+func InspectAll(dir string) ([]*ImageInfo, error) {
+	fileNames := make(chan string)
+	go func() {
+		walkDir(dir, isSupported, fileNames)
+		close(fileNames)
+	}()
+	defer func() {
+		for range fileNames {
+		}
+	}()
+
+	var infos []*ImageInfo
+	for fn := range fileNames {
+		info, err := Inspect(fn)
+		if err != nil {
+			return infos, err
+		}
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+var modelNames = map[color.Model]string{
+	color.RGBAModel:    "RGBA",
+	color.RGBA64Model:  "RGBA64",
+	color.NRGBAModel:   "NRGBA",
+	color.NRGBA64Model: "NRGBA64",
+	color.AlphaModel:   "Alpha",
+	color.Alpha16Model: "Alpha16",
+	color.GrayModel:    "Gray",
+	color.Gray16Model:  "Gray16",
+	color.YCbCrModel:   "YCbCr",
+	color.NYCbCrAModel: "NYCbCrA",
+	color.CMYKModel:    "CMYK",
+}
+
+func modelName(m color.Model) string {
+	if p, ok := m.(color.Palette); ok {
+		return fmt.Sprintf("Paletted(%d)", len(p))
+	}
+	if name, ok := modelNames[m]; ok {
+		return name
+	}
+	return "unknown"
+}
@@ -0,0 +1,43 @@
+package converter
+
+import (
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		path      string
+		want      ImageInfo
+		wantError bool
+	}{
+		{"testdata/verify/gradient.jpg", ImageInfo{Format: "jpeg", Width: 64, Height: 48, ColorModel: "YCbCr"}, false},
+		{"testdata/sample/dojo1.png", ImageInfo{Format: "png", Width: 1456, Height: 598, ColorModel: "NRGBA"}, false},
+		{"testdata/nothing.png", ImageInfo{}, true},
+	}
+
+	for _, tt := range tests {
+		info, err := Inspect(tt.path)
+		helper.TestWantError(t, err, tt.wantError)
+		if err != nil {
+			continue
+		}
+		if info.Bytes == 0 {
+			t.Errorf("%s: size is 0", tt.path)
+		}
+		tt.want.Path, tt.want.Bytes = info.Path, info.Bytes
+		if *info != tt.want {
+			t.Errorf("Inspect(%s) = %+v, want %+v", tt.path, *info, tt.want)
+		}
+	}
+}
+
+func TestInspectAll(t *testing.T) {
+	infos, err := InspectAll(sampleTree(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := len(sampleImages()); len(infos) != want {
+		t.Errorf("inspected %d files, want %d", len(infos), want)
+	}
+}
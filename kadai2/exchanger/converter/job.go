@@ -0,0 +1,197 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Job is a conversion listed in a job file.
+//
+// A job file is a JSON object with the array "jobs":
+//
+//	{
+//	  "jobs": [
+//	    {
+//	      "name": "thumbnails",
+//	      "src": "photos",
+//	      "include": ["*.jpg", "raw/*.png"],
+//	      "targets": ["png", "qoi"],
+//	      "operations": {"watermark": {"path": "logo.png", "scale": 0.2}},
+//	      "options": {"colors": 64, "verify": true},
+//	      "out": "output/thumbnails",
+//	      "layout": "mirror"
+//	    }
+//	  ]
+//	}
+//
+// Relative paths in a job file are relative to the directory of the file, and so is the
+// default output directory.
+type Job struct {
+	// Name identifies the job in errors. Empty means "job <n>", counting from 1.
+	Name string `json:"name"`
+	// Src is the directory of the sources.
+	Src string `json:"src"`
+	// Include are the patterns of path.Match the sources must match, against either their
+	// slash-separated paths relative to Src or their base names. Empty means all the supported formats.
+	Include []string `json:"include"`
+	// Targets are the formats each source is converted to.
+	Targets []string `json:"targets"`
+	// Operations are applied to each source before it is encoded.
+	Operations Operations `json:"operations"`
+	// Options are the encoder options. The fields omitted in the file have the values of DefaultOptions.
+	Options *Options `json:"options"`
+	// Out is the output directory. LoadJobs sets it to "output" in the directory of the
+	// job file if it is omitted.
+	Out string `json:"out"`
+	// Layout is "flat" or "mirror" as Options.Layout.
+	Layout string `json:"layout"`
+}
+
+// LoadJobs reads the job file path.
+func LoadJobs(path string) ([]*Job, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Jobs []json.RawMessage `json:"jobs"`
+	}
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(file.Jobs) == 0 {
+		return nil, fmt.Errorf("%s: no jobs", path)
+	}
+
+	dir := filepath.Dir(path)
+	jobs := make([]*Job, len(file.Jobs))
+	for i, raw := range file.Jobs {
+		// 省略されたオプションが既定値になるよう、既定値の上にデコードする。
+		j := &Job{Name: "job " + strconv.Itoa(i+1), Options: DefaultOptions()}
+		d := json.NewDecoder(bytes.NewReader(raw))
+		d.DisallowUnknownFields()
+		if err := d.Decode(j); err != nil {
+			return nil, fmt.Errorf("%s: job %d: %w", path, i+1, err)
+		}
+		if j.Options == nil {
+			j.Options = DefaultOptions()
+		}
+
+		// 出力先の既定値も、作業ディレクトリではなくジョブファイルの場所から決める。
+		if j.Out == "" {
+			j.Out = "output"
+		}
+		j.Src = resolve(dir, j.Src)
+		j.Out = resolve(dir, j.Out)
+		if j.Operations.Watermark != nil {
+			j.Operations.Watermark.Path = resolve(dir, j.Operations.Watermark.Path)
+		}
+		jobs[i] = j
+	}
+
+	return jobs, nil
+}
+
+// resolve returns p relative to dir unless it is absolute or empty.
+func resolve(dir, p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+// RunJobs runs the jobs in order and returns their reports.
+// It stops at the first job which fails, and the last report contains the files it converted.
+func RunJobs(jobs []*Job) ([]*Report, error) {
+	var reports []*Report
+	for _, j := range jobs {
+		r, err := j.Run()
+		reports = append(reports, r)
+		if err != nil {
+			return reports, fmt.Errorf("%s: %w", j.Name, err)
+		}
+	}
+
+	return reports, nil
+}
+
+// Run runs the job. The returned report is never nil.
+func (j *Job) Run() (*Report, error) {
+	report := &Report{}
+	opts := DefaultOptions()
+	if j.Options != nil {
+		o := *j.Options
+		opts = &o
+	}
+	opts.Operations = j.Operations
+	opts.OutDir = j.Out
+	opts.Layout = j.Layout
+
+	if err := validateOptions(opts); err != nil {
+		return report, err
+	}
+	if j.Src == "" {
+		return report, errors.New("src is required")
+	}
+	if len(j.Targets) == 0 {
+		return report, errors.New("targets are required")
+	}
+	// 同じ名前で書き出すマニフェストが上書きされないよう、プリセットの出力形式は1つに限る。
+	if opts.Preset != "" && len(j.Targets) > 1 {
+		return report, errors.New("a preset takes only one target")
+	}
+	targets := make([]string, len(j.Targets))
+	for i, t := range j.Targets {
+		targets[i] = strings.ToLower(t)
+		if !opts.encodes(targets[i]) {
+			return report, fmt.Errorf("target %s is not supported", t)
+		}
+	}
+	match, err := includes(j.Src, j.Include, opts)
+	if err != nil {
+		return report, err
+	}
+
+	return report, convert(j.Src, walked(j.Src, match), targets, opts, report)
+}
+
+// includes returns a matcher for the files under src which opts can read and which match one
+// of patterns by their slash-separated paths relative to src or by their base names.
+func includes(src string, patterns []string, opts *Options) (func(p string) bool, error) {
+	for _, pat := range patterns {
+		if _, err := path.Match(pat, ""); err != nil {
+			return nil, fmt.Errorf("include pattern %q: %w", pat, err)
+		}
+	}
+
+	return func(p string) bool {
+		if !opts.decodes(strings.ToLower(strings.TrimPrefix(filepath.Ext(p), "."))) {
+			return false
+		}
+		if len(patterns) == 0 {
+			return true
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return false
+		}
+		rel = filepath.ToSlash(rel)
+		for _, pat := range patterns {
+			if ok, _ := path.Match(pat, rel); ok {
+				return true
+			}
+			if ok, _ := path.Match(pat, path.Base(rel)); ok {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
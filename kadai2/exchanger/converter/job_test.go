@@ -0,0 +1,100 @@
+package converter
+
+import (
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunJobs(t *testing.T) {
+	sample, err := filepath.Abs("testdata/sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify, err := filepath.Abs("testdata/verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		job  string
+		// 出力ディレクトリからの相対パス
+		want      []string
+		wantError bool
+	}{
+		{"mirror", `{"src": "` + sample + `", "include": ["sample4/*.jpg", "sample2/dojo5.jpg"], "targets": ["qoi"], "layout": "mirror"}`,
+			[]string{"sample2/dojo5.qoi", "sample4/dojo3.qoi"}, false},
+		{"flat with targets", `{"src": "` + verify + `", "targets": ["png", "BMP"], "options": {"verify": true}}`,
+			[]string{"gradient(1).bmp", "gradient(1).png", "gradient.bmp", "gradient.png"}, false},
+		{"base name", `{"src": "` + verify + `", "include": ["*.jpeg"], "targets": ["jpg"], "options": {"quality": 90}}`,
+			[]string{"gradient.jpg"}, false},
+		{"preset", `{"src": "` + verify + `", "include": ["*.jpg"], "targets": ["png"], "options": {"preset": "responsive", "widths": [32]}}`,
+			[]string{"gradient-32w.png", "srcset.html", "srcset.json"}, false},
+		{"preset with targets", `{"src": "` + verify + `", "targets": ["png", "jpg"], "options": {"preset": "favicon"}}`, nil, true},
+		{"no targets", `{"src": "` + verify + `"}`, nil, true},
+		{"unknown target", `{"src": "` + verify + `", "targets": ["hoge"]}`, nil, true},
+		{"bad pattern", `{"src": "` + verify + `", "include": ["["], "targets": ["png"]}`, nil, true},
+		{"bad options", `{"src": "` + verify + `", "targets": ["png"], "options": {"quality": 0}}`, nil, true},
+		{"unknown field", `{"src": "` + verify + `", "target": ["png"]}`, nil, true},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "jobs.json")
+		job := strings.Replace(tt.job, "{", `{"out": "out", `, 1)
+		if err := ioutil.WriteFile(path, []byte(`{"jobs": [`+job+`]}`), 0666); err != nil {
+			t.Fatal(err)
+		}
+
+		jobs, err := LoadJobs(path)
+		if err == nil {
+			_, err = RunJobs(jobs)
+		}
+		helper.TestWantError(t, err, tt.wantError)
+		if err != nil {
+			continue
+		}
+
+		got := helper.Files(t, filepath.Join(dir, "out"))
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: wrote %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadJobsDefaults(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jobs.json")
+	data := `{"jobs": [{"src": "in", "targets": ["png"], "options": {"colors": 16},
+		"operations": {"watermark": {"path": "logo.png"}}}, {"name": "second", "src": "/in", "targets": ["jpg"]}]}`
+	if err := ioutil.WriteFile(path, []byte(data), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := LoadJobs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("loaded %d jobs, want 2", len(jobs))
+	}
+
+	// 省略したオプションは既定値のまま、相対パスはジョブファイルからの相対になる。
+	j := jobs[0]
+	if j.Name != "job 1" || j.Options.Colors != 16 || j.Options.Quality != DefaultOptions().Quality {
+		t.Errorf("job 1 = %+v, options %+v", j, j.Options)
+	}
+	if j.Src != filepath.Join(dir, "in") || j.Operations.Watermark.Path != filepath.Join(dir, "logo.png") {
+		t.Errorf("paths are not resolved: src %s, watermark %s", j.Src, j.Operations.Watermark.Path)
+	}
+	// 出力先を省略しても、作業ディレクトリによらずジョブファイルの隣に書き出す。
+	if j.Out != filepath.Join(dir, "output") {
+		t.Errorf("default out = %s, want %s", j.Out, filepath.Join(dir, "output"))
+	}
+	if jobs[1].Name != "second" || jobs[1].Src != "/in" || jobs[1].Options.Quality != DefaultOptions().Quality {
+		t.Errorf("job 2 = %+v", jobs[1])
+	}
+}
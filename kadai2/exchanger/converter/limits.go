@@ -0,0 +1,102 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"runtime"
+)
+
+// ErrTooLarge is returned when an image exceeds Options.MaxPixels or Options.MemoryBudget.
+var ErrTooLarge = errors.New("image is too large")
+
+// bytesPerPixel are the sizes of the decoded pixels of the color models.
+var bytesPerPixel = map[color.Model]int64{
+	color.GrayModel:    1,
+	color.AlphaModel:   1,
+	color.Gray16Model:  2,
+	color.Alpha16Model: 2,
+	color.YCbCrModel:   3,
+	color.RGBAModel:    4,
+	color.NRGBAModel:   4,
+	color.CMYKModel:    4,
+	color.NYCbCrAModel: 4,
+	color.RGBA64Model:  8,
+	color.NRGBA64Model: 8,
+}
+
+// filterBytesPerPixel is the working set of Options.Filters: the samples as float64 and,
+// at the peak of the blur of UnsharpMask, two more buffers of the same size.
+const filterBytesPerPixel = 3 * 4 * 8
+
+// estimateMemory estimates the memory converting an image of cfg with opts takes: the decoded
+// image, a working copy with 16-bit samples, made by operations or the rounding to 8 bits,
+// and the buffers of the filters.
+func estimateMemory(cfg image.Config, opts *Options) int64 {
+	bpp, ok := bytesPerPixel[cfg.ColorModel]
+	if _, paletted := cfg.ColorModel.(color.Palette); paletted {
+		bpp, ok = 1, true
+	}
+	if !ok {
+		bpp = 8
+	}
+
+	if len(opts.Filters) > 0 {
+		bpp += filterBytesPerPixel
+	}
+
+	return int64(cfg.Width) * int64(cfg.Height) * (bpp + 8)
+}
+
+// checkSize reads the header of the file path and returns the estimated memory
+// to convert it. It fails with ErrTooLarge if the image exceeds the limits of opts.
+// The files read by commands are checked after the command instead, and estimated at zero.
+func checkSize(path string, opts *Options) (int64, error) {
+	if opts.decoder(path) != nil {
+		return 0, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return checkConfig(path, cfg, opts)
+}
+
+// checkConfig returns the estimated memory to convert the image name of cfg.
+// It fails with ErrTooLarge if the image exceeds the limits of opts.
+func checkConfig(name string, cfg image.Config, opts *Options) (int64, error) {
+	pixels := int64(cfg.Width) * int64(cfg.Height)
+	if opts.MaxPixels > 0 && pixels > opts.MaxPixels {
+		return 0, fmt.Errorf("%w: %s is %dx%d, over %d pixels", ErrTooLarge, name, cfg.Width, cfg.Height, opts.MaxPixels)
+	}
+	mem := estimateMemory(cfg, opts)
+	if opts.MemoryBudget > 0 && mem > opts.MemoryBudget {
+		return 0, fmt.Errorf("%w: %s needs about %d bytes, over the budget of %d bytes", ErrTooLarge, name, mem, opts.MemoryBudget)
+	}
+
+	return mem, nil
+}
+
+// checkImage checks the image name, as the operations returned it, against the limits of opts,
+// since the operations can make it larger than its source.
+func checkImage(name string, img image.Image, opts *Options) error {
+	size := img.Bounds().Size()
+	_, err := checkConfig(name, image.Config{ColorModel: img.ColorModel(), Width: size.X, Height: size.Y}, opts)
+	return err
+}
+
+func (o *Options) workers() int {
+	if o.Workers > 0 {
+		return o.Workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
@@ -0,0 +1,81 @@
+package converter
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestConvertLimits(t *testing.T) {
+	// sampleTree の画像は 64x48 の NRGBA で、1枚 64*48*(4+8) バイトと見積もられる。
+	const one = 64 * 48 * 12
+	tests := []struct {
+		name         string
+		maxPixels    int64
+		memoryBudget int64
+		workers      int
+		// padding は切り抜きの後に足す余白で、0 なら切り抜かない。
+		padding  int
+		filters  []string
+		tooLarge bool
+	}{
+		{"max pixels", 64*48 - 1, 0, 0, 0, nil, true},
+		{"memory budget", 0, one - 1, 0, 0, nil, true},
+		// 1枚ずつしか入らない予算でも、並行数に関わらず全部変換できる。
+		{"one at a time", 64 * 48, one + one/2, 8, 0, nil, false},
+		{"no limits", 0, 0, 1, 0, nil, false},
+		// 余白で大きくなった画像も上限を超えれば変換しない。
+		{"padded over max pixels", 64 * 48, 0, 1, 1, nil, true},
+		// フィルタの作業領域も見積もりに入る。
+		{"filters over memory budget", 0, one * 4, 1, 0, []string{"blur=1"}, true},
+	}
+
+	src := sampleTree(t)
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.OutDir = t.TempDir()
+		opts.MaxPixels = tt.maxPixels
+		opts.MemoryBudget = tt.memoryBudget
+		opts.Workers = tt.workers
+		opts.Filters = tt.filters
+		if tt.padding > 0 {
+			opts.Trim = &Trim{Mode: "alpha", Padding: tt.padding}
+		}
+		r, err := Convert(src, "png", "qoi", opts)
+		if got := errors.Is(err, ErrTooLarge); got != tt.tooLarge {
+			t.Errorf("%s: err = %v, want ErrTooLarge %v", tt.name, err, tt.tooLarge)
+		}
+		if want := countSamples("png"); !tt.tooLarge && len(r.Files) != want {
+			t.Errorf("%s: %d files converted, want %d", tt.name, len(r.Files), want)
+		}
+	}
+}
+
+func TestConvertDeterministic(t *testing.T) {
+	src := sampleTree(t)
+	var want []string
+	for _, workers := range []int{1, 8, 3} {
+		opts := DefaultOptions()
+		opts.OutDir = "output"
+		opts.Workers = workers
+		r, err := Convert(src, "png", "bmp", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 同名のファイルに付く (n) も含めて、並行数によらず同じ名前と順序になる。
+		var got []string
+		for _, f := range r.Files {
+			got = append(got, f.Src+" "+f.Dst)
+		}
+		if want == nil {
+			want = got
+		} else if !reflect.DeepEqual(got, want) {
+			t.Errorf("workers %d: %v, want %v", workers, got, want)
+		}
+	}
+	if err := os.RemoveAll("output"); err != nil {
+		t.Error("failed to delete an output folder")
+	}
+}
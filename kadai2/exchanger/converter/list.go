@@ -0,0 +1,80 @@
+package converter
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadList reads the paths listed in r, one per line or separated by NUL bytes if nul is set,
+// like the outputs of `git diff --name-only` and `find -print0`. Empty entries are ignored.
+func ReadList(r io.Reader, nul bool) ([]string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sep := []byte{'\n'}
+	if nul {
+		sep = []byte{0}
+	}
+	var paths []string
+	for _, b := range bytes.Split(data, sep) {
+		p := string(b)
+		if !nul {
+			p = strings.TrimSuffix(p, "\r")
+		}
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+
+	return paths, nil
+}
+
+// ConvertList converts the files of paths with the extension from to the extension to with opts,
+// instead of walking a directory. The other files are ignored, and the listed files which do not
+// exist or are not regular files are added to Report.Skipped, so that a list of changed files
+// may include deleted ones. Relative paths keep their directories in the mirror layout.
+func ConvertList(paths []string, from, to string, opts *Options) (*Report, error) {
+	from = strings.ToLower(from)
+	to = strings.ToLower(to)
+
+	report := &Report{}
+	if err := validateOptions(opts); err != nil {
+		return report, err
+	}
+	if err := validateArgs(from, to, opts); err != nil {
+		return report, err
+	}
+
+	match := hasExt(from)
+	var files []string
+	seen := map[string]bool{}
+	for _, p := range paths {
+		p = filepath.Clean(p)
+		if !match(p) || seen[p] {
+			continue
+		}
+		seen[p] = true
+		if fi, err := os.Stat(p); err != nil || !fi.Mode().IsRegular() {
+			report.Skipped = append(report.Skipped, p)
+			continue
+		}
+		files = append(files, p)
+	}
+
+	return report, convert(".", listed(files), []string{to}, opts, report)
+}
+
+// listed returns a source of convert which sends paths in order.
+func listed(paths []string) func(fileNames chan<- string) {
+	return func(fileNames chan<- string) {
+		for _, p := range paths {
+			fileNames <- p
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package converter
+
+import (
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestReadList(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		nul  bool
+		want []string
+	}{
+		{"lines", "a.png\nb/c.jpg\n", false, []string{"a.png", "b/c.jpg"}},
+		{"crlf and blank lines", "a.png\r\n\r\n b.png\r\n", false, []string{"a.png", " b.png"}},
+		{"nul", "a b.png\x00c\nd.png\x00", true, []string{"a b.png", "c\nd.png"}},
+		{"empty", "", false, nil},
+	}
+
+	for _, tt := range tests {
+		got, err := ReadList(strings.NewReader(tt.in), tt.nul)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("%s: ReadList = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConvertList(t *testing.T) {
+	tests := []struct {
+		name   string
+		paths  []string
+		from   string
+		layout string
+		// 出力ディレクトリからの相対パス
+		want      []string
+		skipped   int
+		wantError bool
+	}{
+		{"flat", []string{"testdata/sample/dojo1.png", "testdata/sample/sample4/dojo2.png", "testdata/sample/sample2/dojo5.jpg"},
+			"png", "flat", []string{"dojo1.jpg", "dojo2.jpg"}, 0, false},
+		{"mirror", []string{"testdata/sample/sample2/dojo3.png", "./testdata/sample/sample2/dojo3.png", "testdata/sample/dojo1.png"},
+			"png", "mirror", []string{"testdata/sample/dojo1.jpg", "testdata/sample/sample2/dojo3.jpg"}, 0, false},
+		{"outside", []string{"../converter/testdata/sample/dojo2.png"}, "png", "mirror", []string{"dojo2.jpg"}, 0, false},
+		{"deleted and directories", []string{"testdata/sample/deleted.png", "testdata/sample/dojo1.png", "testdata/sample/sample2.png"},
+			"png", "flat", []string{"dojo1.jpg"}, 2, false},
+		{"nothing", []string{"testdata/sample/sample2/dojo5.jpg"}, "png", "flat", nil, 0, false},
+		{"bad from", []string{"testdata/sample/dojo1.png"}, "hoge", "flat", nil, 0, true},
+	}
+
+	// ディレクトリが .png で終わる場合も変換しない。
+	if err := os.MkdirAll("testdata/sample/sample2.png", 0777); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testdata/sample/sample2.png")
+
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.OutDir = t.TempDir()
+		opts.Layout = tt.layout
+		report, err := ConvertList(tt.paths, tt.from, "jpg", opts)
+		helper.TestWantError(t, err, tt.wantError)
+		if err != nil {
+			continue
+		}
+
+		var got []string
+		for _, f := range report.Files {
+			rel, _ := filepath.Rel(opts.OutDir, f.Dst)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		sort.Strings(got)
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: wrote %v, want %v", tt.name, got, tt.want)
+		}
+		if len(report.Skipped) != tt.skipped {
+			t.Errorf("%s: skipped %v, want %d files", tt.name, report.Skipped, tt.skipped)
+		}
+	}
+}
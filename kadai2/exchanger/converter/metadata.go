@@ -0,0 +1,66 @@
+package converter
+
+import (
+	"gopher-dojo/kadai2/exchanger/codec/metadata"
+	"image"
+	"path/filepath"
+)
+
+// carriesMetadata reports whether the metadata is written to the outputs in the format to.
+func carriesMetadata(to string) bool {
+	return to == "jpg" || to == "jpeg" || to == "png"
+}
+
+// withMetadata returns out, img encoded in the format to, with the metadata opts asks for.
+// src is the data of the source file name, which is needed only if opts keeps the metadata.
+// name is left out of the text if it is empty.
+func withMetadata(out, src []byte, name string, img image.Image, to string, opts *Options) ([]byte, error) {
+	if !carriesMetadata(to) {
+		return out, nil
+	}
+
+	m := &metadata.Metadata{}
+	if opts.Metadata == "keep" {
+		kept, err := metadata.Read(src)
+		if err != nil {
+			return nil, err
+		}
+		// 位置情報は残さない。
+		kept.StripGPS()
+		m.EXIF = kept.EXIF
+		if iccFits(kept.ICC, img) {
+			m.ICC = kept.ICC
+		}
+		m.Text = map[string]string{}
+		if name != "" {
+			m.Text["Source"] = filepath.Base(name)
+		}
+		if c := kept.Copyright(); c != "" {
+			m.Text["Copyright"] = c
+		}
+	}
+	for k, v := range opts.Text {
+		if m.Text == nil {
+			m.Text = map[string]string{}
+		}
+		m.Text[k] = v
+	}
+	if m.ICC == nil && m.EXIF == nil && len(m.Text) == 0 {
+		return out, nil
+	}
+
+	if to == "png" {
+		return metadata.InsertPNG(out, m)
+	}
+	return metadata.InsertJPEG(out, m)
+}
+
+// iccFits reports whether the ICC profile icc describes the colors of img as it is encoded.
+// The profiles of CMYK sources do not, since they are converted to RGB without them.
+func iccFits(icc []byte, img image.Image) bool {
+	switch img.(type) {
+	case *image.Gray, *image.Gray16:
+		return metadata.ICCColorSpace(icc) == "GRAY"
+	}
+	return metadata.ICCColorSpace(icc) == "RGB"
+}
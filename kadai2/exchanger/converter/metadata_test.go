@@ -0,0 +1,199 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"gopher-dojo/kadai2/exchanger/codec/metadata"
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// exifWithGPS returns a big-endian TIFF structure with the copyright and a GPS latitude.
+func exifWithGPS() []byte {
+	b := make([]byte, 96)
+	copy(b, "MM\x00\x2a\x00\x00\x00\x08")
+	be := binary.BigEndian
+	put := func(at int, tag, typ uint16, count, value uint32) {
+		be.PutUint16(b[at:], tag)
+		be.PutUint16(b[at+2:], typ)
+		be.PutUint32(b[at+4:], count)
+		be.PutUint32(b[at+8:], value)
+	}
+	// IFD0: 著作権と GPS IFD へのオフセット
+	be.PutUint16(b[8:], 2)
+	put(10, 0x8298, 2, 10, 56)
+	put(22, 0x8825, 4, 1, 38)
+	// GPS IFD: 緯度の有理数 3 つ
+	be.PutUint16(b[38:], 1)
+	put(40, 2, 5, 3, 66)
+	copy(b[56:], "Jane Doe\x00\x00")
+	for i := 66; i < 90; i++ {
+		b[i] = 0x11
+	}
+
+	return b
+}
+
+func TestConvertMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, helper.Gradient(16, 16), nil); err != nil {
+		t.Fatal(err)
+	}
+	icc := append(make([]byte, 16), "RGB profile"...)
+	data, err := metadata.InsertJPEG(buf.Bytes(), &metadata.Metadata{ICC: icc, EXIF: exifWithGPS()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(src, "photo.jpg"), data, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		to       string
+		metadata string
+		text     map[string]string
+		// 出力に期待するメタデータ
+		icc       bool
+		copyright string
+		wantText  map[string]string
+	}{
+		{"strip by default", "jpeg", "", nil, false, "", nil},
+		{"strip", "png", "strip", nil, false, "", nil},
+		{"keep in JPEG", "jpeg", "keep", nil, true, "Jane Doe", nil},
+		{"keep in PNG", "png", "keep", nil, true, "Jane Doe", map[string]string{"Source": "photo.jpg", "Copyright": "Jane Doe"}},
+		{"rewrite", "png", "keep", map[string]string{"Copyright": "© ACME"}, true, "Jane Doe",
+			map[string]string{"Source": "photo.jpg", "Copyright": "© ACME"}},
+		{"rewrite in JPEG", "jpeg", "keep", map[string]string{"Copyright": "© ACME"}, true, "© ACME", nil},
+		{"text only", "png", "strip", map[string]string{"Author": "ACME"}, false, "", map[string]string{"Author": "ACME"}},
+		{"other formats", "bmp", "keep", nil, false, "", nil},
+	}
+
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.OutDir = t.TempDir()
+		opts.Metadata = tt.metadata
+		opts.Text = tt.text
+		opts.Verify = true
+		r, err := Convert(src, "jpg", tt.to, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if f := r.Flagged(); len(f) > 0 {
+			t.Errorf("%s: flagged %+v", tt.name, f[0].Verification)
+		}
+
+		out, err := ioutil.ReadFile(r.Files[0].Dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := metadata.Read(out)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := m.ICC != nil; got != tt.icc {
+			t.Errorf("%s: ICC written = %v, want %v", tt.name, got, tt.icc)
+		}
+		if got := m.Copyright(); got != tt.copyright {
+			t.Errorf("%s: copyright = %q, want %q", tt.name, got, tt.copyright)
+		}
+		if m.EXIF != nil && !bytes.Equal(m.EXIF[40:52], make([]byte, 12)) || bytes.Contains(out, bytes.Repeat([]byte{0x11}, 24)) {
+			t.Errorf("%s: GPS is left", tt.name)
+		}
+		for k, v := range tt.wantText {
+			if m.Text[k] != v {
+				t.Errorf("%s: text %s = %q, want %q", tt.name, k, m.Text[k], v)
+			}
+		}
+		if len(m.Text) != len(tt.wantText) {
+			t.Errorf("%s: text = %v, want %v", tt.name, m.Text, tt.wantText)
+		}
+	}
+}
+
+func TestConvertMetadataPNGToJPEG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, helper.Gradient(16, 16)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := metadata.InsertPNG(buf.Bytes(), &metadata.Metadata{Text: map[string]string{"Copyright": "Jane Doe"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(src, "photo.png"), data, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		metadata  string
+		text      map[string]string
+		copyright string
+	}{
+		{"keep", "keep", nil, "Jane Doe"},
+		{"rewrite", "keep", map[string]string{"Copyright": "© ACME"}, "© ACME"},
+		{"text only", "strip", map[string]string{"Copyright": "© ACME"}, "© ACME"},
+		{"strip", "strip", nil, ""},
+	}
+
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.OutDir = t.TempDir()
+		opts.Metadata = tt.metadata
+		opts.Text = tt.text
+		r, err := Convert(src, "png", "jpg", opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		out, err := ioutil.ReadFile(r.Files[0].Dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := metadata.Read(out)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := m.Copyright(); got != tt.copyright {
+			t.Errorf("%s: copyright = %q, want %q", tt.name, got, tt.copyright)
+		}
+	}
+}
+
+func TestConvertImageMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	// グレーの画像に RGB のプロファイルは付けない。
+	icc := append(make([]byte, 16), "RGB profile"...)
+	data, err := metadata.InsertJPEG(buf.Bytes(), &metadata.Metadata{ICC: icc, EXIF: exifWithGPS()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.Metadata = "keep"
+	var out bytes.Buffer
+	if err := ConvertImage(&out, bytes.NewReader(data), "png", opts); err != nil {
+		t.Fatal(err)
+	}
+	m, err := metadata.Read(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ICC != nil || m.Copyright() != "Jane Doe" || m.Text["Source"] != "" {
+		t.Errorf("metadata = ICC %q, copyright %q, text %v", m.ICC, m.Copyright(), m.Text)
+	}
+
+	opts.Text = map[string]string{"Bad\nkey": "v"}
+	helper.TestErrorMatch(t, ConvertImage(&out, bytes.NewReader(data), "png", opts), "invalid PNG text keyword")
+	opts.Text, opts.Metadata = nil, "all"
+	helper.TestErrorMatch(t, ConvertImage(&out, bytes.NewReader(data), "png", opts), `unknown metadata "all"`)
+}
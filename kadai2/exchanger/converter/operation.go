@@ -0,0 +1,56 @@
+package converter
+
+import "image"
+
+// operation transforms a decoded image before it is encoded.
+// src is the path of the source file, which is empty if the image is not read from a file.
+type operation func(img image.Image, src string) (image.Image, error)
+
+// operations returns the operations opts asks for, in the order they are applied.
+// Files such as the watermark image are loaded here once for all the conversions.
+func operations(opts *Options) ([]operation, error) {
+	var ops []operation
+	// 透かしの位置が切り抜いた後の画像に対して決まるよう、切り抜きを先にする。
+	if opts.Trim != nil {
+		op, err := trimOperation(opts.Trim)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	if len(opts.Filters) > 0 {
+		op, err := filterOperation(opts.Filters)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	if opts.Watermark != nil {
+		op, err := watermarkOperation(opts.Watermark)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	// 文字は透かしにも隠れないよう最後に描く。
+	if opts.Caption != nil {
+		op, err := captionOperation(opts.Caption)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+
+	return ops, nil
+}
+
+func apply(img image.Image, src string, ops []operation) (image.Image, error) {
+	for _, op := range ops {
+		var err error
+		if img, err = op(img, src); err != nil {
+			return nil, err
+		}
+	}
+
+	return img, nil
+}
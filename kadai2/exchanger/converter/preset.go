@@ -0,0 +1,20 @@
+package converter
+
+import "image"
+
+// preset writes a set of outputs for each source image instead of a single file.
+type preset interface {
+	// write writes the outputs of img, which is decoded from src and named name.
+	// It is called concurrently for different sources.
+	write(src, name string, img image.Image) ([]FileResult, error)
+	// close writes the files which cover all the sources, such as a manifest.
+	// It writes nothing if no source was written.
+	close() ([]FileResult, error)
+}
+
+// presets maps the values of Options.Preset to the constructors of the presets,
+// which take the target format and the options of the conversion.
+var presets = map[string]func(to string, opts *Options) preset{
+	"favicon":    newFaviconPreset,
+	"responsive": newResponsivePreset,
+}
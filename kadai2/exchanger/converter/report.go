@@ -0,0 +1,44 @@
+package converter
+
+// Report is the result of a conversion.
+type Report struct {
+	Files []FileResult
+	// Skipped are the listed sources which were not converted since they do not exist
+	// or are not regular files.
+	Skipped []string
+}
+
+// Flagged returns the files which failed verification.
+func (r *Report) Flagged() []FileResult {
+	var flagged []FileResult
+	for _, f := range r.Files {
+		if f.Verification != nil && f.Verification.Flagged {
+			flagged = append(flagged, f)
+		}
+	}
+
+	return flagged
+}
+
+// FileResult is the result of converting a single file.
+type FileResult struct {
+	// Src is empty for the files which cover all the sources, such as a manifest.
+	Src string
+	Dst string
+	// Verification is set when Options.Verify is true.
+	Verification *Verification
+}
+
+// Verification is the comparison between an output and the image which was encoded.
+type Verification struct {
+	// Lossless reports whether the output is expected to have exactly the same pixels.
+	Lossless bool
+	// Identical reports whether the output has exactly the same pixels.
+	Identical bool
+	// PSNR is the peak signal-to-noise ratio in decibels. It is +Inf if the output is identical.
+	PSNR float64
+	// SSIM is the structural similarity index. It is 1 if the output is identical.
+	SSIM float64
+	// Flagged reports whether a lossless output differs or a lossy output is below the thresholds.
+	Flagged bool
+}
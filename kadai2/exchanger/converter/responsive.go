@@ -0,0 +1,198 @@
+package converter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/imaging"
+	"html"
+	"image"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultWidths are the widths of the responsive preset when Options.Widths is empty.
+var defaultWidths = []int{320, 640, 1280}
+
+// DefaultNameTemplate is the name template of the responsive preset when Options.NameTemplate is empty.
+const DefaultNameTemplate = "{name}-{width}w.{ext}"
+
+// SrcSet is the set of the images written from a source by the responsive preset.
+// The paths are relative to the output directory. Src and SrcSet are URLs with the
+// segments of the paths percent-encoded, so names with spaces and commas stay valid.
+type SrcSet struct {
+	Source string `json:"source"`
+	// Src is the largest image, for the src attribute.
+	Src    string            `json:"src"`
+	SrcSet string            `json:"srcset"`
+	Width  int               `json:"width"`
+	Height int               `json:"height"`
+	Images []ResponsiveImage `json:"images"`
+}
+
+// ResponsiveImage is an image in a SrcSet.
+type ResponsiveImage struct {
+	Path   string `json:"path"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// responsivePreset writes each source in Options.Widths without enlarging it,
+// and srcset.json and srcset.html in the output directory listing the srcset values
+// in the order of the source paths.
+type responsivePreset struct {
+	to   string
+	opts *Options
+
+	mu   sync.Mutex
+	sets []SrcSet
+}
+
+func newResponsivePreset(to string, opts *Options) preset {
+	return &responsivePreset{to: to, opts: opts}
+}
+
+func (p *responsivePreset) write(src, name string, img image.Image) ([]FileResult, error) {
+	// 出力形式の指定がなければ元の形式のまま書き出す。
+	to := p.to
+	if to == "" {
+		to = strings.ToLower(strings.TrimPrefix(filepath.Ext(src), "."))
+	}
+
+	size := img.Bounds().Size()
+	set := SrcSet{Source: src}
+	var results []FileResult
+	for _, w := range responsiveWidths(size.X, p.opts) {
+		s := imaging.Fit(size, w, size.Y)
+		file := expandName(p.opts.NameTemplate, name, s, to)
+		r, err := writeImage(src, filepath.Join(p.opts.outDir(), file), imaging.Resize(img, s.X, s.Y), to, p.opts)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, *r)
+		set.Images = append(set.Images, ResponsiveImage{Path: filepath.ToSlash(file), Width: s.X, Height: s.Y})
+	}
+
+	var srcset []string
+	for _, i := range set.Images {
+		srcset = append(srcset, fmt.Sprintf("%s %dw", escapePath(i.Path), i.Width))
+	}
+	largest := set.Images[len(set.Images)-1]
+	set.Src, set.SrcSet = escapePath(largest.Path), strings.Join(srcset, ", ")
+	set.Width, set.Height = largest.Width, largest.Height
+	p.mu.Lock()
+	p.sets = append(p.sets, set)
+	p.mu.Unlock()
+
+	return results, nil
+}
+
+func (p *responsivePreset) close() ([]FileResult, error) {
+	// 書き出した画像がなければ、空の一覧も作らない。
+	if len(p.sets) == 0 {
+		return nil, nil
+	}
+	// 並行に書き出すので、順序をソース順に揃える。
+	sort.Slice(p.sets, func(i, j int) bool { return p.sets[i].Source < p.sets[j].Source })
+	b, err := json.MarshalIndent(p.sets, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	var h strings.Builder
+	for _, s := range p.sets {
+		fmt.Fprintf(&h, "<img src=\"%s\" srcset=\"%s\" sizes=\"100vw\" width=\"%d\" height=\"%d\" alt=\"\">\n",
+			html.EscapeString(s.Src), html.EscapeString(s.SrcSet), s.Width, s.Height)
+	}
+
+	if err := os.MkdirAll(p.opts.outDir(), 0777); err != nil {
+		return nil, err
+	}
+	var results []FileResult
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{"srcset.json", append(b, '\n')},
+		{"srcset.html", []byte(h.String())},
+	} {
+		dst := filepath.Join(p.opts.outDir(), f.name)
+		if err := ioutil.WriteFile(dst, f.data, 0666); err != nil {
+			return results, err
+		}
+		results = append(results, FileResult{Dst: dst})
+	}
+
+	return results, nil
+}
+
+// escapePath percent-encodes each segment of the slash-separated path p for a URL.
+func escapePath(p string) string {
+	segs := strings.Split(p, "/")
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	return strings.Join(segs, "/")
+}
+
+// responsiveWidths returns the widths in opts.Widths not greater than width in ascending order.
+// If width is smaller than all of them, it returns width alone.
+func responsiveWidths(width int, opts *Options) []int {
+	widths := opts.Widths
+	if len(widths) == 0 {
+		widths = defaultWidths
+	}
+
+	var ws []int
+	for _, w := range widths {
+		if w <= width {
+			ws = append(ws, w)
+		}
+	}
+	if len(ws) == 0 {
+		return []int{width}
+	}
+	sort.Ints(ws)
+
+	// 重複した幅は同じファイル名になるので除く。
+	uniq := ws[:1]
+	for _, w := range ws[1:] {
+		if w != uniq[len(uniq)-1] {
+			uniq = append(uniq, w)
+		}
+	}
+	return uniq
+}
+
+// expandName replaces {name}, {width}, {height} and {ext} in template.
+func expandName(template, name string, size image.Point, ext string) string {
+	if template == "" {
+		template = DefaultNameTemplate
+	}
+
+	return strings.NewReplacer(
+		"{name}", name,
+		"{width}", strconv.Itoa(size.X),
+		"{height}", strconv.Itoa(size.Y),
+		"{ext}", ext,
+	).Replace(template)
+}
+
+func validateNameTemplate(template string) error {
+	if template == "" {
+		return nil
+	}
+	if !strings.Contains(template, "{name}") || !strings.Contains(template, "{width}") {
+		return errors.New("name template must contain {name} and {width}")
+	}
+	if strings.ContainsAny(template, `/\`) {
+		return errors.New("name template must not contain path separators")
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package converter
+
+import (
+	"encoding/json"
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConvertResponsive(t *testing.T) {
+	tests := []struct {
+		widths    []int
+		template  string
+		to        string
+		want      []ResponsiveImage
+		wantError bool
+	}{
+		// 元の幅 64 より大きい 128 は書き出さない。
+		{[]int{32, 128, 16}, "", "png", []ResponsiveImage{
+			{"gradient-16w.png", 16, 12}, {"gradient-32w.png", 32, 24},
+		}, false},
+		// すべて大きければ元の幅で1枚だけ書き出す。
+		{[]int{320, 640}, "{name}_{width}x{height}.{ext}", "", []ResponsiveImage{
+			{"gradient_64x48.jpg", 64, 48},
+		}, false},
+		{[]int{0}, "", "png", nil, true},
+		{nil, "{name}.{ext}", "png", nil, true},
+		{nil, "{width}/{name}.{ext}", "png", nil, true},
+	}
+
+	for _, tt := range tests {
+		if err := os.MkdirAll("output", 0777); err != nil {
+			t.Error("failed to make an output folder")
+		}
+
+		opts := DefaultOptions()
+		opts.Preset = "responsive"
+		opts.Widths = tt.widths
+		opts.NameTemplate = tt.template
+		opts.Verify = true
+		r, err := Convert("testdata/verify", "jpg", tt.to, opts)
+		helper.TestWantError(t, err, tt.wantError)
+		for _, i := range tt.want {
+			if _, err := os.Stat("output/" + i.Path); err != nil {
+				t.Errorf("%v: %v", tt.widths, err)
+			}
+		}
+		os.RemoveAll("output")
+		if err != nil {
+			continue
+		}
+
+		// 画像と srcset.json、srcset.html
+		if len(r.Files) != len(tt.want)+2 {
+			t.Errorf("%v: %d files written, want %d", tt.widths, len(r.Files), len(tt.want)+2)
+		}
+		if f := r.Flagged(); len(f) != 0 {
+			t.Errorf("%v: flagged %+v", tt.widths, f[0])
+		}
+	}
+}
+
+func TestConvertResponsiveManifest(t *testing.T) {
+	if err := os.MkdirAll("output", 0777); err != nil {
+		t.Error("failed to make an output folder")
+	}
+	defer os.RemoveAll("output")
+
+	opts := DefaultOptions()
+	opts.Preset = "responsive"
+	opts.Widths = []int{16, 32}
+	if _, err := Convert("testdata/verify", "jpg", "png", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile("output/srcset.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sets []SrcSet
+	if err := json.Unmarshal(b, &sets); err != nil {
+		t.Fatal(err)
+	}
+	want := []SrcSet{{
+		Source: "testdata/verify/gradient.jpg",
+		Src:    "gradient-32w.png",
+		SrcSet: "gradient-16w.png 16w, gradient-32w.png 32w",
+		Width:  32,
+		Height: 24,
+		Images: []ResponsiveImage{{"gradient-16w.png", 16, 12}, {"gradient-32w.png", 32, 24}},
+	}}
+	if !reflect.DeepEqual(sets, want) {
+		t.Errorf("srcset.json = %+v, want %+v", sets, want)
+	}
+
+	b, err = ioutil.ReadFile("output/srcset.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := `<img src="gradient-32w.png" srcset="gradient-16w.png 16w, gradient-32w.png 32w" sizes="100vw" width="32" height="24" alt="">` + "\n"; string(b) != h {
+		t.Errorf("srcset.html = %q, want %q", b, h)
+	}
+}
+
+func TestConvertResponsiveNoSources(t *testing.T) {
+	for _, exists := range []bool{false, true} {
+		opts := DefaultOptions()
+		opts.Preset = "responsive"
+		opts.OutDir = filepath.Join(t.TempDir(), "out")
+		if exists {
+			if err := os.Mkdir(opts.OutDir, 0777); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// testdata/verify に PNG はない。
+		r, err := Convert("testdata/verify", "png", "", opts)
+		if err != nil {
+			t.Fatalf("output directory exists %v: %v", exists, err)
+		}
+		if len(r.Files) != 0 {
+			t.Errorf("output directory exists %v: written %+v", exists, r.Files)
+		}
+		if _, err := os.Stat(filepath.Join(opts.OutDir, "srcset.json")); !os.IsNotExist(err) {
+			t.Errorf("output directory exists %v: srcset.json is written: %v", exists, err)
+		}
+	}
+}
+
+func TestConvertResponsiveEscape(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/verify/gradient.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(src, "my photo, v2.jpg"), data, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.Preset = "responsive"
+	opts.Widths = []int{16, 32}
+	opts.OutDir = t.TempDir()
+	if _, err := Convert(src, "jpg", "png", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(opts.OutDir, "srcset.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sets []SrcSet
+	if err := json.Unmarshal(b, &sets); err != nil {
+		t.Fatal(err)
+	}
+	// 空白とカンマは srcset の区切りなので、ファイル名の中ではエスケープする。
+	want := "my%20photo%2C%20v2-16w.png 16w, my%20photo%2C%20v2-32w.png 32w"
+	if len(sets) != 1 || sets[0].SrcSet != want || sets[0].Src != "my%20photo%2C%20v2-32w.png" {
+		t.Fatalf("srcset.json = %+v, want srcset %q", sets, want)
+	}
+	if sets[0].Images[0].Path != "my photo, v2-16w.png" {
+		t.Errorf("image path = %q, want the file name", sets[0].Images[0].Path)
+	}
+
+	b, err = ioutil.ReadFile(filepath.Join(opts.OutDir, "srcset.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `src="my%20photo%2C%20v2-32w.png" srcset="`+want+`"`) {
+		t.Errorf("srcset.html = %q", b)
+	}
+}
@@ -0,0 +1,239 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/imaging"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// SheetOptions configures MakeSheet.
+type SheetOptions struct {
+	// Mode is "grid" for a contact sheet of thumbnails or "sprite" for a packed sprite sheet.
+	Mode string
+	// From is the extension of the images to include. Empty includes all the supported formats.
+	From string
+	// Columns is the number of columns of a contact sheet.
+	Columns int
+	// Cell is the maximum width and height of each thumbnail of a contact sheet.
+	Cell int
+	// Padding is the space between the images and around the sheet.
+	Padding int
+	// Captions draws the file names under the thumbnails of a contact sheet.
+	Captions bool
+	// MaxPixels is the largest number of pixels of an image. Zero means no limit.
+	MaxPixels int64
+	// MemoryBudget limits the estimated memory in bytes of all the images, which are
+	// held at the same time. Zero means no limit.
+	MemoryBudget int64
+}
+
+// DefaultSheetOptions returns the options for a contact sheet of 4 columns,
+// with the limits of DefaultOptions.
+func DefaultSheetOptions() *SheetOptions {
+	def := DefaultOptions()
+	return &SheetOptions{Mode: "grid", Columns: 4, Cell: 128, Padding: 4, MaxPixels: def.MaxPixels, MemoryBudget: def.MemoryBudget}
+}
+
+// Sprite is the location of an image in a sheet.
+type Sprite struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+	W    int    `json:"w"`
+	H    int    `json:"h"`
+}
+
+// Sheet is the tiled image and the locations of the images in it.
+type Sheet struct {
+	Image   image.Image `json:"-"`
+	Width   int         `json:"width"`
+	Height  int         `json:"height"`
+	Sprites []Sprite    `json:"sprites"`
+}
+
+// WriteMap writes the locations of the images as JSON.
+func (s *Sheet) WriteMap(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// MakeSheet tiles the images under src into a single image.
+// Each image is checked against opts.MaxPixels by its header before it is decoded, and
+// fails with ErrTooLarge if it exceeds it or the images exceed opts.MemoryBudget together.
+// The sprite names are the file names without extensions, made unique in the same way as Convert.
+func MakeSheet(src string, opts *SheetOptions) (*Sheet, error) {
+	if opts.Mode != "grid" && opts.Mode != "sprite" {
+		return nil, fmt.Errorf("unknown sheet mode %q", opts.Mode)
+	}
+	if opts.Mode == "grid" && (opts.Columns < 1 || opts.Cell < 1) {
+		return nil, fmt.Errorf("columns and cell must be positive")
+	}
+	if opts.Padding < 0 {
+		return nil, fmt.Errorf("padding must not be negative")
+	}
+	if opts.MaxPixels < 0 || opts.MemoryBudget < 0 {
+		return nil, fmt.Errorf("max pixels and memory budget must not be negative")
+	}
+
+	match := isSupported
+	if opts.From != "" {
+		from := strings.ToLower(opts.From)
+		if _, ok := formats[from]; !ok {
+			return nil, fmt.Errorf("from is not supported")
+		}
+		match = hasExt(from)
+	}
+
+	fileNames := make(chan string)
+	go func() {
+		walkDir(src, match, fileNames)
+		close(fileNames)
+	}()
+	defer func() {
+		for range fileNames {
+		}
+	}()
+
+	var images []image.Image
+	var sprites []Sprite
+	names := uniqueNames{}
+	limits := &Options{MaxPixels: opts.MaxPixels, MemoryBudget: opts.MemoryBudget}
+	var total int64
+	for fn := range fileNames {
+		mem, err := checkSize(fn, limits)
+		if err != nil {
+			return nil, err
+		}
+		// シートは全ての画像を同時に持つので、見積もりの合計を予算と比べる。
+		if total += mem; opts.MemoryBudget > 0 && total > opts.MemoryBudget {
+			return nil, fmt.Errorf("%w: the images under %s need about %d bytes, over the budget of %d bytes",
+				ErrTooLarge, src, total, opts.MemoryBudget)
+		}
+		img, err := decodeFile(fn)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
+		images = append(images, img)
+		sprites = append(sprites, Sprite{Name: names.next(filename(fn)), Path: fn})
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("no images found in %s", src)
+	}
+
+	if opts.Mode == "sprite" {
+		return packSprites(images, sprites, opts.Padding), nil
+	}
+	return contactSheet(images, sprites, opts), nil
+}
+
+func contactSheet(images []image.Image, sprites []Sprite, opts *SheetOptions) *Sheet {
+	cols := opts.Columns
+	if len(images) < cols {
+		cols = len(images)
+	}
+	rows := (len(images) + cols - 1) / cols
+
+	captionHeight := 0
+	if opts.Captions {
+		captionHeight = imaging.TextSize("A", 1).Y + opts.Padding
+	}
+	cellW := opts.Cell + opts.Padding
+	cellH := opts.Cell + captionHeight + opts.Padding
+
+	sheet := &Sheet{Width: cols*cellW + opts.Padding, Height: rows*cellH + opts.Padding}
+	dst := image.NewRGBA(image.Rect(0, 0, sheet.Width, sheet.Height))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+
+	for i, img := range images {
+		x0 := opts.Padding + i%cols*cellW
+		y0 := opts.Padding + i/cols*cellH
+
+		// サムネイルはセルの中央に置く。
+		size := imaging.Fit(img.Bounds().Size(), opts.Cell, opts.Cell)
+		thumb := imaging.Resize(img, size.X, size.Y)
+		r := image.Rect(0, 0, size.X, size.Y).Add(image.Pt(x0+(opts.Cell-size.X)/2, y0+(opts.Cell-size.Y)/2))
+		draw.Draw(dst, r, thumb, image.Point{}, draw.Over)
+		sprites[i].X, sprites[i].Y, sprites[i].W, sprites[i].H = r.Min.X, r.Min.Y, size.X, size.Y
+
+		if opts.Captions {
+			caption := fitCaption(sprites[i].Name, opts.Cell)
+			w := imaging.TextSize(caption, 1).X
+			imaging.DrawText(dst, image.Pt(x0+(opts.Cell-w)/2, y0+opts.Cell+opts.Padding), caption, 1, color.Black)
+		}
+	}
+
+	sheet.Image = dst
+	sheet.Sprites = sprites
+	return sheet
+}
+
+// fitCaption shortens s with ".." to fit in width pixels.
+func fitCaption(s string, width int) string {
+	if imaging.TextSize(s, 1).X <= width {
+		return s
+	}
+
+	r := []rune(s)
+	for len(r) > 0 && imaging.TextSize(string(r)+"..", 1).X > width {
+		r = r[:len(r)-1]
+	}
+	return string(r) + ".."
+}
+
+// packSprites places the images on shelves from the tallest, keeping the sheet roughly square.
+func packSprites(images []image.Image, sprites []Sprite, padding int) *Sheet {
+	order := make([]int, len(images))
+	area, maxW := 0, 0
+	for i, img := range images {
+		order[i] = i
+		s := img.Bounds().Size()
+		sprites[i].W, sprites[i].H = s.X, s.Y
+		area += (s.X + padding) * (s.Y + padding)
+		if s.X > maxW {
+			maxW = s.X
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return sprites[order[a]].H > sprites[order[b]].H })
+
+	width := int(math.Ceil(math.Sqrt(float64(area))))
+	if width < maxW+2*padding {
+		width = maxW + 2*padding
+	}
+
+	x, y, shelf := padding, padding, 0
+	sheet := &Sheet{}
+	for _, i := range order {
+		s := &sprites[i]
+		if x+s.W+padding > width {
+			x, y, shelf = padding, y+shelf+padding, 0
+		}
+		s.X, s.Y = x, y
+		x += s.W + padding
+		if s.H > shelf {
+			shelf = s.H
+		}
+		if x > sheet.Width {
+			sheet.Width = x
+		}
+	}
+	sheet.Height = y + shelf + padding
+
+	dst := image.NewNRGBA(image.Rect(0, 0, sheet.Width, sheet.Height))
+	for i, img := range images {
+		s := sprites[i]
+		draw.Draw(dst, image.Rect(s.X, s.Y, s.X+s.W, s.Y+s.H), img, img.Bounds().Min, draw.Src)
+	}
+
+	sheet.Image = dst
+	sheet.Sprites = sprites
+	return sheet
+}
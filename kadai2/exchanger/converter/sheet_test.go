@@ -0,0 +1,97 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"testing"
+)
+
+func TestMakeSheet(t *testing.T) {
+	src := sampleTree(t)
+	tests := []struct {
+		src       string
+		opts      SheetOptions
+		width     int
+		height    int
+		wantError bool
+	}{
+		{"testdata/verify", SheetOptions{Mode: "grid", Columns: 4, Cell: 32, Padding: 2}, 70, 36, false},
+		{"testdata/verify", SheetOptions{Mode: "grid", Columns: 1, Cell: 32, Padding: 2, Captions: true}, 36, 90, false},
+		{"testdata/verify", SheetOptions{Mode: "sprite", Padding: 1}, 0, 0, false},
+		{src, SheetOptions{Mode: "sprite", From: "jpg"}, 0, 0, false},
+		{"testdata/verify", SheetOptions{Mode: "mosaic"}, 0, 0, true},
+		{"testdata/verify", SheetOptions{Mode: "grid", Columns: 0, Cell: 32}, 0, 0, true},
+		{"testdata/verify", SheetOptions{Mode: "sprite", From: "hoge"}, 0, 0, true},
+		{"testdata/nothing", SheetOptions{Mode: "sprite"}, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		opts := tt.opts
+		sheet, err := MakeSheet(tt.src, &opts)
+		helper.TestWantError(t, err, tt.wantError)
+		if err != nil {
+			continue
+		}
+
+		if tt.width != 0 && (sheet.Width != tt.width || sheet.Height != tt.height) {
+			t.Errorf("%+v: size = %dx%d, want %dx%d", tt.opts, sheet.Width, sheet.Height, tt.width, tt.height)
+		}
+		if sheet.Image.Bounds() != image.Rect(0, 0, sheet.Width, sheet.Height) {
+			t.Errorf("%+v: image bounds = %v", tt.opts, sheet.Image.Bounds())
+		}
+
+		// 画像同士が重ならず、シートに収まっているかの確認
+		for i, a := range sheet.Sprites {
+			ra := image.Rect(a.X, a.Y, a.X+a.W, a.Y+a.H)
+			if !ra.In(sheet.Image.Bounds()) {
+				t.Errorf("%+v: %s is out of the sheet: %v", tt.opts, a.Name, ra)
+			}
+			for _, b := range sheet.Sprites[i+1:] {
+				if ra.Overlaps(image.Rect(b.X, b.Y, b.X+b.W, b.Y+b.H)) {
+					t.Errorf("%+v: %s overlaps %s", tt.opts, a.Name, b.Name)
+				}
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := sheet.WriteMap(&buf); err != nil {
+			t.Fatal(err)
+		}
+		var m Sheet
+		if err := json.Unmarshal(buf.Bytes(), &m); err != nil || len(m.Sprites) != len(sheet.Sprites) {
+			t.Errorf("%+v: map is broken: %v", tt.opts, err)
+		}
+	}
+}
+
+func TestMakeSheetLimits(t *testing.T) {
+	// testdata/verify には 64x48 の YCbCr の画像が 2 つあり、1 つで 33792 バイトと見積もられる。
+	tests := []struct {
+		name         string
+		maxPixels    int64
+		memoryBudget int64
+		tooLarge     bool
+	}{
+		{"within limits", 64 * 48, 2 * 33792, false},
+		{"over max pixels", 64*48 - 1, 0, true},
+		{"one fits the budget", 0, 40000, true},
+		{"no limits", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		opts := DefaultSheetOptions()
+		opts.MaxPixels, opts.MemoryBudget = tt.maxPixels, tt.memoryBudget
+		_, err := MakeSheet("testdata/verify", opts)
+		if got := errors.Is(err, ErrTooLarge); got != tt.tooLarge {
+			t.Errorf("%s: error = %v, want too large %v", tt.name, err, tt.tooLarge)
+		}
+	}
+
+	opts := DefaultSheetOptions()
+	opts.MemoryBudget = -1
+	_, err := MakeSheet("testdata/verify", opts)
+	helper.TestErrorMatch(t, err, "must not be negative")
+}
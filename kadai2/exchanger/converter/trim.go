@@ -0,0 +1,40 @@
+package converter
+
+import (
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/imaging"
+	"image"
+)
+
+// maxTrimPadding is the largest Trim.Padding, which keeps a tiny source from growing into
+// a huge output before the size of the result is checked.
+const maxTrimPadding = 1024
+
+// Trim removes the uniform borders of each image.
+type Trim struct {
+	// Mode is "color" to remove the pixels close to the top-left pixel, or "alpha"
+	// to remove the transparent pixels. Empty means "color".
+	Mode string `json:"mode"`
+	// Tolerance is the largest difference from the border color, or the largest alpha
+	// in the alpha mode, from 0 to 1.
+	Tolerance float64 `json:"tolerance"`
+	// Padding is the width in pixels of the border added after trimming, up to 1024.
+	Padding int `json:"padding"`
+}
+
+func trimOperation(t *Trim) (operation, error) {
+	if t.Mode != "" && t.Mode != "color" && t.Mode != "alpha" {
+		return nil, fmt.Errorf("unknown trim mode %q", t.Mode)
+	}
+	if t.Tolerance < 0 || t.Tolerance > 1 {
+		return nil, fmt.Errorf("trim tolerance must be between 0 and 1")
+	}
+	if t.Padding < 0 || t.Padding > maxTrimPadding {
+		return nil, fmt.Errorf("trim padding must be between 0 and %d", maxTrimPadding)
+	}
+
+	alpha := t.Mode == "alpha"
+	return func(img image.Image, _ string) (image.Image, error) {
+		return imaging.Trim(img, alpha, t.Tolerance, t.Padding), nil
+	}, nil
+}
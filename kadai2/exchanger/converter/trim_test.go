@@ -0,0 +1,65 @@
+package converter
+
+import (
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvertTrim(t *testing.T) {
+	// 白い余白の中に黒い 10x5 の四角がある画像
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(10, 20, 20, 25), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	src := t.TempDir()
+	f, err := os.Create(filepath.Join(src, "margin.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	png.Encode(f, img)
+	f.Close()
+
+	tests := []struct {
+		trim      Trim
+		want      image.Point
+		wantError bool
+	}{
+		{Trim{}, image.Pt(10, 5), false},
+		{Trim{Padding: 3}, image.Pt(16, 11), false},
+		// 不透明な画像は alpha では切り抜かれない。
+		{Trim{Mode: "alpha"}, image.Pt(40, 30), false},
+		{Trim{Mode: "edge"}, image.Point{}, true},
+		{Trim{Tolerance: 2}, image.Point{}, true},
+		{Trim{Padding: -1}, image.Point{}, true},
+		{Trim{Padding: 1025}, image.Point{}, true},
+	}
+
+	if err := os.MkdirAll("output", 0777); err != nil {
+		t.Error("failed to make an output folder")
+	}
+	defer os.RemoveAll("output")
+
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		trim := tt.trim
+		opts.Trim = &trim
+		_, err := Convert(src, "png", "bmp", opts)
+		helper.TestWantError(t, err, tt.wantError)
+		if err != nil {
+			continue
+		}
+
+		info, err := Inspect("output/margin.bmp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := image.Pt(info.Width, info.Height); got != tt.want {
+			t.Errorf("%+v: size = %v, want %v", tt.trim, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package converter
+
+import (
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/imaging"
+	"image"
+	"io/ioutil"
+	"math"
+)
+
+// verify decodes the file dst and compares it with want, the image which was encoded.
+// The outputs of commands are decoded by the decode command of their format.
+func verify(want image.Image, dst, to string, opts *Options) (*Verification, error) {
+	var got image.Image
+	var err error
+	if args := opts.decoder(dst); args != nil {
+		var data []byte
+		if data, err = ioutil.ReadFile(dst); err == nil {
+			got, err = decodeCommand(dst, data, args, opts)
+		}
+	} else {
+		got, err = decodeFile(dst)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if ref := formats[to].reference; ref != nil {
+		want = ref(want, opts)
+	}
+
+	// 減色した PNG はパレットに丸められるので可逆ではない。
+	return compare(want, got, formats[to].lossless && opts.Colors == 0, opts)
+}
+
+// CompareFiles decodes the image files want and got and compares got with want.
+// Verification.Flagged reports whether got is below opts.MinPSNR or opts.MinSSIM.
+func CompareFiles(want, got string, opts *Options) (*Verification, error) {
+	w, err := decodeFile(want)
+	if err != nil {
+		return nil, err
+	}
+	g, err := decodeFile(got)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := compare(w, g, false, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s and %s: %w", want, got, err)
+	}
+	return v, nil
+}
+
+func compare(want, got image.Image, lossless bool, opts *Options) (*Verification, error) {
+	v := &Verification{Lossless: lossless}
+	var err error
+	if v.Identical, err = imaging.Identical(want, got); err != nil {
+		return nil, err
+	}
+	if v.Identical {
+		v.PSNR, v.SSIM = math.Inf(1), 1
+		return v, nil
+	}
+
+	if v.PSNR, err = imaging.PSNR(want, got); err != nil {
+		return nil, err
+	}
+	if v.SSIM, err = imaging.SSIM(want, got); err != nil {
+		return nil, err
+	}
+	v.Flagged = v.Lossless || v.PSNR < opts.MinPSNR || v.SSIM < opts.MinSSIM
+
+	return v, nil
+}
@@ -0,0 +1,71 @@
+package converter
+
+import (
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/imaging"
+	"image"
+	"math"
+)
+
+// Watermark is an image composited onto each output.
+type Watermark struct {
+	// Path is the watermark image file.
+	Path string `json:"path"`
+	// Anchor is where the watermark is placed: "top-left", "top", "top-right", "left", "center",
+	// "right", "bottom-left", "bottom" or "bottom-right". Empty means "bottom-right".
+	Anchor string `json:"anchor"`
+	// Margin is the distance in pixels from the edges of the output.
+	Margin int `json:"margin"`
+	// Opacity is multiplied to the alpha of the watermark, above 0 and up to 1.
+	// Nil means 1, so the watermark is opaque unless it is given.
+	Opacity *float64 `json:"opacity"`
+	// Scale is the width of the watermark relative to the width of the output.
+	// Zero keeps the size of the watermark image.
+	Scale float64 `json:"scale"`
+}
+
+func watermarkOperation(w *Watermark) (operation, error) {
+	name := w.Anchor
+	if name == "" {
+		name = "bottom-right"
+	}
+	anchor, err := imaging.ParseAnchor(name)
+	if err != nil {
+		return nil, err
+	}
+	opacity := 1.0
+	if w.Opacity != nil {
+		opacity = *w.Opacity
+	}
+	// 0 では何も描かれないので、指定の誤りとして扱う。
+	if opacity <= 0 || opacity > 1 {
+		return nil, fmt.Errorf("watermark opacity must be greater than 0 and at most 1")
+	}
+	if w.Scale < 0 || w.Scale > 1 {
+		return nil, fmt.Errorf("watermark scale must be between 0 and 1")
+	}
+	if w.Margin < 0 {
+		return nil, fmt.Errorf("watermark margin must not be negative")
+	}
+
+	mark, err := decodeFile(w.Path)
+	if err != nil {
+		return nil, fmt.Errorf("watermark: %w", err)
+	}
+
+	return func(img image.Image, _ string) (image.Image, error) {
+		m := mark
+		if w.Scale > 0 {
+			// 出力の幅に合わせて、縦横比を保ったまま拡大縮小する。
+			size := mark.Bounds().Size()
+			width := int(math.Round(float64(img.Bounds().Dx()) * w.Scale))
+			height := int(math.Round(float64(size.Y) * float64(width) / float64(size.X)))
+			if width < 1 || height < 1 {
+				return img, nil
+			}
+			m = imaging.Resize(mark, width, height)
+		}
+
+		return imaging.Overlay(img, m, anchor, w.Margin, opacity), nil
+	}, nil
+}
@@ -0,0 +1,76 @@
+package converter
+
+import (
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvertWatermark(t *testing.T) {
+	mark := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for i := 0; i < len(mark.Pix); i += 4 {
+		copy(mark.Pix[i:], []byte{0xff, 0, 0, 0xff})
+	}
+	markPath := filepath.Join(t.TempDir(), "mark.png")
+	f, err := os.Create(markPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	png.Encode(f, mark)
+	f.Close()
+
+	zero, one, two := 0.0, 1.0, 2.0
+	tests := []struct {
+		watermark Watermark
+		// 透かしが入るはずの位置と入らないはずの位置
+		inside, outside image.Point
+		wantError       bool
+	}{
+		{Watermark{Path: markPath, Anchor: "top-left"}, image.Pt(3, 1), image.Pt(4, 2), false},
+		// 幅 64 の 1/4 に拡大されて 16x8 になる。
+		{Watermark{Path: markPath, Anchor: "top-left", Scale: 0.25}, image.Pt(15, 7), image.Pt(16, 8), false},
+		{Watermark{Path: markPath, Margin: 2}, image.Pt(61, 45), image.Pt(62, 46), false},
+		{Watermark{Path: markPath, Anchor: "middle"}, image.Point{}, image.Point{}, true},
+		{Watermark{Path: markPath, Opacity: &one}, image.Pt(63, 47), image.Pt(59, 45), false},
+		{Watermark{Path: markPath, Opacity: &zero}, image.Point{}, image.Point{}, true},
+		{Watermark{Path: markPath, Opacity: &two}, image.Point{}, image.Point{}, true},
+		{Watermark{Path: "testdata/nothing.png"}, image.Point{}, image.Point{}, true},
+	}
+
+	if err := os.MkdirAll("output", 0777); err != nil {
+		t.Error("failed to make an output folder")
+	}
+	defer os.RemoveAll("output")
+
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		w := tt.watermark
+		opts.Watermark = &w
+		_, err := Convert("testdata/verify", "jpg", "png", opts)
+		helper.TestWantError(t, err, tt.wantError)
+		if err != nil {
+			continue
+		}
+
+		f, err := os.Open("output/gradient.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c := color.RGBAModel.Convert(img.At(tt.inside.X, tt.inside.Y)); c != red {
+			t.Errorf("%+v: pixel %v = %v, want the watermark", tt.watermark, tt.inside, c)
+		}
+		if c := color.RGBAModel.Convert(img.At(tt.outside.X, tt.outside.Y)); c == red {
+			t.Errorf("%+v: pixel %v has the watermark", tt.watermark, tt.outside)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/converter"
+)
+
+func (cli *CLI) runDiff(args []string) int {
+	fs := cli.flagSet("diff", "Compares the images under two directories by their paths regardless of the formats, and fails if any differ.")
+	tolerance := fs.Int("tolerance", 0, "largest difference of an 8-bit channel which is not a change")
+	visualize := fs.String("visualize", "", "directory to write PNG images marking the changed pixels in red")
+	maxPixels := fs.Int64("max-pixels", converter.DefaultOptions().MaxPixels, "largest number of pixels of an image (0 means no limit)")
+	asJSON := fs.Bool("json", false, "print all the images as JSON")
+	if err := fs.Parse(args); err != nil {
+		return exitCode(err)
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 1
+	}
+
+	diffs, err := converter.DiffTrees(fs.Arg(0), fs.Arg(1), &converter.DiffOptions{Tolerance: *tolerance, Visualize: *visualize, MaxPixels: *maxPixels})
+	if err != nil {
+		fmt.Fprintln(cli.errStream, err)
+		return 1
+	}
+
+	counts := make(map[string]int)
+	for _, d := range diffs {
+		counts[d.Status]++
+	}
+	code := 0
+	if counts[converter.DiffSame] < len(diffs) {
+		code = 1
+	}
+
+	if *asJSON {
+		if diffs == nil {
+			diffs = []converter.Difference{}
+		}
+		enc := json.NewEncoder(cli.outStream)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(diffs); err != nil {
+			fmt.Fprintln(cli.errStream, err)
+			return 1
+		}
+		return code
+	}
+
+	for _, d := range diffs {
+		switch d.Status {
+		case converter.DiffAdded:
+			fmt.Fprintf(cli.outStream, "added    %s\n", d.B)
+		case converter.DiffRemoved:
+			fmt.Fprintf(cli.outStream, "removed  %s\n", d.A)
+		case converter.DiffChanged:
+			if d.Size != "" {
+				fmt.Fprintf(cli.outStream, "changed  %s -> %s  resized %s\n", d.A, d.B, d.Size)
+			} else {
+				fmt.Fprintf(cli.outStream, "changed  %s -> %s  %d pixels, max %d\n", d.A, d.B, d.Pixels, d.Max)
+			}
+			if d.Visual != "" {
+				fmt.Fprintf(cli.outStream, "         %s\n", d.Visual)
+			}
+		}
+	}
+	fmt.Fprintf(cli.outStream, "%d added, %d removed, %d changed, %d same\n",
+		counts[converter.DiffAdded], counts[converter.DiffRemoved], counts[converter.DiffChanged], counts[converter.DiffSame])
+
+	return code
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/converter"
+)
+
+func (cli *CLI) runDupes(args []string) int {
+	def := converter.DefaultDupesOptions()
+	fs := cli.flagSet("dupes", "Groups near-duplicate images under the target directory by perceptual hash.")
+	hash := fs.String("hash", def.Hash, "perceptual hash: ahash or dhash")
+	distance := fs.Int("distance", def.Distance, "maximum Hamming distance between near-duplicates")
+	maxPixels := fs.Int64("max-pixels", def.MaxPixels, "largest number of pixels of an image (0 means no limit)")
+	asJSON := fs.Bool("json", false, "print the groups as JSON")
+	if err := fs.Parse(args); err != nil {
+		return exitCode(err)
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+
+	groups, err := converter.FindDuplicates(fs.Arg(0), &converter.DupesOptions{Hash: *hash, Distance: *distance, MaxPixels: *maxPixels})
+	// 読めないファイルは知らせて、残りのグループは表示する。
+	code := 0
+	var undecodable *converter.UndecodableError
+	if errors.As(err, &undecodable) {
+		for _, e := range undecodable.Errors {
+			fmt.Fprintln(cli.errStream, e)
+		}
+		code = 1
+	} else if err != nil {
+		fmt.Fprintln(cli.errStream, err)
+		return 1
+	}
+
+	if *asJSON {
+		if groups == nil {
+			groups = []converter.DuplicateGroup{}
+		}
+		enc := json.NewEncoder(cli.outStream)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(groups); err != nil {
+			fmt.Fprintln(cli.errStream, err)
+			return 1
+		}
+		return code
+	}
+
+	if len(groups) == 0 {
+		fmt.Fprintln(cli.outStream, "No duplicates found")
+		return code
+	}
+	for i, g := range groups {
+		if i > 0 {
+			fmt.Fprintln(cli.outStream)
+		}
+		for _, f := range g.Files {
+			fmt.Fprintf(cli.outStream, "%s  %dx%d  %s\n", f.Hash, f.Width, f.Height, f.Path)
+		}
+	}
+
+	return code
+}
@@ -0,0 +1,35 @@
+package imaging
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		s         string
+		want      color.NRGBA
+		wantError bool
+	}{
+		{"#ff8000", color.NRGBA{255, 128, 0, 255}, false},
+		{"#FF800080", color.NRGBA{255, 128, 0, 128}, false},
+		{"#f80", color.NRGBA{255, 136, 0, 255}, false},
+		{"#0008", color.NRGBA{0, 0, 0, 136}, false},
+		{"ff8000", color.NRGBA{}, true},
+		{"#ff80", color.NRGBA{255, 255, 136, 0}, false},
+		{"#gggggg", color.NRGBA{}, true},
+		{"#ff80000", color.NRGBA{}, true},
+		{"", color.NRGBA{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseColor(tt.s)
+		if (err != nil) != tt.wantError {
+			t.Errorf("ParseColor(%q) error = %v", tt.s, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseColor(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
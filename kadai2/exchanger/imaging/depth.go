@@ -0,0 +1,49 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+)
+
+// To8Bit returns img with its 16-bit samples rounded to 8 bits. Gray16 images become
+// Gray, Alpha16 images Alpha and the others NRGBA. Images with 8-bit samples are returned as is.
+func To8Bit(img image.Image) image.Image {
+	if !HighBitDepth(img) {
+		return img
+	}
+
+	b := img.Bounds()
+	switch m := img.(type) {
+	case *image.Gray16:
+		dst := image.NewGray(b)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				dst.SetGray(x, y, color.Gray{round8(m.Gray16At(x, y).Y)})
+			}
+		}
+		return dst
+	case *image.Alpha16:
+		dst := image.NewAlpha(b)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				dst.SetAlpha(x, y, color.Alpha{round8(m.Alpha16At(x, y).A)})
+			}
+		}
+		return dst
+	}
+
+	// アルファを掛けない値で丸めて、半透明の色が暗くならないようにする。
+	dst := image.NewNRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+			dst.SetNRGBA(x, y, color.NRGBA{round8(c.R), round8(c.G), round8(c.B), round8(c.A)})
+		}
+	}
+	return dst
+}
+
+// round8 rounds a 16-bit sample to the nearest 8-bit one.
+func round8(v uint16) uint8 {
+	return uint8((uint32(v)*0xff + 0x7fff) / 0xffff)
+}
@@ -0,0 +1,45 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestTo8Bit(t *testing.T) {
+	rect := image.Rect(0, 0, 2, 1)
+	gray := image.NewGray16(rect)
+	gray.SetGray16(0, 0, color.Gray16{0x1280})
+	gray.SetGray16(1, 0, color.Gray16{0xffff})
+	rgba := image.NewRGBA64(rect)
+	// 0x01ff は切り捨てなら 1 だが、四捨五入で 2 になる。
+	rgba.SetRGBA64(0, 0, color.RGBA64{0x8080, 0x01ff, 0, 0xffff})
+	// 半透明の色はアルファを掛けない値で丸める。
+	rgba.SetRGBA64(1, 0, color.RGBA64{0x8000, 0, 0, 0x8000})
+	nrgba := image.NewNRGBA(rect)
+
+	tests := []struct {
+		name string
+		img  image.Image
+		want []color.Color
+	}{
+		{"gray", gray, []color.Color{color.Gray{0x12}, color.Gray{0xff}}},
+		{"rgba", rgba, []color.Color{color.NRGBA{0x80, 0x02, 0, 0xff}, color.NRGBA{0xff, 0, 0, 0x80}}},
+	}
+
+	for _, tt := range tests {
+		got := To8Bit(tt.img)
+		if HighBitDepth(got) {
+			t.Fatalf("%s: still has 16-bit samples", tt.name)
+		}
+		for x, want := range tt.want {
+			if c := got.At(x, 0); c != want {
+				t.Errorf("%s: pixel %d = %v, want %v", tt.name, x, c, want)
+			}
+		}
+	}
+
+	if got := To8Bit(nrgba); got != image.Image(nrgba) {
+		t.Error("8-bit image is copied")
+	}
+}
@@ -0,0 +1,82 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+)
+
+// PixelDiff is the difference between two images of the same size.
+type PixelDiff struct {
+	// Changed is the number of pixels which have a channel differing by more than the tolerance.
+	Changed int
+	// Max is the largest difference of the 8-bit channels, including those within the tolerance.
+	Max int
+	// Bounds is the smallest rectangle containing the changed pixels, relative to the top-left corner.
+	Bounds image.Rectangle
+}
+
+// ComparePixels compares the 8-bit alpha-premultiplied channels of a and b, whose bounds
+// may have different origins. Differences up to tolerance are not counted as changes.
+func ComparePixels(a, b image.Image, tolerance int) (PixelDiff, error) {
+	var d PixelDiff
+	err := eachDelta(a, b, func(x, y, delta int) {
+		if delta > d.Max {
+			d.Max = delta
+		}
+		if delta > tolerance {
+			d.Changed++
+			d.Bounds = d.Bounds.Union(image.Rect(x, y, x+1, y+1))
+		}
+	})
+
+	return d, err
+}
+
+// DiffImage returns b faded to a light gray with the pixels which differ from a by more than
+// tolerance in red, brighter for larger differences.
+func DiffImage(a, b image.Image, tolerance int) (*image.NRGBA, error) {
+	bb := b.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bb.Dx(), bb.Dy()))
+	err := eachDelta(a, b, func(x, y, delta int) {
+		if delta > tolerance {
+			dst.SetNRGBA(x, y, color.NRGBA{uint8(128 + delta/2), 0, 0, 255})
+			return
+		}
+		g := color.GrayModel.Convert(b.At(bb.Min.X+x, bb.Min.Y+y)).(color.Gray)
+		v := 192 + g.Y/4
+		dst.SetNRGBA(x, y, color.NRGBA{v, v, v, 255})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dst, nil
+}
+
+// eachDelta calls f with the largest difference of the 8-bit channels at each pixel.
+func eachDelta(a, b image.Image, f func(x, y, delta int)) error {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Size() != bb.Size() {
+		return ErrSizeMismatch
+	}
+
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			r1, g1, b1, a1 := a.At(ab.Min.X+x, ab.Min.Y+y).RGBA()
+			r2, g2, b2, a2 := b.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+			delta := 0
+			for _, p := range [4][2]uint32{{r1, r2}, {g1, g2}, {b1, b2}, {a1, a2}} {
+				v := int(p[0]>>8) - int(p[1]>>8)
+				if v < 0 {
+					v = -v
+				}
+				if v > delta {
+					delta = v
+				}
+			}
+			f(x, y, delta)
+		}
+	}
+
+	return nil
+}
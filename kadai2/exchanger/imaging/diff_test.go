@@ -0,0 +1,66 @@
+package imaging
+
+import (
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestComparePixels(t *testing.T) {
+	src := helper.Gradient(16, 12)
+	changed := helper.Gradient(16, 12)
+	changed.SetNRGBA(3, 2, color.NRGBA{0, 0, 0, 255})
+	changed.SetNRGBA(10, 8, color.NRGBA{255, 255, 255, 255})
+	slight := helper.Gradient(16, 12)
+	for i := 0; i < len(slight.Pix); i += 4 {
+		slight.Pix[i] += 2
+	}
+	shifted := image.NewNRGBA(image.Rect(4, 4, 20, 16))
+	copy(shifted.Pix, changed.Pix)
+
+	tests := []struct {
+		name      string
+		b         image.Image
+		tolerance int
+		want      PixelDiff
+		wantError bool
+	}{
+		{"same", src, 0, PixelDiff{}, false},
+		{"changed", changed, 0, PixelDiff{Changed: 2, Max: 128, Bounds: image.Rect(3, 2, 11, 9)}, false},
+		{"different origin", shifted, 0, PixelDiff{Changed: 2, Max: 128, Bounds: image.Rect(3, 2, 11, 9)}, false},
+		{"within tolerance", slight, 2, PixelDiff{Max: 2}, false},
+		{"over tolerance", slight, 1, PixelDiff{Changed: 16 * 12, Max: 2, Bounds: image.Rect(0, 0, 16, 12)}, false},
+		{"different size", helper.Gradient(8, 8), 0, PixelDiff{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ComparePixels(src, tt.b, tt.tolerance)
+		if (err != nil) != tt.wantError {
+			t.Fatalf("%s: error = %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: ComparePixels = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiffImage(t *testing.T) {
+	a := helper.Gradient(16, 12)
+	b := helper.Gradient(16, 12)
+	b.SetNRGBA(5, 5, color.NRGBA{0, 0, 0, 255})
+
+	img, err := DiffImage(a, b, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := img.NRGBAAt(5, 5); c.R < 128 || c.G != 0 || c.B != 0 {
+		t.Errorf("changed pixel = %v, want red", c)
+	}
+	if c := img.NRGBAAt(0, 0); c.R != c.G || c.G != c.B || c.R < 192 {
+		t.Errorf("unchanged pixel = %v, want light gray", c)
+	}
+	if _, err := DiffImage(a, helper.Gradient(8, 8), 0); err != ErrSizeMismatch {
+		t.Errorf("error = %v, want %v", err, ErrSizeMismatch)
+	}
+}
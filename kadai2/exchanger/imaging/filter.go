@@ -0,0 +1,238 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// Filter adjusts pix, the alpha-premultiplied 16-bit samples of a w x h image in
+// row-major RGBA order, and returns the result, which may be pix itself.
+type Filter func(pix []float64, w, h int) []float64
+
+// ApplyFilters returns img with filters applied in order. Images with 16-bit samples are
+// returned as *image.RGBA64 and the others as *image.RGBA, as Resize does.
+func ApplyFilters(img image.Image, filters ...Filter) image.Image {
+	if len(filters) == 0 {
+		return img
+	}
+
+	b := img.Bounds()
+	pix := premultiplied(img)
+	for _, f := range filters {
+		pix = f(pix, b.Dx(), b.Dy())
+	}
+
+	return fromPremultiplied(pix, b.Dx(), b.Dy(), HighBitDepth(img))
+}
+
+// rows calls f with ranges of the rows from 0 to h, concurrently on GOMAXPROCS goroutines.
+func rows(h int, f func(y0, y1 int)) {
+	n := runtime.GOMAXPROCS(0)
+	if n > h {
+		n = h
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		y0, y1 := h*i/n, h*(i+1)/n
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f(y0, y1)
+		}()
+	}
+	wg.Wait()
+}
+
+// pointFilter returns a filter which maps the RGB of each pixel, without the alpha
+// premultiplied and scaled to 0 to 1, by f. The results are clamped to 0 to 1.
+func pointFilter(f func(c *[3]float64)) Filter {
+	return func(pix []float64, w, h int) []float64 {
+		rows(h, func(y0, y1 int) {
+			for i := y0 * w * 4; i < y1*w*4; i += 4 {
+				p := pix[i : i+4]
+				if p[3] == 0 {
+					continue
+				}
+				c := [3]float64{p[0] / p[3], p[1] / p[3], p[2] / p[3]}
+				f(&c)
+				for j, v := range c {
+					p[j] = math.Max(0, math.Min(v, 1)) * p[3]
+				}
+			}
+		})
+		return pix
+	}
+}
+
+// matrixFilter returns a filter which multiplies the RGB of each pixel by m.
+func matrixFilter(m [3][3]float64) Filter {
+	return pointFilter(func(c *[3]float64) {
+		r, g, b := c[0], c[1], c[2]
+		for i := range c {
+			c[i] = m[i][0]*r + m[i][1]*g + m[i][2]*b
+		}
+	})
+}
+
+// Gamma returns a filter which raises each channel to the power of 1/gamma,
+// so gammas above 1 brighten the mid-tones and those below 1 darken them.
+func Gamma(gamma float64) Filter {
+	e := 1 / gamma
+	return pointFilter(func(c *[3]float64) {
+		for i, v := range c {
+			c[i] = math.Pow(v, e)
+		}
+	})
+}
+
+// Saturation returns a filter which scales the saturation by s as the CSS saturate()
+// function does. Zero makes the image gray and 1 keeps it.
+func Saturation(s float64) Filter {
+	return matrixFilter([3][3]float64{
+		{0.213 + 0.787*s, 0.715 - 0.715*s, 0.072 - 0.072*s},
+		{0.213 - 0.213*s, 0.715 + 0.285*s, 0.072 - 0.072*s},
+		{0.213 - 0.213*s, 0.715 - 0.715*s, 0.072 + 0.928*s},
+	})
+}
+
+// HueShift returns a filter which rotates the hue by degrees as the CSS hue-rotate() function does.
+func HueShift(degrees float64) Filter {
+	rad := degrees * math.Pi / 180
+	cos, sin := math.Cos(rad), math.Sin(rad)
+	return matrixFilter([3][3]float64{
+		{0.213 + cos*0.787 - sin*0.213, 0.715 - cos*0.715 - sin*0.715, 0.072 - cos*0.072 + sin*0.928},
+		{0.213 - cos*0.213 + sin*0.143, 0.715 + cos*0.285 + sin*0.140, 0.072 - cos*0.072 - sin*0.283},
+		{0.213 - cos*0.213 - sin*0.787, 0.715 - cos*0.715 + sin*0.715, 0.072 + cos*0.928 + sin*0.072},
+	})
+}
+
+// Sepia returns a filter which tones the image in sepia by amount from 0 to 1,
+// as the CSS sepia() function does.
+func Sepia(amount float64) Filter {
+	k := 1 - math.Max(0, math.Min(amount, 1))
+	return matrixFilter([3][3]float64{
+		{0.393 + 0.607*k, 0.769 - 0.769*k, 0.189 - 0.189*k},
+		{0.349 - 0.349*k, 0.686 + 0.314*k, 0.168 - 0.168*k},
+		{0.272 - 0.272*k, 0.534 - 0.534*k, 0.131 + 0.869*k},
+	})
+}
+
+// Invert returns a filter which inverts the RGB of each pixel.
+func Invert() Filter {
+	return pointFilter(func(c *[3]float64) {
+		for i, v := range c {
+			c[i] = 1 - v
+		}
+	})
+}
+
+// Threshold returns a filter which makes the pixels white if their luma, weighted as
+// color.GrayModel does, is at least level from 0 to 1, and black otherwise.
+func Threshold(level float64) Filter {
+	return pointFilter(func(c *[3]float64) {
+		v := 0.0
+		if 0.299*c[0]+0.587*c[1]+0.114*c[2] >= level {
+			v = 1
+		}
+		*c = [3]float64{v, v, v}
+	})
+}
+
+// GaussianBlur returns a filter which blurs the image with a Gaussian of the standard
+// deviation sigma in pixels. The edges are extended.
+func GaussianBlur(sigma float64) Filter {
+	k := gaussian(sigma)
+	return func(pix []float64, w, h int) []float64 {
+		return convolve(pix, w, h, k)
+	}
+}
+
+// UnsharpMask returns a filter which sharpens the image by adding the difference from
+// the image blurred by sigma, multiplied by amount, to the channels which differ by more
+// than threshold from 0 to 1.
+func UnsharpMask(sigma, amount, threshold float64) Filter {
+	k := gaussian(sigma)
+	return func(pix []float64, w, h int) []float64 {
+		blurred := convolve(pix, w, h, k)
+		t := threshold * 0xffff
+		rows(h, func(y0, y1 int) {
+			for i := y0 * w * 4; i < y1*w*4; i += 4 {
+				p, q := pix[i:i+4], blurred[i:i+4]
+				for j := 0; j < 3; j++ {
+					if d := p[j] - q[j]; math.Abs(d) > t {
+						p[j] = math.Max(0, math.Min(p[j]+amount*d, p[3]))
+					}
+				}
+			}
+		})
+		return pix
+	}
+}
+
+// gaussian returns the normalized weights of a Gaussian from -3 sigma to 3 sigma.
+func gaussian(sigma float64) []float64 {
+	r := int(math.Ceil(3 * sigma))
+	k := make([]float64, 2*r+1)
+	var total float64
+	for i := range k {
+		x := float64(i - r)
+		k[i] = math.Exp(-x * x / (2 * sigma * sigma))
+		total += k[i]
+	}
+	for i := range k {
+		k[i] /= total
+	}
+
+	return k
+}
+
+// convolve returns pix convolved with the symmetric kernel k horizontally and then vertically.
+func convolve(pix []float64, w, h int, k []float64) []float64 {
+	r := len(k) / 2
+	clamp := func(v, n int) int {
+		if v < 0 {
+			return 0
+		}
+		if v >= n {
+			return n - 1
+		}
+		return v
+	}
+
+	tmp := make([]float64, len(pix))
+	rows(h, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < w; x++ {
+				var sum [4]float64
+				for i, wt := range k {
+					p := pix[(y*w+clamp(x+i-r, w))*4:]
+					for c := 0; c < 4; c++ {
+						sum[c] += p[c] * wt
+					}
+				}
+				copy(tmp[(y*w+x)*4:], sum[:])
+			}
+		}
+	})
+
+	out := make([]float64, len(pix))
+	rows(h, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < w; x++ {
+				var sum [4]float64
+				for i, wt := range k {
+					p := tmp[(clamp(y+i-r, h)*w+x)*4:]
+					for c := 0; c < 4; c++ {
+						sum[c] += p[c] * wt
+					}
+				}
+				copy(out[(y*w+x)*4:], sum[:])
+			}
+		}
+	})
+
+	return out
+}
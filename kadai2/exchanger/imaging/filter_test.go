@@ -0,0 +1,107 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPointFilters(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	tests := []struct {
+		name      string
+		filters   []Filter
+		in        color.NRGBA
+		want      color.NRGBA
+		tolerance int
+	}{
+		{"invert", []Filter{Invert()}, red, color.NRGBA{0, 255, 255, 255}, 0},
+		{"invert keeps alpha", []Filter{Invert()}, color.NRGBA{255, 0, 0, 128}, color.NRGBA{0, 255, 255, 128}, 1},
+		{"gamma", []Filter{Gamma(2.2)}, color.NRGBA{64, 64, 64, 255}, color.NRGBA{136, 136, 136, 255}, 1},
+		{"gamma 1", []Filter{Gamma(1)}, color.NRGBA{10, 20, 30, 255}, color.NRGBA{10, 20, 30, 255}, 0},
+		{"desaturate", []Filter{Saturation(0)}, red, color.NRGBA{54, 54, 54, 255}, 1},
+		{"saturation 1", []Filter{Saturation(1)}, color.NRGBA{10, 200, 30, 255}, color.NRGBA{10, 200, 30, 255}, 1},
+		{"hue 0", []Filter{HueShift(0)}, color.NRGBA{10, 200, 30, 255}, color.NRGBA{10, 200, 30, 255}, 1},
+		{"hue 360", []Filter{HueShift(360)}, color.NRGBA{10, 200, 30, 255}, color.NRGBA{10, 200, 30, 255}, 1},
+		{"sepia", []Filter{Sepia(1)}, color.NRGBA{100, 100, 100, 255}, color.NRGBA{135, 120, 94, 255}, 1},
+		{"sepia 0", []Filter{Sepia(0)}, red, red, 0},
+		{"threshold dark", []Filter{Threshold(0.5)}, color.NRGBA{100, 100, 100, 255}, color.NRGBA{0, 0, 0, 255}, 0},
+		{"threshold light", []Filter{Threshold(0.5)}, color.NRGBA{200, 200, 200, 255}, color.NRGBA{255, 255, 255, 255}, 0},
+		{"in order", []Filter{Saturation(0), Invert(), Threshold(0.5)}, red, color.NRGBA{255, 255, 255, 255}, 0},
+		{"transparent", []Filter{Invert(), Sepia(1)}, color.NRGBA{}, color.NRGBA{}, 0},
+	}
+
+	for _, tt := range tests {
+		img := image.NewNRGBA(image.Rect(0, 0, 3, 5))
+		for i := 0; i < len(img.Pix); i += 4 {
+			copy(img.Pix[i:], []uint8{tt.in.R, tt.in.G, tt.in.B, tt.in.A})
+		}
+		got := color.NRGBAModel.Convert(ApplyFilters(img, tt.filters...).At(2, 4)).(color.NRGBA)
+		for i, p := range [4][2]uint8{{got.R, tt.want.R}, {got.G, tt.want.G}, {got.B, tt.want.B}, {got.A, tt.want.A}} {
+			if d := int(p[0]) - int(p[1]); d > tt.tolerance || -d > tt.tolerance {
+				t.Errorf("%s: channel %d of %v, want %v", tt.name, i, got, tt.want)
+				break
+			}
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	img.SetNRGBA(0, 0, red)
+	// 赤の色相を 120 度回すと緑になる。
+	if got := color.NRGBAModel.Convert(ApplyFilters(img, HueShift(120)).At(0, 0)).(color.NRGBA); got.G <= got.R || got.G <= got.B {
+		t.Errorf("hue 120 of red = %v, want green", got)
+	}
+}
+
+func TestBlurAndSharpen(t *testing.T) {
+	// 左半分が黒、右半分が白の画像
+	edge := image.NewGray(image.Rect(0, 0, 16, 4))
+	for y := 0; y < 4; y++ {
+		for x := 8; x < 16; x++ {
+			edge.SetGray(x, y, color.Gray{200})
+		}
+	}
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 8; x++ {
+			edge.SetGray(x, y, color.Gray{50})
+		}
+	}
+	gray := func(img image.Image, x int) uint8 {
+		return color.GrayModel.Convert(img.At(x, 2)).(color.Gray).Y
+	}
+
+	blurred := ApplyFilters(edge, GaussianBlur(1.5))
+	if v := gray(blurred, 7); v <= 50 || v >= 125 {
+		t.Errorf("blurred edge = %d, want between 50 and 125", v)
+	}
+	if v := gray(blurred, 0); v != 50 {
+		t.Errorf("blurred far from the edge = %d, want 50", v)
+	}
+
+	sharpened := ApplyFilters(edge, UnsharpMask(1, 1, 0))
+	if v := gray(sharpened, 7); v >= 50 {
+		t.Errorf("sharpened dark side = %d, want below 50", v)
+	}
+	if v := gray(sharpened, 8); v <= 200 {
+		t.Errorf("sharpened light side = %d, want above 200", v)
+	}
+	if v := gray(ApplyFilters(edge, UnsharpMask(1, 1, 0.5)), 8); v != 200 {
+		t.Errorf("sharpened within the threshold = %d, want 200", v)
+	}
+
+	// 一様な画像はぼかしても変わらず、16 ビットのまま返す。
+	deep := image.NewNRGBA64(image.Rect(0, 0, 5, 5))
+	for i := range deep.Pix {
+		deep.Pix[i] = 0x80
+		if i%8 >= 6 {
+			deep.Pix[i] = 0xff
+		}
+	}
+	got := ApplyFilters(deep, GaussianBlur(2), UnsharpMask(2, 1, 0))
+	if _, ok := got.(*image.RGBA64); !ok {
+		t.Fatalf("16-bit image is returned as %T", got)
+	}
+	if ok, _ := Identical(deep, got); !ok {
+		t.Errorf("uniform image is changed to %v", got.At(2, 2))
+	}
+}
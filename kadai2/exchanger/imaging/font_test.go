@@ -0,0 +1,41 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDrawText(t *testing.T) {
+	tests := []struct {
+		text  string
+		scale int
+		want  image.Point
+	}{
+		{"", 1, image.Pt(0, 0)},
+		{"A", 1, image.Pt(5, 8)},
+		{"AB", 2, image.Pt(22, 16)},
+		{"abc\nd", 1, image.Pt(17, 17)},
+		// ASCII 以外は '?' で描く。
+		{"日本", 1, image.Pt(11, 8)},
+	}
+
+	for _, tt := range tests {
+		size := TextSize(tt.text, tt.scale)
+		if size != tt.want {
+			t.Errorf("TextSize(%q, %d) = %v, want %v", tt.text, tt.scale, size, tt.want)
+		}
+
+		img := image.NewGray(image.Rectangle{Max: size})
+		DrawText(img, image.Point{}, tt.text, tt.scale, color.White)
+		inked := 0
+		for _, v := range img.Pix {
+			if v != 0 {
+				inked++
+			}
+		}
+		if (inked > 0) != (tt.text != "") {
+			t.Errorf("DrawText(%q) drew %d pixels", tt.text, inked)
+		}
+	}
+}
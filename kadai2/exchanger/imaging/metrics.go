@@ -0,0 +1,164 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+)
+
+// ErrSizeMismatch is returned when images of different sizes are compared.
+var ErrSizeMismatch = errors.New("imaging: images have different sizes")
+
+// Identical reports whether a and b have exactly the same pixels at the precision of a:
+// 16 bits per channel if a has 16-bit samples, 8 bits otherwise. The bounds may have different origins.
+// The colors are compared without the alpha premultiplied, as the formats with alpha store them,
+// and those of fully transparent pixels are ignored.
+func Identical(a, b image.Image) (bool, error) {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Size() != bb.Size() {
+		return false, ErrSizeMismatch
+	}
+
+	model := color.NRGBAModel
+	if HighBitDepth(a) {
+		model = color.NRGBA64Model
+	}
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			c1 := model.Convert(a.At(ab.Min.X+x, ab.Min.Y+y))
+			c2 := model.Convert(b.At(bb.Min.X+x, bb.Min.Y+y))
+			if c1 == c2 {
+				continue
+			}
+			if _, _, _, a1 := c1.RGBA(); a1 != 0 {
+				return false, nil
+			}
+			if _, _, _, a2 := c2.RGBA(); a2 != 0 {
+				return false, nil
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// HighBitDepth reports whether img has 16-bit samples.
+func HighBitDepth(img image.Image) bool {
+	switch img.ColorModel() {
+	case color.RGBA64Model, color.NRGBA64Model, color.Gray16Model, color.Alpha16Model:
+		return true
+	}
+	return false
+}
+
+// PSNR returns the peak signal-to-noise ratio in decibels between a and b,
+// computed over the 8-bit alpha-premultiplied RGB channels.
+// It returns +Inf if the channels are equal.
+func PSNR(a, b image.Image) (float64, error) {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Size() != bb.Size() {
+		return 0, ErrSizeMismatch
+	}
+	if ab.Empty() {
+		return math.Inf(1), nil
+	}
+
+	var sum float64
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			r1, g1, b1, _ := a.At(ab.Min.X+x, ab.Min.Y+y).RGBA()
+			r2, g2, b2, _ := b.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+			for _, d := range [3]float64{
+				float64(r1>>8) - float64(r2>>8),
+				float64(g1>>8) - float64(g2>>8),
+				float64(b1>>8) - float64(b2>>8),
+			} {
+				sum += d * d
+			}
+		}
+	}
+
+	mse := sum / float64(3*ab.Dx()*ab.Dy())
+	if mse == 0 {
+		return math.Inf(1), nil
+	}
+	return 10 * math.Log10(255*255/mse), nil
+}
+
+const (
+	ssimWindow = 8
+	ssimStep   = 4
+	ssimC1     = (0.01 * 255) * (0.01 * 255)
+	ssimC2     = (0.03 * 255) * (0.03 * 255)
+)
+
+// SSIM returns the mean structural similarity index between the luminance of a and b.
+// It is computed over 8x8 windows moved by 4 pixels, and 1 means the luminance is equal.
+func SSIM(a, b image.Image) (float64, error) {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Size() != bb.Size() {
+		return 0, ErrSizeMismatch
+	}
+	if ab.Empty() {
+		return 1, nil
+	}
+
+	la, lb := luminance(a), luminance(b)
+	w, h := ab.Dx(), ab.Dy()
+	ww, wh := ssimWindow, ssimWindow
+	if w < ww {
+		ww = w
+	}
+	if h < wh {
+		wh = h
+	}
+
+	var total float64
+	var n int
+	for y := 0; y+wh <= h; y += ssimStep {
+		for x := 0; x+ww <= w; x += ssimStep {
+			total += ssimWindowAt(la, lb, w, x, y, ww, wh)
+			n++
+		}
+	}
+
+	return total / float64(n), nil
+}
+
+func ssimWindowAt(la, lb []float64, stride, x0, y0, ww, wh int) float64 {
+	var sa, sb, saa, sbb, sab float64
+	for y := y0; y < y0+wh; y++ {
+		for x := x0; x < x0+ww; x++ {
+			va, vb := la[y*stride+x], lb[y*stride+x]
+			sa += va
+			sb += vb
+			saa += va * va
+			sbb += vb * vb
+			sab += va * vb
+		}
+	}
+
+	n := float64(ww * wh)
+	ma, mb := sa/n, sb/n
+	va := saa/n - ma*ma
+	vb := sbb/n - mb*mb
+	cov := sab/n - ma*mb
+
+	return ((2*ma*mb + ssimC1) * (2*cov + ssimC2)) /
+		((ma*ma + mb*mb + ssimC1) * (va + vb + ssimC2))
+}
+
+// luminance returns the 8-bit luma of img in row-major order.
+func luminance(img image.Image) []float64 {
+	b := img.Bounds()
+	l := make([]float64, 0, b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			l = append(l, (0.299*float64(r)+0.587*float64(g)+0.114*float64(bl))/257)
+		}
+	}
+
+	return l
+}
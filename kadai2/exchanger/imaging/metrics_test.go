@@ -0,0 +1,109 @@
+package imaging
+
+import (
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	src := helper.Gradient(32, 24)
+	noisy := helper.Gradient(32, 24)
+	for i := 0; i < len(noisy.Pix); i += 4 * 7 {
+		noisy.Pix[i] ^= 0x10
+	}
+	shifted := image.NewNRGBA(image.Rect(5, 5, 37, 29))
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 32; x++ {
+			shifted.Set(x+5, y+5, src.At(x, y))
+		}
+	}
+
+	// アルファを掛けずに保存された半透明の画像と、それを RGBA にしたもの
+	straight := helper.Gradient(32, 24)
+	for i := 3; i < len(straight.Pix); i += 4 {
+		straight.Pix[i] = 128
+	}
+	premultiplied := image.NewRGBA(straight.Bounds())
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 32; x++ {
+			premultiplied.Set(x, y, straight.At(x, y))
+		}
+	}
+	back := image.NewNRGBA(straight.Bounds())
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 32; x++ {
+			back.Set(x, y, premultiplied.At(x, y))
+		}
+	}
+
+	tests := []struct {
+		name      string
+		a, b      image.Image
+		identical bool
+		minPSNR   float64
+		minSSIM   float64
+		wantError bool
+	}{
+		{"same", src, src, true, math.Inf(1), 1, false},
+		{"different origin", src, shifted, true, math.Inf(1), 1, false},
+		{"noisy", src, noisy, false, 30, 0.8, false},
+		{"straight alpha", premultiplied, back, true, 40, 0.99, false},
+		{"different size", src, helper.Gradient(10, 10), false, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		identical, err := Identical(tt.a, tt.b)
+		if (err != nil) != tt.wantError {
+			t.Fatalf("%s: Identical error = %v", tt.name, err)
+		}
+		if err != nil {
+			continue
+		}
+		if identical != tt.identical {
+			t.Errorf("%s: Identical = %v, want %v", tt.name, identical, tt.identical)
+		}
+
+		psnr, _ := PSNR(tt.a, tt.b)
+		if psnr < tt.minPSNR || (!tt.identical && math.IsInf(psnr, 1)) {
+			t.Errorf("%s: PSNR = %v, want >= %v", tt.name, psnr, tt.minPSNR)
+		}
+		ssim, _ := SSIM(tt.a, tt.b)
+		if ssim < tt.minSSIM || ssim > 1+1e-9 || (!tt.identical && ssim == 1) {
+			t.Errorf("%s: SSIM = %v, want >= %v", tt.name, ssim, tt.minSSIM)
+		}
+	}
+}
+
+func TestSSIMDetectsStructure(t *testing.T) {
+	// 平均が同じでも模様が違えば SSIM は下がる。
+	a := image.NewGray(image.Rect(0, 0, 16, 16))
+	b := image.NewGray(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			a.SetGray(x, y, color.Gray{uint8((x + y) % 2 * 255)})
+			b.SetGray(x, y, color.Gray{uint8(x % 2 * 255)})
+		}
+	}
+
+	if s, _ := SSIM(a, b); s > 0.5 {
+		t.Errorf("SSIM = %v, want <= 0.5", s)
+	}
+}
+
+func TestIdenticalPrecision(t *testing.T) {
+	a := image.NewGray16(image.Rect(0, 0, 1, 1))
+	a.SetGray16(0, 0, color.Gray16{0x1234})
+	b := image.NewGray(image.Rect(0, 0, 1, 1))
+	b.SetGray(0, 0, color.Gray{0x12})
+
+	// 16 ビットの画像を基準にすると、8 ビットに落ちた差分も検出する。
+	if ok, _ := Identical(a, b); ok {
+		t.Error("Identical(gray16, gray) = true, want false")
+	}
+	if ok, _ := Identical(b, a); !ok {
+		t.Error("Identical(gray, gray16) = false, want true")
+	}
+}
@@ -0,0 +1,103 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Anchor is the position of an overlay relative to the image.
+type Anchor int
+
+// The anchors.
+const (
+	TopLeft Anchor = iota
+	Top
+	TopRight
+	Left
+	Center
+	Right
+	BottomLeft
+	Bottom
+	BottomRight
+)
+
+var anchorNames = map[string]Anchor{
+	"top-left":     TopLeft,
+	"top":          Top,
+	"top-right":    TopRight,
+	"left":         Left,
+	"center":       Center,
+	"right":        Right,
+	"bottom-left":  BottomLeft,
+	"bottom":       Bottom,
+	"bottom-right": BottomRight,
+}
+
+// ParseAnchor returns the anchor named s, such as "top-left" or "center".
+func ParseAnchor(s string) (Anchor, error) {
+	a, ok := anchorNames[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown anchor %q", s)
+	}
+	return a, nil
+}
+
+// Place returns the rectangle of the given size at anchor within r, margin pixels away from the edges.
+func Place(r image.Rectangle, size image.Point, anchor Anchor, margin int) image.Rectangle {
+	var x, y int
+	switch anchor % 3 {
+	case 0:
+		x = r.Min.X + margin
+	case 1:
+		x = r.Min.X + (r.Dx()-size.X)/2
+	default:
+		x = r.Max.X - margin - size.X
+	}
+	switch anchor / 3 {
+	case 0:
+		y = r.Min.Y + margin
+	case 1:
+		y = r.Min.Y + (r.Dy()-size.Y)/2
+	default:
+		y = r.Max.Y - margin - size.Y
+	}
+
+	return image.Rectangle{Min: image.Pt(x, y), Max: image.Pt(x+size.X, y+size.Y)}
+}
+
+// Clone returns a copy of img which can be drawn on, keeping 16-bit samples if img has them.
+func Clone(img image.Image) draw.Image {
+	b := img.Bounds()
+	var dst draw.Image
+	if HighBitDepth(img) {
+		dst = image.NewRGBA64(b)
+	} else {
+		dst = image.NewRGBA(b)
+	}
+	draw.Draw(dst, b, img, b.Min, draw.Src)
+
+	return dst
+}
+
+// Overlay returns a copy of img with mark composited at anchor, margin pixels away
+// from the edges. The alpha of mark is multiplied by opacity, from 0 to 1.
+func Overlay(img, mark image.Image, anchor Anchor, margin int, opacity float64) image.Image {
+	dst := Clone(img)
+	r := Place(img.Bounds(), mark.Bounds().Size(), anchor, margin)
+	mask := image.NewUniform(color.Alpha16{uint16(clamp01(opacity) * 0xffff)})
+	draw.DrawMask(dst, r, mark, mark.Bounds().Min, mask, image.Point{}, draw.Over)
+
+	return dst
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,65 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPlace(t *testing.T) {
+	r := image.Rect(0, 0, 100, 50)
+	size := image.Pt(10, 4)
+	tests := []struct {
+		anchor string
+		want   image.Point
+	}{
+		{"top-left", image.Pt(2, 2)},
+		{"top", image.Pt(45, 2)},
+		{"center", image.Pt(45, 23)},
+		{"right", image.Pt(88, 23)},
+		{"bottom-right", image.Pt(88, 44)},
+		{"bottom-left", image.Pt(2, 44)},
+	}
+
+	for _, tt := range tests {
+		a, err := ParseAnchor(tt.anchor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Place(r, size, a, 2); got.Min != tt.want || got.Size() != size {
+			t.Errorf("Place(%s) = %v, want at %v", tt.anchor, got, tt.want)
+		}
+	}
+	if _, err := ParseAnchor("middle"); err == nil {
+		t.Error("ParseAnchor(middle) got nothing happened, want an error")
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	mark := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for i := range mark.Pix {
+		mark.Pix[i] = 0xff
+	}
+
+	got := Overlay(img, mark, BottomRight, 1, 0.5)
+	tests := []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{0, 0, color.RGBA{}},
+		{5, 5, color.RGBA{0x7f, 0x7f, 0x7f, 0x7f}},
+		{6, 6, color.RGBA{0x7f, 0x7f, 0x7f, 0x7f}},
+		{4, 4, color.RGBA{}},
+		{7, 7, color.RGBA{}},
+	}
+	for _, tt := range tests {
+		if c := color.RGBAModel.Convert(got.At(tt.x, tt.y)); c != tt.want {
+			t.Errorf("pixel (%d, %d) = %v, want %v", tt.x, tt.y, c, tt.want)
+		}
+	}
+	// 元の画像は変更しない。
+	if img.Pix[6*img.Stride+6*4] != 0 {
+		t.Error("Overlay modified the source image")
+	}
+}
@@ -0,0 +1,75 @@
+package imaging
+
+import (
+	"image"
+	"math/bits"
+)
+
+// AHash returns the average hash of img: each bit of the 8x8 grayscale
+// thumbnail is set if the pixel is brighter than the mean.
+func AHash(img image.Image) uint64 {
+	g := grayThumbnail(img, 8, 8)
+
+	var mean float64
+	for _, v := range g {
+		mean += v
+	}
+	mean /= float64(len(g))
+
+	var h uint64
+	for i, v := range g {
+		if v > mean {
+			h |= 1 << uint(63-i)
+		}
+	}
+
+	return h
+}
+
+// DHash returns the difference hash of img: each bit of the 9x8 grayscale
+// thumbnail is set if the pixel is brighter than its right neighbor.
+func DHash(img image.Image) uint64 {
+	g := grayThumbnail(img, 9, 8)
+
+	var h uint64
+	i := 0
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if g[y*9+x] > g[y*9+x+1] {
+				h |= 1 << uint(63-i)
+			}
+			i++
+		}
+	}
+
+	return h
+}
+
+// Hamming returns the number of bits which differ between a and b.
+func Hamming(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// grayThumbnail returns the luminance of img reduced to w x h by averaging the covered pixels.
+func grayThumbnail(img image.Image, w, h int) []float64 {
+	b := img.Bounds()
+	sum := make([]float64, w*h)
+	cnt := make([]float64, w*h)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		ty := (y - b.Min.Y) * h / b.Dy()
+		for x := b.Min.X; x < b.Max.X; x++ {
+			tx := (x - b.Min.X) * w / b.Dx()
+			r, g, bl, _ := img.At(x, y).RGBA()
+			sum[ty*w+tx] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+			cnt[ty*w+tx]++
+		}
+	}
+
+	for i := range sum {
+		if cnt[i] > 0 {
+			sum[i] /= cnt[i]
+		}
+	}
+
+	return sum
+}
@@ -0,0 +1,56 @@
+package imaging
+
+import (
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPerceptualHash(t *testing.T) {
+	src := helper.Gradient(64, 48)
+	// 同じ絵を縮小して少しだけ明るくしたもの
+	similar := image.NewNRGBA(image.Rect(0, 0, 32, 24))
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 32; x++ {
+			c := src.NRGBAAt(x*2, y*2)
+			c.R, c.G = c.R/2+c.R/3, c.G/2+c.G/3
+			similar.SetNRGBA(x, y, c)
+		}
+	}
+	// 左右を反転したもの
+	flipped := image.NewNRGBA(src.Bounds())
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			flipped.Set(63-x, y, src.At(x, y))
+		}
+	}
+	checker := image.NewGray(src.Bounds())
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			checker.SetGray(x, y, color.Gray{uint8((x/8 + y/6) % 2 * 255)})
+		}
+	}
+
+	tests := []struct {
+		name    string
+		hash    func(image.Image) uint64
+		other   image.Image
+		maxDist int
+		minDist int
+	}{
+		{"ahash same", AHash, src, 0, 0},
+		{"ahash similar", AHash, similar, 6, 0},
+		{"ahash checker", AHash, checker, 64, 12},
+		{"dhash same", DHash, src, 0, 0},
+		{"dhash similar", DHash, similar, 6, 0},
+		{"dhash flipped", DHash, flipped, 64, 12},
+	}
+
+	for _, tt := range tests {
+		d := Hamming(tt.hash(src), tt.hash(tt.other))
+		if d > tt.maxDist || d < tt.minDist {
+			t.Errorf("%s: distance = %d, want between %d and %d", tt.name, d, tt.minDist, tt.maxDist)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Package imaging provides image operations used by the exchanger.
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+// Quantize returns a paletted copy of img with at most n colors chosen by median cut.
+// If dither is true, the colors are diffused with Floyd-Steinberg error diffusion.
+func Quantize(img image.Image, n int, dither bool) *image.Paletted {
+	b := img.Bounds()
+	dst := image.NewPaletted(b, MedianCut(img, n))
+	if dither {
+		draw.FloydSteinberg.Draw(dst, b, img, b.Min)
+	} else {
+		draw.Draw(dst, b, img, b.Min, draw.Src)
+	}
+
+	return dst
+}
+
+// MedianCut returns a palette of at most n colors which represents the colors in img.
+// If img has n colors or fewer, the palette contains exactly those colors.
+func MedianCut(img image.Image, n int) color.Palette {
+	if n < 1 {
+		n = 1
+	}
+	if n > 256 {
+		n = 256
+	}
+
+	hist := make(map[color.RGBA]int)
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			hist[c]++
+		}
+	}
+
+	entries := make([]histEntry, 0, len(hist))
+	for c, cnt := range hist {
+		entries = append(entries, histEntry{c: [4]uint8{c.R, c.G, c.B, c.A}, count: cnt})
+	}
+	// map の順序に依存しないように並べておく。
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key() < entries[j].key()
+	})
+
+	if len(entries) <= n {
+		p := make(color.Palette, len(entries))
+		for i, e := range entries {
+			p[i] = color.RGBA{e.c[0], e.c[1], e.c[2], e.c[3]}
+		}
+		return p
+	}
+
+	boxes := []colorBox{{entries: entries}}
+	for len(boxes) < n {
+		// 最も色の幅が広い箱を選んで分割する。
+		idx, ch, width := -1, 0, -1
+		for i, box := range boxes {
+			if len(box.entries) < 2 {
+				continue
+			}
+			if c, w := box.widest(); w > width {
+				idx, ch, width = i, c, w
+			}
+		}
+		if idx < 0 {
+			break
+		}
+
+		a, b := boxes[idx].split(ch)
+		boxes[idx] = a
+		boxes = append(boxes, b)
+	}
+
+	p := make(color.Palette, len(boxes))
+	for i, box := range boxes {
+		p[i] = box.average()
+	}
+
+	return p
+}
+
+type histEntry struct {
+	c     [4]uint8
+	count int
+}
+
+func (e histEntry) key() uint32 {
+	return uint32(e.c[0])<<24 | uint32(e.c[1])<<16 | uint32(e.c[2])<<8 | uint32(e.c[3])
+}
+
+type colorBox struct {
+	entries []histEntry
+}
+
+// widest returns the channel with the largest range and its width.
+func (b colorBox) widest() (int, int) {
+	ch, width := 0, -1
+	for c := 0; c < 4; c++ {
+		min, max := 255, 0
+		for _, e := range b.entries {
+			v := int(e.c[c])
+			if v < min {
+				min = v
+			}
+			if v > max {
+				max = v
+			}
+		}
+		if max-min > width {
+			ch, width = c, max-min
+		}
+	}
+
+	return ch, width
+}
+
+// split divides the box at the pixel-weighted median of channel ch.
+func (b colorBox) split(ch int) (colorBox, colorBox) {
+	sort.SliceStable(b.entries, func(i, j int) bool {
+		return b.entries[i].c[ch] < b.entries[j].c[ch]
+	})
+
+	total := 0
+	for _, e := range b.entries {
+		total += e.count
+	}
+
+	sum, mid := 0, 1
+	for i, e := range b.entries[:len(b.entries)-1] {
+		sum += e.count
+		mid = i + 1
+		if sum*2 >= total {
+			break
+		}
+	}
+
+	return colorBox{entries: b.entries[:mid]}, colorBox{entries: b.entries[mid:]}
+}
+
+func (b colorBox) average() color.Color {
+	var sum [4]int
+	total := 0
+	for _, e := range b.entries {
+		for c := 0; c < 4; c++ {
+			sum[c] += int(e.c[c]) * e.count
+		}
+		total += e.count
+	}
+
+	var avg [4]uint8
+	for c := 0; c < 4; c++ {
+		avg[c] = uint8((sum[c] + total/2) / total)
+	}
+
+	return color.RGBA{avg[0], avg[1], avg[2], avg[3]}
+}
@@ -0,0 +1,50 @@
+package imaging
+
+import (
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestQuantize(t *testing.T) {
+	tests := []struct {
+		colors int
+		dither bool
+	}{
+		{2, false},
+		{16, false},
+		{16, true},
+		{256, true},
+	}
+
+	src := helper.Gradient(64, 64)
+	for _, tt := range tests {
+		got := Quantize(src, tt.colors, tt.dither)
+		if len(got.Palette) > tt.colors {
+			t.Errorf("Quantize(%d, %v) has %d colors", tt.colors, tt.dither, len(got.Palette))
+		}
+		if got.Bounds() != src.Bounds() {
+			t.Errorf("Quantize(%d, %v) bounds = %v, want %v", tt.colors, tt.dither, got.Bounds(), src.Bounds())
+		}
+	}
+}
+
+func TestQuantizeKeepsFewColors(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	colors := []color.NRGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 0, 0}}
+	for i := range src.Pix[:len(src.Pix)/4] {
+		src.Set(i%8, i/8, colors[i%len(colors)])
+	}
+
+	// 色数が上限以下なら元の色がそのまま残るはず。
+	got := Quantize(src, 16, true)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			want := color.RGBAModel.Convert(src.At(x, y))
+			if c := color.RGBAModel.Convert(got.At(x, y)); c != want {
+				t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, c, want)
+			}
+		}
+	}
+}
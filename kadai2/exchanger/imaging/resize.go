@@ -0,0 +1,182 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Resize returns img scaled to w x h with a linear filter, which averages the
+// covered pixels when shrinking. Images with 16-bit samples are returned as
+// *image.RGBA64 and the others as *image.RGBA.
+func Resize(img image.Image, w, h int) image.Image {
+	b := img.Bounds()
+	if w <= 0 || h <= 0 || b.Empty() {
+		return image.NewRGBA(image.Rect(0, 0, 0, 0))
+	}
+
+	// 横方向、縦方向の順に、アルファを掛けた値のまま畳み込む。
+	src := premultiplied(img)
+	tmp := make([]float64, w*b.Dy()*4)
+	xw := weights(b.Dx(), w)
+	for y := 0; y < b.Dy(); y++ {
+		for x, ws := range xw {
+			var sum [4]float64
+			for _, c := range ws {
+				p := src[(y*b.Dx()+c.index)*4:]
+				for i := 0; i < 4; i++ {
+					sum[i] += p[i] * c.weight
+				}
+			}
+			copy(tmp[(y*w+x)*4:], sum[:])
+		}
+	}
+
+	out := make([]float64, w*h*4)
+	yw := weights(b.Dy(), h)
+	for y, ws := range yw {
+		for x := 0; x < w; x++ {
+			var sum [4]float64
+			for _, c := range ws {
+				p := tmp[(c.index*w+x)*4:]
+				for i := 0; i < 4; i++ {
+					sum[i] += p[i] * c.weight
+				}
+			}
+			copy(out[(y*w+x)*4:], sum[:])
+		}
+	}
+
+	return fromPremultiplied(out, w, h, HighBitDepth(img))
+}
+
+// Fit returns the largest size which has the aspect ratio of size and fits in maxW x maxH.
+// Smaller sizes are not enlarged.
+func Fit(size image.Point, maxW, maxH int) image.Point {
+	if size.X <= maxW && size.Y <= maxH {
+		return size
+	}
+
+	scale := math.Min(float64(maxW)/float64(size.X), float64(maxH)/float64(size.Y))
+	p := image.Pt(int(math.Round(float64(size.X)*scale)), int(math.Round(float64(size.Y)*scale)))
+	if p.X < 1 {
+		p.X = 1
+	}
+	if p.Y < 1 {
+		p.Y = 1
+	}
+
+	return p
+}
+
+// Contain returns img scaled to fit in w x h, enlarging it if needed, and centered
+// on a transparent canvas of w x h.
+func Contain(img image.Image, w, h int) image.Image {
+	b := img.Bounds()
+	var dst draw.Image
+	if HighBitDepth(img) {
+		dst = image.NewRGBA64(image.Rect(0, 0, w, h))
+	} else {
+		dst = image.NewRGBA(image.Rect(0, 0, w, h))
+	}
+	if b.Empty() || w <= 0 || h <= 0 {
+		return dst
+	}
+
+	scale := math.Min(float64(w)/float64(b.Dx()), float64(h)/float64(b.Dy()))
+	size := image.Pt(int(math.Round(float64(b.Dx())*scale)), int(math.Round(float64(b.Dy())*scale)))
+	if size.X < 1 {
+		size.X = 1
+	}
+	if size.Y < 1 {
+		size.Y = 1
+	}
+	draw.Draw(dst, Place(dst.Bounds(), size, Center, 0), Resize(img, size.X, size.Y), image.Point{}, draw.Src)
+
+	return dst
+}
+
+type contribution struct {
+	index  int
+	weight float64
+}
+
+// weights returns the source pixels and their weights for each destination pixel
+// of a tent filter widened by the scale when shrinking.
+func weights(srcLen, dstLen int) [][]contribution {
+	scale := float64(srcLen) / float64(dstLen)
+	support := math.Max(scale, 1)
+
+	ws := make([][]contribution, dstLen)
+	for i := range ws {
+		center := (float64(i)+0.5)*scale - 0.5
+		lo := int(math.Floor(center - support))
+		hi := int(math.Ceil(center + support))
+
+		var total float64
+		for j := lo; j <= hi; j++ {
+			w := 1 - math.Abs(float64(j)-center)/support
+			if w <= 0 {
+				continue
+			}
+			k := j
+			if k < 0 {
+				k = 0
+			}
+			if k >= srcLen {
+				k = srcLen - 1
+			}
+			ws[i] = append(ws[i], contribution{k, w})
+			total += w
+		}
+		for j := range ws[i] {
+			ws[i][j].weight /= total
+		}
+	}
+
+	return ws
+}
+
+// premultiplied returns the alpha-premultiplied 16-bit samples of img in row-major RGBA order.
+func premultiplied(img image.Image) []float64 {
+	b := img.Bounds()
+	pix := make([]float64, 0, b.Dx()*b.Dy()*4)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			pix = append(pix, float64(r), float64(g), float64(bl), float64(a))
+		}
+	}
+
+	return pix
+}
+
+func fromPremultiplied(pix []float64, w, h int, wide bool) image.Image {
+	clamp := func(v, max float64) float64 {
+		return math.Max(0, math.Min(math.Round(v), max))
+	}
+
+	if wide {
+		img := image.NewRGBA64(image.Rect(0, 0, w, h))
+		for i := 0; i < w*h; i++ {
+			p := pix[i*4:]
+			a := clamp(p[3], 0xffff)
+			img.SetRGBA64(i%w, i/w, color.RGBA64{
+				uint16(clamp(p[0], a)), uint16(clamp(p[1], a)), uint16(clamp(p[2], a)), uint16(a),
+			})
+		}
+		return img
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < w*h; i++ {
+		p := pix[i*4:]
+		a := clamp(p[3]/257, 0xff)
+		img.Pix[i*4+0] = uint8(clamp(p[0]/257, a))
+		img.Pix[i*4+1] = uint8(clamp(p[1]/257, a))
+		img.Pix[i*4+2] = uint8(clamp(p[2]/257, a))
+		img.Pix[i*4+3] = uint8(a)
+	}
+	return img
+}
@@ -0,0 +1,89 @@
+package imaging
+
+import (
+	"gopher-dojo/kadai2/exchanger/testing/helper"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestResize(t *testing.T) {
+	tests := []struct {
+		w, h int
+	}{
+		{32, 24}, {7, 3}, {128, 96}, {1, 1},
+	}
+
+	src := helper.Gradient(64, 48)
+	for _, tt := range tests {
+		got := Resize(src, tt.w, tt.h)
+		if got.Bounds() != image.Rect(0, 0, tt.w, tt.h) {
+			t.Errorf("Resize(%d, %d) bounds = %v", tt.w, tt.h, got.Bounds())
+		}
+	}
+
+	// 単色の画像は縮小しても色が変わらない。
+	flat := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for i := range flat.Pix {
+		flat.Pix[i] = 0x80
+	}
+	want := color.RGBAModel.Convert(flat.At(0, 0))
+	if c := Resize(flat, 3, 3).At(1, 1); c != want {
+		t.Errorf("Resize of a flat image = %v, want %v", c, want)
+	}
+
+	if _, ok := Resize(image.NewGray16(image.Rect(0, 0, 4, 4)), 2, 2).(*image.RGBA64); !ok {
+		t.Error("Resize of a 16-bit image is not *image.RGBA64")
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		size       image.Point
+		maxW, maxH int
+		want       image.Point
+	}{
+		{image.Pt(1456, 598), 128, 128, image.Pt(128, 53)},
+		{image.Pt(100, 200), 128, 128, image.Pt(64, 128)},
+		{image.Pt(50, 50), 128, 128, image.Pt(50, 50)},
+		{image.Pt(1000, 1), 10, 10, image.Pt(10, 1)},
+	}
+
+	for _, tt := range tests {
+		if got := Fit(tt.size, tt.maxW, tt.maxH); got != tt.want {
+			t.Errorf("Fit(%v, %d, %d) = %v, want %v", tt.size, tt.maxW, tt.maxH, got, tt.want)
+		}
+	}
+}
+
+func TestContain(t *testing.T) {
+	tests := []struct {
+		name   string
+		w, h   int
+		size   int
+		opaque image.Rectangle
+	}{
+		{"wide", 40, 20, 16, image.Rect(0, 4, 16, 12)},
+		{"tall", 10, 20, 16, image.Rect(4, 0, 12, 16)},
+		{"enlarged", 4, 4, 32, image.Rect(0, 0, 32, 32)},
+	}
+
+	for _, tt := range tests {
+		src := image.NewNRGBA(image.Rect(0, 0, tt.w, tt.h))
+		for i := range src.Pix {
+			src.Pix[i] = 0xff
+		}
+		got := Contain(src, tt.size, tt.size)
+		if s := got.Bounds().Size(); s != image.Pt(tt.size, tt.size) {
+			t.Fatalf("%s: size = %v, want %dx%d", tt.name, s, tt.size, tt.size)
+		}
+		for y := 0; y < tt.size; y++ {
+			for x := 0; x < tt.size; x++ {
+				_, _, _, a := got.At(x, y).RGBA()
+				if want := image.Pt(x, y).In(tt.opaque); (a == 0xffff) != want {
+					t.Fatalf("%s: alpha at (%d, %d) = %#x, want opaque %v", tt.name, x, y, a, want)
+				}
+			}
+		}
+	}
+}
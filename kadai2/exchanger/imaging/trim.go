@@ -0,0 +1,101 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// ContentBounds returns the bounds of img without its uniform borders.
+// If alpha is true, the border pixels are those whose alpha is at most tolerance.
+// Otherwise they are those which differ from the top-left pixel by at most tolerance
+// in every channel. Tolerance ranges from 0 to 1. It returns an empty rectangle
+// if the whole image is border.
+func ContentBounds(img image.Image, alpha bool, tolerance float64) image.Rectangle {
+	b := img.Bounds()
+	if b.Empty() {
+		return image.Rectangle{}
+	}
+
+	limit := uint32(clamp01(tolerance) * 0xffff)
+	r0, g0, b0, a0 := img.At(b.Min.X, b.Min.Y).RGBA()
+	isBorder := func(x, y int) bool {
+		r, g, bl, a := img.At(x, y).RGBA()
+		if alpha {
+			return a <= limit
+		}
+		return diff(r, r0) <= limit && diff(g, g0) <= limit && diff(bl, b0) <= limit && diff(a, a0) <= limit
+	}
+	rowIsBorder := func(y, x0, x1 int) bool {
+		for x := x0; x < x1; x++ {
+			if !isBorder(x, y) {
+				return false
+			}
+		}
+		return true
+	}
+	colIsBorder := func(x, y0, y1 int) bool {
+		for y := y0; y < y1; y++ {
+			if !isBorder(x, y) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// 上下、左右の順に内側へ詰めていく。
+	r := b
+	for r.Min.Y < r.Max.Y && rowIsBorder(r.Min.Y, r.Min.X, r.Max.X) {
+		r.Min.Y++
+	}
+	if r.Min.Y == r.Max.Y {
+		return image.Rectangle{}
+	}
+	for rowIsBorder(r.Max.Y-1, r.Min.X, r.Max.X) {
+		r.Max.Y--
+	}
+	for colIsBorder(r.Min.X, r.Min.Y, r.Max.Y) {
+		r.Min.X++
+	}
+	for colIsBorder(r.Max.X-1, r.Min.Y, r.Max.Y) {
+		r.Max.X--
+	}
+
+	return r
+}
+
+// Trim returns img cropped to ContentBounds and surrounded by padding pixels of
+// the border color, or transparent pixels if alpha is true.
+// An image which is all border is returned as is.
+func Trim(img image.Image, alpha bool, tolerance float64, padding int) image.Image {
+	r := ContentBounds(img, alpha, tolerance)
+	if r.Empty() {
+		return img
+	}
+	if padding < 0 {
+		padding = 0
+	}
+
+	var fill color.Color = color.Transparent
+	if !alpha {
+		fill = img.At(img.Bounds().Min.X, img.Bounds().Min.Y)
+	}
+	out := image.Rect(0, 0, r.Dx()+2*padding, r.Dy()+2*padding)
+	var dst draw.Image
+	if HighBitDepth(img) {
+		dst = image.NewRGBA64(out)
+	} else {
+		dst = image.NewRGBA(out)
+	}
+	draw.Draw(dst, out, image.NewUniform(fill), image.Point{}, draw.Src)
+	draw.Draw(dst, out.Inset(padding), img, r.Min, draw.Src)
+
+	return dst
+}
+
+func diff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
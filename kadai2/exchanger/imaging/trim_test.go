@@ -0,0 +1,62 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestContentBounds(t *testing.T) {
+	// 白地に灰色の枠とわずかなノイズ、中央に黒い四角
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	img.SetNRGBA(1, 8, color.NRGBA{0xfa, 0xfa, 0xfa, 0xff})
+	draw.Draw(img, image.Rect(5, 3, 12, 6), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	transparent := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	transparent.SetNRGBA(2, 3, color.NRGBA{0xff, 0, 0, 0xff})
+	transparent.SetNRGBA(5, 4, color.NRGBA{0, 0, 0xff, 0x08})
+
+	tests := []struct {
+		name      string
+		img       image.Image
+		alpha     bool
+		tolerance float64
+		want      image.Rectangle
+	}{
+		{"exact", img, false, 0, image.Rect(1, 3, 12, 9)},
+		{"tolerance", img, false, 0.05, image.Rect(5, 3, 12, 6)},
+		{"alpha", transparent, true, 0, image.Rect(2, 3, 6, 5)},
+		{"alpha tolerance", transparent, true, 0.1, image.Rect(2, 3, 3, 4)},
+		{"uniform", image.NewGray(image.Rect(0, 0, 3, 3)), false, 0, image.Rectangle{}},
+		{"all border", image.NewNRGBA(image.Rect(0, 0, 4, 4)), true, 0, image.Rectangle{}},
+	}
+
+	for _, tt := range tests {
+		if got := ContentBounds(tt.img, tt.alpha, tt.tolerance); got != tt.want {
+			t.Errorf("%s: ContentBounds = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTrim(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(5, 3, 12, 6), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	got := Trim(img, false, 0, 2)
+	if s := got.Bounds().Size(); s != image.Pt(11, 7) {
+		t.Fatalf("size = %v, want (11,7)", s)
+	}
+	if c := color.GrayModel.Convert(got.At(0, 0)).(color.Gray); c.Y != 0xff {
+		t.Errorf("padding = %v, want white", c)
+	}
+	if c := color.GrayModel.Convert(got.At(2, 2)).(color.Gray); c.Y != 0 {
+		t.Errorf("content = %v, want black", c)
+	}
+
+	if got := Trim(image.NewNRGBA(image.Rect(0, 0, 4, 4)), true, 0, 2); got.Bounds().Size() != image.Pt(4, 4) {
+		t.Errorf("all border image is trimmed to %v", got.Bounds())
+	}
+}
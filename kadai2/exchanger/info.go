@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/converter"
+	"os"
+)
+
+func (cli *CLI) runInfo(args []string) int {
+	fs := cli.flagSet("info", "Prints the format, size and color model of the images. Directories are searched recursively.")
+	asJSON := fs.Bool("json", false, "print the information as JSON")
+	if err := fs.Parse(args); err != nil {
+		return exitCode(err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 1
+	}
+
+	infos := []*converter.ImageInfo{}
+	for _, path := range fs.Args() {
+		st, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintln(cli.errStream, err)
+			return 1
+		}
+		if st.IsDir() {
+			is, err := converter.InspectAll(path)
+			if err != nil {
+				fmt.Fprintln(cli.errStream, err)
+				return 1
+			}
+			infos = append(infos, is...)
+			continue
+		}
+		info, err := converter.Inspect(path)
+		if err != nil {
+			fmt.Fprintln(cli.errStream, err)
+			return 1
+		}
+		infos = append(infos, info)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(cli.outStream)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(infos); err != nil {
+			fmt.Fprintln(cli.errStream, err)
+			return 1
+		}
+		return 0
+	}
+
+	for _, i := range infos {
+		fmt.Fprintf(cli.outStream, "%s  %s  %dx%d  %s  %d bytes\n", i.Path, i.Format, i.Width, i.Height, i.ColorModel, i.Bytes)
+	}
+	return 0
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/converter"
+)
+
+func (cli *CLI) runJob(args []string) int {
+	fs := cli.flagSet("job", "Runs all the jobs listed in the JSON job files in order.")
+	if err := fs.Parse(args); err != nil {
+		return exitCode(err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 1
+	}
+
+	var jobs []*converter.Job
+	for _, path := range fs.Args() {
+		js, err := converter.LoadJobs(path)
+		if err != nil {
+			fmt.Fprintln(cli.errStream, err)
+			return 1
+		}
+		jobs = append(jobs, js...)
+	}
+
+	reports, err := converter.RunJobs(jobs)
+	flagged := 0
+	for i, r := range reports {
+		fmt.Fprintf(cli.outStream, "%s: %d files written\n", jobs[i].Name, len(r.Files))
+		for _, f := range r.Flagged() {
+			printFlagged(cli, f)
+			flagged++
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(cli.errStream, err)
+		return 1
+	}
+	if flagged > 0 {
+		fmt.Fprintf(cli.errStream, "%d files failed verification\n", flagged)
+		return 1
+	}
+
+	return 0
+}
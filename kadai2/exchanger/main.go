@@ -1,56 +1,136 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
-	"gopher-dojo/kadai1/exchanger/converter"
 	"io"
 	"os"
 )
 
+// CLI runs the commands of the exchanger, reading lists from inStream and writing all the output to its streams.
 type CLI struct {
+	inStream             io.Reader
 	outStream, errStream io.Writer
 }
 
 func main() {
-	cli := &CLI{outStream: os.Stdout, errStream: os.Stderr}
-	os.Exit(cli.Run())
+	cli := &CLI{inStream: os.Stdin, outStream: os.Stdout, errStream: os.Stderr}
+	os.Exit(cli.Run(os.Args[1:]))
 }
 
-func (cli *CLI) Run() int {
-	flag.Usage = usage
-	flag.Parse()
-	if len(os.Args[1:]) != 3 {
-		flag.Usage()
-		return 1
+// commands are the subcommands and their arguments, listed in this order in the usage.
+var commands = []struct {
+	name, usage string
+}{
+	{"convert", "[options] target directory"},
+	{"convert", "[options] -list file"},
+	{"info", "[options] file or directory..."},
+	{"verify", "[options] original file converted file"},
+	{"dupes", "[options] target directory"},
+	{"diff", "[options] directory directory"},
+	{"sheet", "[options] target directory"},
+	{"job", "job file..."},
+	{"serve", "[options]"},
+	{"clean", "[options] output directory"},
+}
+
+// Run runs the command given by args, which exclude the program name, and returns the exit code.
+// The options before the command write the profiles of the run.
+func (cli *CLI) Run(args []string) int {
+	fs := flag.NewFlagSet("main", flag.ContinueOnError)
+	fs.SetOutput(cli.errStream)
+	fs.Usage = cli.usage
+	cpuProfile := fs.String("cpuprofile", "", "")
+	memProfile := fs.String("memprofile", "", "")
+	if err := fs.Parse(args); err != nil {
+		return exitCode(err)
 	}
 
-	if err := os.MkdirAll("output", 0777); err != nil {
+	stop, err := cli.startProfiles(*cpuProfile, *memProfile)
+	if err != nil {
 		fmt.Fprintln(cli.errStream, err)
 		return 1
 	}
+	defer stop()
 
-	from := flag.Arg(0)
-	to := flag.Arg(1)
-	src := flag.Arg(2)
-	count, err := converter.ConvertEtx(src, from, to)
-	if err != nil {
-		fmt.Fprintln(cli.errStream, err)
+	return cli.run(fs.Args())
+}
+
+func (cli *CLI) run(args []string) int {
+	if len(args) == 0 {
+		cli.usage()
 		return 1
 	}
-	if count == 0 {
-		fmt.Println("Files with extension you specified not found")
-	} else {
-		fmt.Printf("%d files converted! see under ./output\n", count)
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		cli.usage()
+		return 0
+	case "convert":
+		return cli.runConvert(args[1:])
+	case "info":
+		return cli.runInfo(args[1:])
+	case "verify":
+		return cli.runVerify(args[1:])
+	case "dupes":
+		return cli.runDupes(args[1:])
+	case "diff":
+		return cli.runDiff(args[1:])
+	case "sheet":
+		return cli.runSheet(args[1:])
+	case "job":
+		return cli.runJob(args[1:])
+	case "serve":
+		return cli.runServe(args[1:])
+	case "clean":
+		return cli.runClean(args[1:])
+	}
+
+	// 以前の `main from to dir` の形式も convert として受け付ける。
+	if len(args) == 3 {
+		return cli.runConvert([]string{"-from", args[0], "-to", args[1], args[2]})
 	}
 
-	return 0
+	fmt.Fprintf(cli.errStream, "unknown command %q\n\n", args[0])
+	cli.usage()
+	return 1
 }
 
-func usage() {
-	fmt.Println("Usage:")
-	fmt.Println("  main extension(from) extension(to) target directory")
-	fmt.Println("")
-	fmt.Println("All of the args are required.")
-	flag.PrintDefaults()
+func (cli *CLI) usage() {
+	fmt.Fprintln(cli.errStream, "Usage:")
+	for _, c := range commands {
+		fmt.Fprintf(cli.errStream, "  main %s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintln(cli.errStream, "")
+	fmt.Fprintln(cli.errStream, "Run \"main <command> -h\" for the options of each command.")
+	fmt.Fprintln(cli.errStream, "Put -cpuprofile file or -memprofile file before the command to write its CPU or heap profile.")
+}
+
+// flagSet returns the flag set of the command name, which prints the usage and
+// the description to the error stream.
+func (cli *CLI) flagSet(name, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(cli.errStream)
+	fs.Usage = func() {
+		fmt.Fprintln(cli.errStream, "Usage:")
+		for _, c := range commands {
+			if c.name == name {
+				fmt.Fprintf(cli.errStream, "  main %s %s\n", c.name, c.usage)
+			}
+		}
+		fmt.Fprintln(cli.errStream, "")
+		fmt.Fprintln(cli.errStream, description)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// exitCode returns the exit code for the error of flag.FlagSet.Parse.
+// Asking for the help is not a failure.
+func exitCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	return 1
 }
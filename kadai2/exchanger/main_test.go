@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	out := t.TempDir()
+	job := filepath.Join(out, "jobs.json")
+	src, err := filepath.Abs("converter/testdata/verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := `{"jobs": [{"name": "pngs", "src": "` + src + `", "targets": ["png"], "out": "job"}]}`
+	if err := ioutil.WriteFile(job, []byte(data), 0666); err != nil {
+		t.Fatal(err)
+	}
+	// 標準入力と -list のファイルに渡す一覧
+	stdin := "converter/testdata/verify/gradient.jpg\nconverter/testdata/verify/deleted.jpg\n"
+	list := filepath.Join(out, "list")
+	if err := ioutil.WriteFile(list, []byte("converter/testdata/verify/gradient.jpeg\x00converter/testdata/sample/dojo1.png\x00"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重複を探すディレクトリに壊れたファイルを混ぜる。
+	broken := filepath.Join(out, "broken")
+	jpg, err := ioutil.ReadFile("converter/testdata/verify/gradient.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(broken, 0777); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{"a.jpg": jpg, "b.jpg": jpg, "c.png": []byte("not a png")} {
+		if err := ioutil.WriteFile(filepath.Join(broken, name), data, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+		// 標準出力と標準エラー出力に含まれるはずの文字列
+		out, err string
+		// 書き出されるはずのファイル
+		file string
+	}{
+		{"no args", nil, 1, "", "Usage:", ""},
+		{"help", []string{"help"}, 0, "", "main convert", ""},
+		{"help flag", []string{"-h"}, 0, "", "-cpuprofile", ""},
+		{"profiles", []string{"-cpuprofile", out + "/cpu.prof", "-memprofile", out + "/mem.prof", "info", "converter/testdata/verify"}, 0,
+			"jpeg  64x48", "", "mem.prof"},
+		{"profile bad path", []string{"-cpuprofile", out + "/nothing/cpu.prof", "info", "converter/testdata/verify"}, 1, "", "no such file", ""},
+		{"unknown flag", []string{"-hoge", "info"}, 1, "", "Usage:", ""},
+		{"unknown command", []string{"hoge", "fuga"}, 1, "", `unknown command "hoge"`, ""},
+		{"convert", []string{"convert", "-from", "jpg", "-to", "png", "-o", out + "/c", "converter/testdata/verify"}, 0,
+			"1 files converted!", "", "c/gradient.png"},
+		{"convert with options", []string{"convert", "-from", "jpeg", "-to", "jpg", "-quality", "50", "-verify", "-min-psnr", "20",
+			"-o", out + "/q", "converter/testdata/verify"}, 0, "1 files converted!", "", "q/gradient.jpg"},
+		{"convert flagged", []string{"convert", "-from", "jpeg", "-to", "jpg", "-verify", "-min-psnr", "100",
+			"-o", out + "/f", "converter/testdata/verify"}, 1, "flagged", "1 files failed verification", "f/gradient.jpg"},
+		{"convert preset", []string{"convert", "-from", "jpg", "-preset", "responsive", "-widths", "16,32", "-to", "png",
+			"-o", out + "/r", "converter/testdata/verify"}, 0, "4 files converted!", "", "r/gradient-32w.png"},
+		{"convert trim", []string{"convert", "-from", "jpg", "-to", "png", "-trim", "-trim-tolerance", "0.1", "-trim-padding", "2",
+			"-o", out + "/t", "converter/testdata/verify"}, 0, "1 files converted!", "", "t/gradient.png"},
+		{"convert depth", []string{"convert", "-from", "jpg", "-to", "jpg", "-depth", "8", "-o", out + "/d", "converter/testdata/verify"}, 0,
+			"1 files converted!", "", "d/gradient.jpg"},
+		{"convert caption", []string{"convert", "-from", "jpg", "-to", "jpg", "-caption", "{name} {date}", "-caption-background", "#00000080",
+			"-caption-padding", "2", "-o", out + "/cap", "converter/testdata/verify"}, 0, "1 files converted!", "", "cap/gradient.jpg"},
+		{"convert bad caption", []string{"convert", "-from", "jpg", "-to", "png", "-caption", "x", "-caption-anchor", "middle",
+			"converter/testdata/verify"}, 1, "", "anchor", ""},
+		{"convert filters", []string{"convert", "-from", "jpg", "-to", "png", "-filter", "gamma=1.8", "-filter", "unsharp=1,0.5,0.01",
+			"-o", out + "/fl", "converter/testdata/verify"}, 0, "1 files converted!", "", "fl/gradient.png"},
+		{"convert bad filter", []string{"convert", "-from", "jpg", "-to", "png", "-filter", "blur", "converter/testdata/verify"}, 1,
+			"", "want blur=SIGMA", ""},
+		{"convert same format", []string{"convert", "-from", "jpg", "-to", "jpg", "converter/testdata/verify"}, 1, "", "from and to are same", ""},
+		{"convert too large", []string{"convert", "-from", "jpg", "-to", "png", "-max-pixels", "1000", "-workers", "2", "-o", out + "/l",
+			"converter/testdata/verify"}, 1, "", "image is too large", ""},
+		{"legacy convert", []string{"jpg", "qoi", "converter/testdata/verify"}, 0, "1 files converted!", "", ""},
+		{"convert not found", []string{"convert", "-from", "bmp", "-to", "png", "-o", out, "converter/testdata/verify"}, 0,
+			"not found", "", ""},
+		{"convert without to", []string{"convert", "-from", "jpg", "converter/testdata/verify"}, 1, "", "Usage:", ""},
+		{"convert bad option", []string{"convert", "-from", "jpg", "-to", "png", "-quality", "0", "converter/testdata/verify"}, 1,
+			"", "quality must be between", ""},
+		{"convert bad list", []string{"convert", "-widths", "a,b", "converter/testdata/verify"}, 1, "", "invalid value", ""},
+		{"convert list", []string{"convert", "-from", "jpg", "-to", "png", "-list", "-", "-o", out + "/ls"}, 0,
+			"1 files converted!", "skipped converter/testdata/verify/deleted.jpg", "ls/gradient.png"},
+		{"convert list file", []string{"convert", "-from", "jpeg", "-to", "bmp", "-list", list, "-0", "-o", out + "/lf"}, 0,
+			"1 files converted!", "", "lf/gradient.bmp"},
+		{"convert list and directory", []string{"convert", "-from", "jpg", "-to", "png", "-list", "-", "converter/testdata/verify"}, 1,
+			"", "Usage:", ""},
+		{"convert list missing", []string{"convert", "-from", "jpg", "-to", "png", "-list", out + "/nothing"}, 1, "", "no such file", ""},
+		{"convert encoder", []string{"convert", "-from", "jpg", "-to", "raw", "-encoder", "raw=cat", "-o", out + "/e",
+			"converter/testdata/verify"}, 0, "1 files converted!", "", "e/gradient.raw"},
+		{"convert decoder", []string{"convert", "-from", "raw", "-to", "png", "-decoder", ".RAW=cat -", "-o", out + "/ed", out + "/e"}, 0,
+			"1 files converted!", "", "ed/gradient.png"},
+		{"convert bad encoder", []string{"convert", "-from", "jpg", "-to", "raw", "-encoder", "raw", "converter/testdata/verify"}, 1,
+			"", "want ext=command", ""},
+		{"convert metadata", []string{"convert", "-from", "jpg", "-to", "png", "-metadata", "keep", "-text", "Copyright=ACME",
+			"-o", out + "/m", "converter/testdata/verify"}, 0, "1 files converted!", "", "m/gradient.png"},
+		{"convert bad text", []string{"convert", "-from", "jpg", "-to", "png", "-text", "=ACME", "converter/testdata/verify"}, 1,
+			"", "want key=value", ""},
+		{"convert help", []string{"convert", "-h"}, 0, "", "-tiff-compression", ""},
+		{"info", []string{"info", "converter/testdata/verify/gradient.jpg", "converter/testdata/verify"}, 0,
+			"jpeg  64x48  YCbCr", "", ""},
+		{"info json", []string{"info", "-json", "converter/testdata/verify/gradient.jpg"}, 0, `"colorModel": "YCbCr"`, "", ""},
+		{"info missing", []string{"info", "converter/testdata/nothing.png"}, 1, "", "no such file", ""},
+		{"verify", []string{"verify", "converter/testdata/verify/gradient.jpg", "converter/testdata/verify/gradient.jpeg"}, 0,
+			"identical", "", ""},
+		{"verify args", []string{"verify", "converter/testdata/verify/gradient.jpg"}, 1, "", "Usage:", ""},
+		{"dupes", []string{"dupes", "converter/testdata/verify"}, 0, "gradient.jpeg", "", ""},
+		{"dupes undecodable", []string{"dupes", broken}, 1, "broken/b.jpg", "c.png: ", ""},
+		{"diff same", []string{"diff", "converter/testdata/verify", "converter/testdata/verify"}, 0, "0 added, 0 removed, 0 changed, 2 same", "", ""},
+		{"diff", []string{"diff", "-tolerance", "1", "-visualize", out + "/v", "converter/testdata/verify", out + "/q"}, 1,
+			"changed  converter/testdata/verify/gradient.jpeg", "", "v/gradient.png"},
+		{"diff json", []string{"diff", "-json", "converter/testdata/verify", out + "/q"}, 1, `"status": "removed"`, "", ""},
+		{"diff missing", []string{"diff", "converter/testdata/verify", out + "/nothing"}, 1, "", "no such file", ""},
+		{"diff too large", []string{"diff", "-max-pixels", "100", "converter/testdata/verify", "converter/testdata/verify"}, 1, "", "image is too large", ""},
+		{"sheet", []string{"sheet", "-o", out + "/sheet.png", "converter/testdata/verify"}, 0, "2 images tiled", "", "sheet.png"},
+		{"sheet too large", []string{"sheet", "-max-pixels", "100", "-o", out + "/large.png", "converter/testdata/verify"}, 1, "", "image is too large", ""},
+		{"job", []string{"job", job}, 0, "pngs: 2 files written", "", "job/gradient.png"},
+		{"serve args", []string{"serve", "-concurrency", "0"}, 1, "", "Usage:", ""},
+		{"clean list", []string{"clean", out + "/c"}, 0, "1 files", "", ""},
+		{"clean dry run", []string{"clean", "-stale", "-n", out + "/c"}, 0, "0 files would be deleted", "", "c/gradient.png"},
+		{"clean run", []string{"clean", "-run", "last", out + "/t"}, 0, "1 files deleted", "", ""},
+		{"clean nothing", []string{"clean", out + "/t"}, 0, "No runs recorded", "", ""},
+		{"clean unknown run", []string{"clean", "-run", "hoge", out + "/c"}, 1, "", `unknown run "hoge"`, ""},
+		{"clean both", []string{"clean", "-run", "last", "-stale", out + "/c"}, 1, "", "Usage:", ""},
+		{"job missing", []string{"job", out + "/nothing.json"}, 1, "", "no such file", ""},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		cli := &CLI{inStream: strings.NewReader(stdin), outStream: &stdout, errStream: &stderr}
+		if code := cli.Run(tt.args); code != tt.code {
+			t.Errorf("%s: exit code = %d, want %d\nstderr: %s", tt.name, code, tt.code, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), tt.out) {
+			t.Errorf("%s: stdout = %q, want %q in it", tt.name, stdout.String(), tt.out)
+		}
+		if !strings.Contains(stderr.String(), tt.err) {
+			t.Errorf("%s: stderr = %q, want %q in it", tt.name, stderr.String(), tt.err)
+		}
+		if tt.file != "" {
+			if _, err := os.Stat(filepath.Join(out, tt.file)); err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+		}
+	}
+
+	// 旧形式は既定の output に書き出す。
+	if err := os.RemoveAll("output"); err != nil {
+		t.Error("failed to delete an output folder")
+	}
+}
+
+func TestRunServeBadPort(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	cli := &CLI{outStream: &stdout, errStream: &stderr}
+	if code := cli.Run([]string{"serve", "-port", "-1"}); code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+	// 待ち受けられなかったポートを知らせない。
+	if stdout.Len() != 0 {
+		t.Errorf("stdout = %q, want nothing", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "invalid port") {
+		t.Errorf("stderr = %q, want %q in it", stderr.String(), "invalid port")
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// startProfiles starts the CPU profile to the file cpu and returns the function which
+// stops it and writes the heap profile to the file mem. Empty names are not profiled.
+func (cli *CLI) startProfiles(cpu, mem string) (stop func(), err error) {
+	var cpuFile *os.File
+	if cpu != "" {
+		if cpuFile, err = os.Create(cpu); err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			cpuFile.Close()
+			return nil, err
+		}
+	}
+
+	return func() {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			if err := cpuFile.Close(); err != nil {
+				fmt.Fprintln(cli.errStream, err)
+			}
+		}
+		if mem != "" {
+			if err := writeHeapProfile(mem); err != nil {
+				fmt.Fprintln(cli.errStream, err)
+			}
+		}
+	}, nil
+}
+
+func writeHeapProfile(name string) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	// 直近の GC の時点の統計なので、書き出す前に回収しておく。
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
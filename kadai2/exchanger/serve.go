@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/server"
+	"net"
+)
+
+func (cli *CLI) runServe(args []string) int {
+	def := server.DefaultConfig()
+	fs := cli.flagSet("serve", "Serves the conversion over HTTP. POST an image to /convert?to=<format>&<options>.")
+	port := fs.String("port", def.Port, "port to listen on")
+	maxBody := fs.Int64("max-body", def.MaxBodyBytes>>20, "largest size in MiB of a posted image")
+	concurrency := fs.Int("concurrency", def.MaxConcurrent, "number of conversions at the same time")
+	if err := fs.Parse(args); err != nil {
+		return exitCode(err)
+	}
+	if fs.NArg() != 0 || *maxBody < 1 || *concurrency < 1 {
+		fs.Usage()
+		return 1
+	}
+
+	def.Port = *port
+	def.MaxBodyBytes = *maxBody << 20
+	def.MaxConcurrent = *concurrency
+	srv := server.New(def)
+	l, err := srv.Listen()
+	if err != nil {
+		fmt.Fprintln(cli.errStream, err)
+		return 1
+	}
+	// ポートを確保してから知らせるので、0 なら選ばれたポートが表示される。
+	fmt.Fprintf(cli.outStream, "listening on :%d\n", l.Addr().(*net.TCPAddr).Port)
+	if err := srv.Serve(l); err != nil {
+		fmt.Fprintln(cli.errStream, err)
+		return 1
+	}
+
+	return 0
+}
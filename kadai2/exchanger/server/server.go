@@ -0,0 +1,255 @@
+// Package server serves the conversions of the exchanger over HTTP.
+//
+// A client POSTs an image to /convert with the target format and the options as
+// query parameters, such as /convert?to=png&colors=64, and gets the converted image.
+// Errors are returned as JSON objects like {"error": "to is not supported"}.
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/converter"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ゆっくり送られるリクエストや読まれないレスポンスで接続を占有させないための時間制限。
+// 書き込みの制限は変換の時間も含む。
+const (
+	readHeaderTimeout = 10 * time.Second
+	readTimeout       = time.Minute
+	writeTimeout      = 5 * time.Minute
+)
+
+// Server converts the images posted to it.
+type Server struct {
+	config *Config
+	// sem は同時に変換できるリクエストの数だけ空きがある。
+	sem chan struct{}
+}
+
+// Config configures a Server.
+type Config struct {
+	Port string
+	// MaxBodyBytes is the largest size of a posted image.
+	MaxBodyBytes int64
+	// MaxConcurrent is the number of conversions at the same time.
+	// Requests over it are rejected with 503 Service Unavailable.
+	MaxConcurrent int
+	// Options returns the options a request starts with before its query parameters are applied.
+	Options func() *converter.Options
+}
+
+// DefaultConfig returns the configuration with the port 8080, 32 MiB of request body and
+// the conversions as many as GOMAXPROCS, with the default options of the converter.
+func DefaultConfig() *Config {
+	return &Config{
+		Port:          "8080",
+		MaxBodyBytes:  32 << 20,
+		MaxConcurrent: runtime.GOMAXPROCS(0),
+		Options:       converter.DefaultOptions,
+	}
+}
+
+// New returns a server with config.
+func New(config *Config) *Server {
+	return &Server{config: config, sem: make(chan struct{}, config.MaxConcurrent)}
+}
+
+// Handler returns the handler serving /convert.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/convert", s.convert)
+
+	return mux
+}
+
+// Run listens on the port and serves the handler.
+func (s *Server) Run() error {
+	l, err := s.Listen()
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Listen listens on the port of the configuration.
+func (s *Server) Listen() (net.Listener, error) {
+	return net.Listen("tcp", ":"+s.config.Port)
+}
+
+// Serve serves the handler on l until it fails.
+func (s *Server) Serve(l net.Listener) error {
+	httpServer := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+	}
+	return httpServer.Serve(l)
+}
+
+func (s *Server) convert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method must be POST"))
+		return
+	}
+
+	q := r.URL.Query()
+	to := strings.ToLower(q.Get("to"))
+	if to == "" {
+		writeError(w, http.StatusBadRequest, errors.New("to is required"))
+		return
+	}
+	mediaType := converter.MediaType(to)
+	if mediaType == "" {
+		writeError(w, http.StatusBadRequest, errors.New("to is not supported"))
+		return
+	}
+	opts := s.config.Options()
+	if err := parseOptions(q, opts); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// 混んでいるときは待たせずに断る。
+	select {
+	case s.sem <- struct{}{}:
+		defer func() { <-s.sem }()
+	default:
+		writeError(w, http.StatusServiceUnavailable, errors.New("server is busy"))
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, s.config.MaxBodyBytes)
+	var buf bytes.Buffer
+	if err := converter.ConvertImage(&buf, body, to, opts); err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Println("Error: ", err)
+	}
+}
+
+// statusOf returns the status code for an error of the conversion.
+func statusOf(err error) int {
+	switch {
+	// Go 1.17 の http.MaxBytesReader のエラーは型で区別できない。
+	case errors.Is(err, converter.ErrTooLarge), strings.Contains(err.Error(), "request body too large"):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, converter.ErrBudgetExceeded):
+		return http.StatusUnprocessableEntity
+	// 画像として読めないものや、変換できないオプションはクライアントの誤り。
+	case errors.Is(err, converter.ErrInvalidInput):
+		return http.StatusBadRequest
+	}
+	// 書き出しの失敗などはサーバの誤り。
+	return http.StatusInternalServerError
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{err.Error()}); err != nil {
+		log.Println("Error: ", err)
+	}
+}
+
+// parseOptions sets the fields of opts given by the query parameters.
+// Options which read files on the server, such as the watermark, are not accepted.
+func parseOptions(q url.Values, opts *converter.Options) error {
+	ints := map[string]*int{
+		"quality":    &opts.Quality,
+		"maxBytes":   &opts.MaxBytes,
+		"minQuality": &opts.MinQuality,
+		"colors":     &opts.Colors,
+		"depth":      &opts.Depth,
+	}
+	for name, p := range ints {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s must be an integer", name)
+			}
+			*p = n
+		}
+	}
+
+	if v := q.Get("dither"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return errors.New("dither must be a boolean")
+		}
+		opts.Dither = b
+	}
+	if v := q.Get("tiffCompression"); v != "" {
+		opts.TIFFCompression = v
+	}
+	if v := q.Get("metadata"); v != "" {
+		opts.Metadata = v
+	}
+	if v := q.Get("icoSizes"); v != "" {
+		opts.ICOSizes = nil
+		for _, s := range strings.Split(v, ",") {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return errors.New("icoSizes must be comma-separated integers")
+			}
+			opts.ICOSizes = append(opts.ICOSizes, n)
+		}
+	}
+
+	// 引数にカンマを含むので、フィルタは繰り返して指定する。
+	opts.Filters = q["filter"]
+	if v := q.Get("caption"); v != "" {
+		opts.Caption = &converter.Caption{
+			Text:       v,
+			Anchor:     q.Get("captionAnchor"),
+			Color:      q.Get("captionColor"),
+			Background: q.Get("captionBackground"),
+		}
+		if v := q.Get("captionScale"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return errors.New("captionScale must be an integer")
+			}
+			opts.Caption.Scale = n
+		}
+	}
+	if v := q.Get("trim"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return errors.New("trim must be a boolean")
+		}
+		if b {
+			opts.Trim = &converter.Trim{Mode: q.Get("trimMode")}
+			if v := q.Get("trimTolerance"); v != "" {
+				if opts.Trim.Tolerance, err = strconv.ParseFloat(v, 64); err != nil {
+					return errors.New("trimTolerance must be a number")
+				}
+			}
+			if v := q.Get("trimPadding"); v != "" {
+				if opts.Trim.Padding, err = strconv.Atoi(v); err != nil {
+					return errors.New("trimPadding must be an integer")
+				}
+			}
+		}
+	}
+
+	return nil
+}
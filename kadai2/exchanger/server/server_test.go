@@ -0,0 +1,177 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/converter"
+	"image"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	jpg, err := ioutil.ReadFile("../converter/testdata/verify/gradient.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		query     string
+		body      []byte
+		config    func(c *Config)
+		status    int
+		mediaType string
+		format    string
+	}{
+		{"png", "POST", "to=png", jpg, nil, http.StatusOK, "image/png", "png"},
+		{"options", "POST", "to=PNG&colors=8&dither=true&trim=true&trimTolerance=0.5", jpg, nil, http.StatusOK, "image/png", "png"},
+		{"jpeg", "POST", "to=jpg&quality=50", jpg, nil, http.StatusOK, "image/jpeg", "jpeg"},
+		{"ico", "POST", "to=ico&icoSizes=16,32", jpg, nil, http.StatusOK, "image/vnd.microsoft.icon", "ico"},
+		{"metadata", "POST", "to=jpg&metadata=keep", jpg, nil, http.StatusOK, "image/jpeg", "jpeg"},
+		{"caption", "POST", "to=png&caption=hello&captionBackground=%23000000&captionScale=2", jpg, nil, http.StatusOK, "image/png", "png"},
+		{"bad caption", "POST", "to=png&caption=hello&captionColor=red", jpg, nil, http.StatusBadRequest, "", ""},
+		{"filters", "POST", "to=png&filter=saturation=0&filter=unsharp=1,0.5", jpg, nil, http.StatusOK, "image/png", "png"},
+		{"bad filter", "POST", "to=png&filter=emboss", jpg, nil, http.StatusBadRequest, "", ""},
+		{"bad metadata", "POST", "to=jpg&metadata=all", jpg, nil, http.StatusBadRequest, "", ""},
+		{"get", "GET", "to=png", nil, nil, http.StatusMethodNotAllowed, "", ""},
+		{"no target", "POST", "", jpg, nil, http.StatusBadRequest, "", ""},
+		{"unknown target", "POST", "to=hoge", jpg, nil, http.StatusBadRequest, "", ""},
+		{"bad option", "POST", "to=jpg&quality=high", jpg, nil, http.StatusBadRequest, "", ""},
+		{"invalid option", "POST", "to=jpg&quality=0", jpg, nil, http.StatusBadRequest, "", ""},
+		{"not an image", "POST", "to=png", []byte("hello"), nil, http.StatusBadRequest, "", ""},
+		{"body too large", "POST", "to=png", jpg, func(c *Config) { c.MaxBodyBytes = 100 }, http.StatusRequestEntityTooLarge, "", ""},
+		{"too many pixels", "POST", "to=png", jpg, func(c *Config) {
+			c.Options = func() *converter.Options {
+				opts := converter.DefaultOptions()
+				opts.MaxPixels = 100
+				return opts
+			}
+		}, http.StatusRequestEntityTooLarge, "", ""},
+		{"padding too large", "POST", "to=png&trim=true&trimMode=alpha&trimPadding=6000", jpg, nil, http.StatusBadRequest, "", ""},
+		// 余白で元の画像より大きくなった結果も上限を確かめる。
+		{"padded over max pixels", "POST", "to=png&trim=true&trimMode=alpha&trimPadding=20", jpg, func(c *Config) {
+			c.Options = func() *converter.Options {
+				opts := converter.DefaultOptions()
+				opts.MaxPixels = 5000
+				return opts
+			}
+		}, http.StatusRequestEntityTooLarge, "", ""},
+		{"budget", "POST", "to=jpg&maxBytes=10", jpg, nil, http.StatusUnprocessableEntity, "", ""},
+	}
+
+	for _, tt := range tests {
+		config := DefaultConfig()
+		if tt.config != nil {
+			tt.config(config)
+		}
+		server := New(config)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(tt.method, "/convert?"+tt.query, bytes.NewReader(tt.body))
+		server.Handler().ServeHTTP(w, r)
+
+		rw := w.Result()
+		defer rw.Body.Close()
+		if rw.StatusCode != tt.status {
+			t.Errorf("%s: status code is %d, want %d", tt.name, rw.StatusCode, tt.status)
+		}
+		b, err := ioutil.ReadAll(rw.Body)
+		if err != nil {
+			t.Fatalf("failed to read body: %s", err)
+		}
+
+		if tt.status != http.StatusOK {
+			// エラーは JSON で返る。
+			var e struct{ Error string }
+			if err := json.Unmarshal(b, &e); err != nil || e.Error == "" {
+				t.Errorf("%s: body %q is not a JSON error", tt.name, b)
+			}
+			continue
+		}
+		if ct := rw.Header.Get("Content-Type"); ct != tt.mediaType {
+			t.Errorf("%s: Content-Type is %s, want %s", tt.name, ct, tt.mediaType)
+		}
+		if _, format, err := image.DecodeConfig(bytes.NewReader(b)); err != nil || format != tt.format {
+			t.Errorf("%s: decoded as %s, %v, want %s", tt.name, format, err, tt.format)
+		}
+	}
+}
+
+func TestConvertBusy(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxConcurrent = 1
+	server := New(config)
+	// 変換中のリクエストがあるように見せる。
+	server.sem <- struct{}{}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/convert?to=png", bytes.NewReader(nil))
+	server.Handler().ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status code is %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	<-server.sem
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/convert?to=png", bytes.NewReader(nil))
+	server.Handler().ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status code after the conversion is %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestStatusOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("image: %w", converter.ErrTooLarge), http.StatusRequestEntityTooLarge},
+		{errors.New("http: request body too large"), http.StatusRequestEntityTooLarge},
+		{converter.ErrBudgetExceeded, http.StatusUnprocessableEntity},
+		{fmt.Errorf("colors: %w", converter.ErrInvalidInput), http.StatusBadRequest},
+		// 書き出しや入出力の失敗はクライアントの誤りにしない。
+		{errors.New("write failed"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if got := statusOf(tt.err); got != tt.want {
+			t.Errorf("statusOf(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	jpg, err := ioutil.ReadFile("../converter/testdata/verify/gradient.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := DefaultConfig()
+	config.Port = "0"
+	server := New(config)
+	l, err := server.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(l) }()
+
+	resp, err := http.Post("http://"+l.Addr().String()+"/convert?to=png", "image/jpeg", bytes.NewReader(jpg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status code is %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	l.Close()
+	if err := <-done; err == nil {
+		t.Error("Serve returned no error after the listener is closed")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/converter"
+	"os"
+	"path/filepath"
+)
+
+func (cli *CLI) runSheet(args []string) int {
+	def := converter.DefaultSheetOptions()
+	fs := cli.flagSet("sheet", "Tiles the images under the target directory into a contact sheet or a sprite sheet.")
+	mode := fs.String("mode", def.Mode, "grid for a contact sheet or sprite for a sprite sheet")
+	from := fs.String("from", "", "extension of the images to include (default all the supported formats)")
+	cols := fs.Int("cols", def.Columns, "number of columns of a contact sheet")
+	cell := fs.Int("cell", def.Cell, "maximum thumbnail size of a contact sheet")
+	padding := fs.Int("padding", def.Padding, "space between the images")
+	captions := fs.Bool("captions", false, "draw file names under the thumbnails")
+	out := fs.String("o", "output/sheet.png", "output image")
+	mapFile := fs.String("map", "", "output JSON coordinate map (default the output image with .json for sprite sheets)")
+	maxPixels := fs.Int64("max-pixels", def.MaxPixels, "largest number of pixels of an image (0 means no limit)")
+	memoryBudget := fs.Int64("memory-budget", def.MemoryBudget>>20, "estimated memory in MiB of all the images (0 means no limit)")
+	if err := fs.Parse(args); err != nil {
+		return exitCode(err)
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+
+	sheet, err := converter.MakeSheet(fs.Arg(0), &converter.SheetOptions{
+		Mode:         *mode,
+		From:         *from,
+		Columns:      *cols,
+		Cell:         *cell,
+		Padding:      *padding,
+		Captions:     *captions,
+		MaxPixels:    *maxPixels,
+		MemoryBudget: *memoryBudget << 20,
+	})
+	if err != nil {
+		fmt.Fprintln(cli.errStream, err)
+		return 1
+	}
+
+	if err := os.MkdirAll(filepath.Dir(*out), 0777); err != nil {
+		fmt.Fprintln(cli.errStream, err)
+		return 1
+	}
+	if err := converter.SaveImage(*out, sheet.Image, converter.DefaultOptions()); err != nil {
+		fmt.Fprintln(cli.errStream, err)
+		return 1
+	}
+
+	if *mapFile == "" && *mode == "sprite" {
+		*mapFile = (*out)[:len(*out)-len(filepath.Ext(*out))] + ".json"
+	}
+	if *mapFile != "" {
+		f, err := os.Create(*mapFile)
+		if err != nil {
+			fmt.Fprintln(cli.errStream, err)
+			return 1
+		}
+		err = sheet.WriteMap(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Fprintln(cli.errStream, err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(cli.outStream, "%d images tiled into %s\n", len(sheet.Sprites), *out)
+	return 0
+}
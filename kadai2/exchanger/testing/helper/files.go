@@ -0,0 +1,120 @@
+package helper
+
+import (
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Encoder writes img in a format.
+type Encoder func(w io.Writer, img image.Image) error
+
+// Encoders are the encoders of the standard library by extension.
+// The codecs of this repository are not imported here, since their tests import this
+// package. The packages under test pass them with the encoders argument of WriteTree,
+// as the converter does with its table of all the supported formats.
+var Encoders = map[string]Encoder{
+	"png":  png.Encode,
+	"jpg":  func(w io.Writer, img image.Image) error { return jpeg.Encode(w, img, nil) },
+	"jpeg": func(w io.Writer, img image.Image) error { return jpeg.Encode(w, img, nil) },
+}
+
+// WriteTree writes images to a temporary directory removed after the test, and returns it.
+// The keys are slash-separated paths, and each image is encoded by the extension of
+// its path with encoders or Encoders.
+func WriteTree(t *testing.T, images map[string]image.Image, encoders map[string]Encoder) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, img := range images {
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+		enc, ok := encoders[ext]
+		if !ok {
+			if enc, ok = Encoders[ext]; !ok {
+				t.Fatalf("%s: no encoder for %q", name, ext)
+			}
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = enc(f, img)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	return dir
+}
+
+// Files returns the sorted slash-separated paths of the files under dir relative to it.
+// Names starting with a dot, such as history logs, are left out.
+func Files(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var files []string
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		files = append(files, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+// Chdir changes the working directory to dir until the test ends, for the code which
+// writes to the working directory. A test which calls it must not run in parallel.
+func Chdir(t *testing.T, dir string) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// DecodeFile decodes the image file path with the decoders registered to the image package.
+func DecodeFile(t *testing.T, path string) image.Image {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+
+	return img
+}
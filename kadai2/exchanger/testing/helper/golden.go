@@ -0,0 +1,47 @@
+package helper
+
+import (
+	"flag"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// update is set by `go test -update` to write the golden files again instead of comparing with them.
+var update = flag.Bool("update", false, "update the golden files")
+
+// TestGoldenImage tests that got matches the PNG golden file path within tolerance of each
+// 8-bit channel. With -update, it writes got to path instead, making the directories.
+func TestGoldenImage(t *testing.T, got image.Image, path string, tolerance int) {
+	t.Helper()
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := png.Encode(f, got); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("%v (run the test with -update to write it)", err)
+	}
+	defer f.Close()
+	want, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	if err := CompareImages(want, got, tolerance); err != nil {
+		t.Errorf("%s: %v", path, err)
+	}
+}
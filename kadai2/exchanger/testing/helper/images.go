@@ -0,0 +1,119 @@
+package helper
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Gradient returns a w x h image whose red grows to the right and green to the bottom.
+func Gradient(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 255 / w), uint8(y * 255 / h), 128, 255})
+		}
+	}
+
+	return img
+}
+
+// Gradient16 returns a w x h image with 16-bit samples, which cannot be stored in 8 bits.
+func Gradient16(w, h int) *image.NRGBA64 {
+	img := image.NewNRGBA64(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA64(x, y, color.NRGBA64{uint16(x * 0xffff / w), uint16(y * 0xffff / h), 0x8001, 0xffff})
+		}
+	}
+
+	return img
+}
+
+// Checker returns a w x h checkerboard of squares of size pixels in a and b.
+func Checker(w, h, size int, a, b color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if (x/size+y/size)%2 == 0 {
+				img.Set(x, y, a)
+			} else {
+				img.Set(x, y, b)
+			}
+		}
+	}
+
+	return img
+}
+
+// Framed returns a w x h image of c with a border of width pixels in border,
+// such as a transparent margin to trim.
+func Framed(w, h, width int, c, border color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	inner := image.Rect(width, width, w-width, h-width)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if image.Pt(x, y).In(inner) {
+				img.Set(x, y, c)
+			} else {
+				img.Set(x, y, border)
+			}
+		}
+	}
+
+	return img
+}
+
+// CompareImages returns an error describing how got differs from want if they have different
+// sizes or any of their 8-bit channels differs by more than tolerance.
+// The images are compared from their top-left corners.
+func CompareImages(want, got image.Image, tolerance int) error {
+	wb, gb := want.Bounds(), got.Bounds()
+	if wb.Size() != gb.Size() {
+		return fmt.Errorf("size is %v, want %v", gb.Size(), wb.Size())
+	}
+
+	var count, worst int
+	var first image.Point
+	for y := 0; y < wb.Dy(); y++ {
+		for x := 0; x < wb.Dx(); x++ {
+			d := diff(want.At(wb.Min.X+x, wb.Min.Y+y), got.At(gb.Min.X+x, gb.Min.Y+y))
+			if d <= tolerance {
+				continue
+			}
+			if count == 0 {
+				first = image.Pt(x, y)
+			}
+			count++
+			if d > worst {
+				worst = d
+			}
+		}
+	}
+	if count > 0 {
+		return fmt.Errorf("%d pixels differ by more than %d, up to %d, first at %v: got %v, want %v",
+			count, tolerance, worst, first,
+			color.NRGBAModel.Convert(got.At(gb.Min.X+first.X, gb.Min.Y+first.Y)),
+			color.NRGBAModel.Convert(want.At(wb.Min.X+first.X, wb.Min.Y+first.Y)))
+	}
+
+	return nil
+}
+
+// diff returns the largest difference between the 8-bit channels of a and b.
+func diff(a, b color.Color) int {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	d := 0
+	for _, p := range [][2]uint32{{ar, br}, {ag, bg}, {ab, bb}, {aa, ba}} {
+		v := int(p[0]>>8) - int(p[1]>>8)
+		if v < 0 {
+			v = -v
+		}
+		if v > d {
+			d = v
+		}
+	}
+
+	return d
+}
@@ -0,0 +1,54 @@
+package helper
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestCompareImages(t *testing.T) {
+	shifted := Gradient(8, 8)
+	shifted.SetNRGBA(3, 2, color.NRGBA{255, 0, 0, 255})
+	offset := image.NewNRGBA(image.Rect(10, 10, 18, 18))
+	copy(offset.Pix, Gradient(8, 8).Pix)
+
+	tests := []struct {
+		name      string
+		got       image.Image
+		tolerance int
+		// エラーメッセージに含まれるはずの文字列
+		want string
+	}{
+		{"same", Gradient(8, 8), 0, ""},
+		{"offset bounds", offset, 0, ""},
+		{"size", Gradient(8, 4), 0, "size is (8,4), want (8,8)"},
+		{"pixel", shifted, 0, "1 pixels differ by more than 0"},
+		{"first pixel", shifted, 0, "first at (3,2)"},
+		{"within tolerance", shifted, 255, ""},
+	}
+
+	for _, tt := range tests {
+		err := CompareImages(Gradient(8, 8), tt.got, tt.tolerance)
+		if tt.want == "" {
+			TestWantError(t, err, false)
+		} else if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: CompareImages = %v, want %q in it", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	dir := WriteTree(t, map[string]image.Image{
+		"a.png":      Gradient(4, 4),
+		"b/c.JPG":    Checker(4, 4, 2, color.Black, color.White),
+		"b/.ignored": Gradient(1, 1),
+	}, map[string]Encoder{"ignored": Encoders["png"]})
+
+	if got := strings.Join(Files(t, dir), " "); got != "a.png b/c.JPG" {
+		t.Errorf("Files = %s, want a.png b/c.JPG", got)
+	}
+	if err := CompareImages(Gradient(4, 4), DecodeFile(t, dir+"/a.png"), 0); err != nil {
+		t.Error(err)
+	}
+}
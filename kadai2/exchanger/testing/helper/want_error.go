@@ -1,6 +1,10 @@
 package helper
 
-import "testing"
+import (
+	"errors"
+	"regexp"
+	"testing"
+)
 
 // TestWantError tests wantError.
 func TestWantError(t *testing.T, err error, want bool) {
@@ -13,3 +17,34 @@ func TestWantError(t *testing.T, err error, want bool) {
 		t.Error("got nothing happened, want an error")
 	}
 }
+
+// TestErrorIs tests that err wraps target with errors.Is. A nil target wants no error.
+func TestErrorIs(t *testing.T, err, target error) {
+	t.Helper()
+
+	if target == nil {
+		TestWantError(t, err, false)
+		return
+	}
+	if !errors.Is(err, target) {
+		t.Errorf("got an error %v, want %v", err, target)
+	}
+}
+
+// TestErrorMatch tests that the message of err matches the regular expression pattern.
+// An empty pattern wants no error.
+func TestErrorMatch(t *testing.T, err error, pattern string) {
+	t.Helper()
+
+	if pattern == "" {
+		TestWantError(t, err, false)
+		return
+	}
+	if err == nil {
+		t.Errorf("got nothing happened, want an error matching %q", pattern)
+		return
+	}
+	if !regexp.MustCompile(pattern).MatchString(err.Error()) {
+		t.Errorf("got an error %q, want one matching %q", err, pattern)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"gopher-dojo/kadai2/exchanger/converter"
+)
+
+func (cli *CLI) runVerify(args []string) int {
+	def := converter.DefaultOptions()
+	fs := cli.flagSet("verify", "Compares a converted image with the original and fails if it is below the thresholds.")
+	minPSNR := fs.Float64("min-psnr", def.MinPSNR, "lowest PSNR in dB")
+	minSSIM := fs.Float64("min-ssim", def.MinSSIM, "lowest SSIM")
+	if err := fs.Parse(args); err != nil {
+		return exitCode(err)
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 1
+	}
+
+	opts := converter.DefaultOptions()
+	opts.MinPSNR, opts.MinSSIM = *minPSNR, *minSSIM
+	v, err := converter.CompareFiles(fs.Arg(0), fs.Arg(1), opts)
+	if err != nil {
+		fmt.Fprintln(cli.errStream, err)
+		return 1
+	}
+
+	if v.Identical {
+		fmt.Fprintln(cli.outStream, "identical")
+		return 0
+	}
+	fmt.Fprintf(cli.outStream, "PSNR %.2f dB, SSIM %.4f\n", v.PSNR, v.SSIM)
+	if v.Flagged {
+		fmt.Fprintln(cli.errStream, "below the thresholds")
+		return 1
+	}
+	return 0
+}